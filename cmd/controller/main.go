@@ -16,6 +16,7 @@ import (
 	"github.com/sethpjohnson/only-fan-controller/internal/api"
 	"github.com/sethpjohnson/only-fan-controller/internal/config"
 	"github.com/sethpjohnson/only-fan-controller/internal/controller"
+	"github.com/sethpjohnson/only-fan-controller/internal/ipmi"
 	"github.com/sethpjohnson/only-fan-controller/internal/monitor"
 	"github.com/sethpjohnson/only-fan-controller/internal/mqtt"
 	"github.com/sethpjohnson/only-fan-controller/internal/storage"
@@ -33,13 +34,31 @@ func applyEnvOverrides(cfg *config.Config) {
 	if v := os.Getenv("IDRAC_PASSWORD"); v != "" {
 		cfg.IDRAC.Password = v
 	}
+	if v := os.Getenv("IDRAC_PASSWORD_FILE"); v != "" {
+		cfg.IDRAC.PasswordFile = v
+	}
+	if v := os.Getenv("IDRAC_TRANSPORT"); v != "" {
+		cfg.IDRAC.Transport = strings.ToLower(v)
+	}
 
 	// GPU settings
 	if v := os.Getenv("GPU_ENABLED"); v != "" {
 		cfg.GPU.Enabled = strings.ToLower(v) == "true" || v == "1"
 	}
+	if v := os.Getenv("GPU_BACKEND"); v != "" {
+		cfg.GPU.Backend = strings.ToLower(v)
+	}
+	if v := os.Getenv("GPU_PERSISTENT"); v != "" {
+		cfg.GPU.Persistent = strings.ToLower(v) == "true" || v == "1"
+	}
 
 	// Fan control settings
+	if v := os.Getenv("FAN_CONTROL_MODE"); v != "" {
+		cfg.FanControl.Mode = strings.ToLower(v)
+	}
+	if v := os.Getenv("FAN_BACKEND"); v != "" {
+		cfg.Actuator.Backend = strings.ToLower(v)
+	}
 	if v := os.Getenv("FAN_MIN_SPEED"); v != "" {
 		if i, err := strconv.Atoi(v); err == nil {
 			cfg.FanControl.MinSpeed = i
@@ -76,6 +95,11 @@ func applyEnvOverrides(cfg *config.Config) {
 		}
 	}
 
+	// CPU temperature source
+	if v := os.Getenv("CPU_SOURCE"); v != "" {
+		cfg.Monitoring.CPUSource = strings.ToLower(v)
+	}
+
 	// Monitoring interval
 	if v := os.Getenv("CHECK_INTERVAL"); v != "" {
 		if i, err := strconv.Atoi(v); err == nil {
@@ -186,10 +210,10 @@ func logCleanupResult(store *storage.Store, retention time.Duration, retentionDa
 // to fall back to defaults + env overrides) from a present-but-invalid file
 // (parse or safety-validation failure). For the latter we REFUSE to start rather
 // than silently fall back to Default(), which would discard the operator's real
-// iDRAC host/credentials and come up pointed at `-H "" -U root -P ""` — making
-// every IPMI call fail, including RestoreAutoMode itself. A process that never
-// starts never enables manual mode, so the BMC keeps automatic control: that is
-// the fail-safe outcome.
+// iDRAC host/credentials and come up pointed at `-H "" -U root` with an empty
+// password — making every IPMI call fail, including RestoreAutoMode itself. A
+// process that never starts never enables manual mode, so the BMC keeps
+// automatic control: that is the fail-safe outcome.
 func resolveConfig(path string) (*config.Config, error) {
 	cfg, err := config.Load(path)
 	if err == nil {
@@ -203,12 +227,76 @@ func resolveConfig(path string) (*config.Config, error) {
 	return nil, err
 }
 
+// loadReload loads the config for a reload the way run loads it at startup:
+// environment overrides and the password file apply on top of the file.
+func loadReload(path string) (*config.Config, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	applyEnvOverrides(cfg)
+	if err := cfg.IDRAC.ResolvePassword(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// reloadConfig reloads path into the running controller. An unreadable or
+// invalid file leaves the running config in place; there is no restart, so
+// the fans never go back to the BMC for a config change.
+func reloadConfig(path string, fanCtrl *controller.FanController) {
+	cfg, err := loadReload(path)
+	if err != nil {
+		log.Printf("Config reload of %s failed, keeping the current config: %v", path, err)
+		return
+	}
+	fanCtrl.Reload(cfg)
+}
+
+// fileStamp identifies a version of the config file for watchConfig; the zero
+// value while it cannot be read.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statConfig(path string) fileStamp {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: fi.ModTime(), size: fi.Size()}
+}
+
+// watchConfig checks path every interval and calls reload when the file has
+// changed, until stopCh is closed. A file caught half-written fails to load
+// and is reloaded again once the write completes and changes it again.
+func watchConfig(path string, interval time.Duration, reload func(), stopCh <-chan struct{}) {
+	last := statConfig(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if stamp := statConfig(path); stamp != last {
+				last = stamp
+				if stamp != (fileStamp{}) {
+					reload()
+				}
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
 // run holds the real program body so that deferred cleanup (e.g. store.Close)
 // always runs, even on an abnormal exit. main() only translates the returned
 // status into a process exit code.
 func run() int {
 	configPath := flag.String("config", "/etc/only-fan-controller/config.yaml", "Path to configuration file")
 	demoMode := flag.Bool("demo", false, "Run in demo mode with simulated temperatures (no actual fan control)")
+	watchInterval := flag.Duration("watch-config", 0, "Reload the config file when it changes, checking at this interval (0 = reload on SIGHUP only)")
 	flag.Parse()
 
 	// Load configuration
@@ -224,6 +312,13 @@ func run() int {
 	// Override with environment variables
 	applyEnvOverrides(cfg)
 
+	// Same reasoning as an invalid config: starting without the BMC password
+	// would leave every IPMI call (restore included) failing.
+	if err := cfg.IDRAC.ResolvePassword(); err != nil {
+		log.Printf("FATAL: cannot read the iDRAC password: %v", err)
+		return 1
+	}
+
 	if *demoMode {
 		log.Println("===========================================")
 		log.Println("  DEMO MODE - No actual hardware control")
@@ -269,18 +364,55 @@ func run() int {
 		restore = restoreOnce(mockCtrl.RestoreAutoMode)
 		go runControlLoop(mockCtrl.Run, restore, errCh)
 	} else {
-		// Initialize real monitors
-		cpuMon := monitor.NewCPUMonitor(cfg)
+		// Initialize real monitors. CPU temperatures come from the BMC unless
+		// monitoring.cpu_source selects the local hwmon drivers.
+		ipmiMon := monitor.NewCPUMonitor(cfg)
+		var cpuMon interface {
+			Read() (*monitor.CPUReading, error)
+		} = ipmiMon
+		if cfg.Monitoring.EffectiveCPUSource() == config.CPUSourceHwmon {
+			cpuMon = monitor.NewHwmonMonitor(cfg)
+			log.Printf("CPU temperatures: hwmon under %s", cfg.Monitoring.Hwmon.EffectiveRoot())
+		}
 		gpuMon := monitor.NewGPUMonitor(cfg)
+		defer gpuMon.Close()
 
 		// Initialize real fan controller
 		fanCtrl = controller.NewFanController(cfg, cpuMon, gpuMon, store)
+		var fanMon *monitor.FanMonitor
+		if cfg.FanControl.Verify.Enabled {
+			fanMon = monitor.NewFanMonitor(cfg)
+			fanCtrl.UseFans(fanMon)
+			log.Printf("Fan readback: verifying speed writes against the BMC's tachometers")
+		}
+
+		// One persistent RMCP+ session serves both the sensor reads and the fan
+		// writes. It is closed when run returns, after the final restore below.
+		if cfg.IDRAC.UsesNativeIPMI() {
+			client := ipmi.NewClient(ipmi.Config{
+				Host:     cfg.IDRAC.Host,
+				Username: cfg.IDRAC.Username,
+				Password: cfg.IDRAC.Password,
+			})
+			defer client.Close()
+			ipmiMon.UseNativeIPMI(client)
+			fanCtrl.UseNativeIPMI(client)
+			if fanMon != nil {
+				fanMon.UseNativeIPMI(client)
+			}
+			log.Printf("BMC transport: native RMCP+ (ipmitool fallback)")
+		}
+		if len(cfg.Sensors) > 0 {
+			fanCtrl.UseSensors(monitor.NewSensorMonitor(cfg, ipmiMon))
+			log.Printf("Named sensors: %d configured", len(cfg.Sensors))
+		}
 		restore = restoreOnce(fanCtrl.RestoreAutoMode)
 		go runControlLoop(fanCtrl.Run, restore, errCh)
 	}
 
 	// Initialize API server
 	apiServer := api.NewServer(cfg, fanCtrl, store)
+	apiServer.UseConfigFile(*configPath)
 
 	// Start API server. On error, report through errCh instead of os.Exit so the
 	// shutdown path (which restores BMC auto mode) still runs.
@@ -304,6 +436,27 @@ func run() int {
 		mqttBridge.Start()
 	}
 
+	// SIGHUP, and with -watch-config a change to the file, reloads the config
+	// without a restart.
+	reload := func() { reloadConfig(*configPath, fanCtrl) }
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			log.Printf("SIGHUP: reloading %s", *configPath)
+			reload()
+		}
+	}()
+	watchStop := make(chan struct{})
+	defer close(watchStop)
+	if *watchInterval > 0 {
+		go watchConfig(*configPath, *watchInterval, func() {
+			log.Printf("%s changed: reloading", *configPath)
+			reload()
+		}, watchStop)
+		log.Printf("Watching %s for changes every %s", *configPath, *watchInterval)
+	}
+
 	// Wait for a shutdown signal or a fatal error.
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
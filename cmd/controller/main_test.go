@@ -148,3 +148,38 @@ func TestRunControlLoopNormalReturnDoesNotReportError(t *testing.T) {
 	default:
 	}
 }
+
+func TestWatchConfigReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("fan_control:\n  idle_speed: 20\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reloads := make(chan struct{}, 4)
+	stop := make(chan struct{})
+	defer close(stop)
+	go watchConfig(path, 5*time.Millisecond, func() { reloads <- struct{}{} }, stop)
+
+	select {
+	case <-reloads:
+		t.Fatal("reloaded an unchanged file")
+	case <-time.After(30 * time.Millisecond):
+	}
+	if err := os.WriteFile(path, []byte("fan_control:\n  idle_speed: 30\n  max_speed: 90\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-reloads:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reload after the file changed")
+	}
+}
+
+func TestLoadReloadRejectsInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("fan_control:\n  max_speed: 150\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err := loadReload(path); err == nil {
+		t.Fatalf("loadReload = %+v, want the invalid file rejected", cfg)
+	}
+}
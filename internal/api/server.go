@@ -3,13 +3,16 @@ package api
 import (
 	"crypto/subtle"
 	"embed"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -27,6 +30,10 @@ type Server struct {
 	ctrl   *controller.FanController
 	store  *storage.Store
 	router *gin.Engine
+	// configPath is the YAML file PATCH /api/config persists to; empty
+	// refuses persist. configMu serializes the updates.
+	configPath string
+	configMu   sync.Mutex
 }
 
 type HintRequest struct {
@@ -57,13 +64,19 @@ func NewServer(cfg *config.Config, ctrl *controller.FanController, store *storag
 
 	if cfg.API.Token == "" {
 		log.Println("WARNING: no api.token configured (env API_TOKEN); mutating endpoints " +
-			"(override/hint) are restricted to loopback only. Set a token to control fans from other LAN hosts.")
+			"(override/hint/config) are restricted to loopback only. Set a token to control fans from other LAN hosts.")
 	}
 
 	s.setupRoutes()
 	return s
 }
 
+// UseConfigFile lets PATCH /api/config?persist=true write its changes to the
+// config file at path.
+func (s *Server) UseConfigFile(path string) {
+	s.configPath = path
+}
+
 func (s *Server) setupRoutes() {
 	// API routes
 	api := s.router.Group("/api")
@@ -71,6 +84,7 @@ func (s *Server) setupRoutes() {
 		// Read-only endpoints stay open: they expose no control surface.
 		api.GET("/status", s.handleStatus)
 		api.GET("/history", s.handleHistory)
+		api.GET("/events", s.handleEvents)
 		api.GET("/config", s.handleGetConfig)
 
 		// Mutating endpoints are gated by requireAuth (bearer token, or loopback
@@ -81,6 +95,7 @@ func (s *Server) setupRoutes() {
 			mutate.DELETE("/hint/:source", s.handleRemoveHint)
 			mutate.POST("/override", s.handleOverride)
 			mutate.DELETE("/override", s.handleClearOverride)
+			mutate.PATCH("/config", s.handlePatchConfig)
 		}
 	}
 
@@ -211,6 +226,27 @@ func (s *Server) handleHistory(c *gin.Context) {
 	})
 }
 
+// GET /api/events?duration=86400
+func (s *Server) handleEvents(c *gin.Context) {
+	durationStr := c.DefaultQuery("duration", "86400")
+	durationSec, err := strconv.Atoi(durationStr)
+	if err != nil {
+		durationSec = 86400
+	}
+
+	events, err := s.store.GetEvents(time.Duration(durationSec) * time.Second)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"duration": durationSec,
+		"count":    len(events),
+		"data":     events,
+	})
+}
+
 // POST /api/hint
 func (s *Server) handleHint(c *gin.Context) {
 	var req HintRequest
@@ -288,13 +324,119 @@ func (s *Server) handleClearOverride(c *gin.Context) {
 
 // GET /api/config
 func (s *Server) handleGetConfig(c *gin.Context) {
-	// Return sanitized config (no passwords)
+	// Return sanitized config (no passwords). The controller's config is the
+	// one running, reloads included.
+	cfg := s.ctrl.Config()
 	c.JSON(http.StatusOK, gin.H{
-		"idrac_host":  s.cfg.IDRAC.Host,
-		"gpu_enabled": s.cfg.GPU.Enabled,
-		"interval":    s.cfg.Monitoring.Interval,
-		"zones":       s.cfg.Zones,
-		"fan_control": s.cfg.FanControl,
-		"api_port":    s.cfg.API.Port,
+		"idrac_host":  cfg.IDRAC.Host,
+		"gpu_enabled": cfg.GPU.Enabled,
+		"interval":    cfg.Monitoring.Interval,
+		"zones":       cfg.Zones,
+		"fan_control": cfg.FanControl,
+		"sensors":     cfg.Sensors,
+		"gpu_devices": cfg.GPU.Devices,
+		"api_port":    cfg.API.Port,
 	})
 }
+
+// PATCH /api/config
+//
+// The body is a JSON merge patch of fan_control and zones (see
+// config.Config.Patch). The result is validated like the config file and, on
+// success, persisted when ?persist=true and applied to the control loop
+// before the response. ?dry_run=true stops after validation. Either way the
+// response lists the changes.
+func (s *Server) handlePatchConfig(c *gin.Context) {
+	dryRun, err := queryBool(c, "dry_run")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	persist, err := queryBool(c, "persist")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if persist && s.configPath == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no config file to persist to"})
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// One update at a time from reading the running config to applying the
+	// change, so neither the running config nor the file loses an update. A
+	// reload of the file in between makes Apply refuse the change, which is
+	// then redone on top of the reloaded config.
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	for attempt := 1; ; attempt++ {
+		cur := s.ctrl.Config()
+		next, err := cur.Patch(body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		changes, err := cur.Changes(next)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		merged, restart := cur.Reload(next)
+		if err := merged.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"valid": false, "error": err.Error(), "changes": changes})
+			return
+		}
+		if dryRun {
+			c.JSON(http.StatusOK, gin.H{"valid": true, "dry_run": true, "changes": changes, "restart_required": restart})
+			return
+		}
+
+		// Persist first: a change that cannot be saved is not applied either.
+		if persist {
+			if err := config.WriteLive(s.configPath, next, changes); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("persist config: %v", err)})
+				return
+			}
+		}
+		if len(changes) > 0 {
+			restart, err = s.ctrl.Apply(cur, next)
+			if errors.Is(err, controller.ErrConfigChanged) && attempt < maxConfigAttempts {
+				continue
+			}
+			if err != nil {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("apply config: %v", err)})
+				return
+			}
+			log.Printf("Config updated via API: %d setting(s) changed", len(changes))
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"valid":            true,
+			"status":           "config applied",
+			"persisted":        persist,
+			"changes":          changes,
+			"restart_required": restart,
+		})
+		return
+	}
+}
+
+// maxConfigAttempts bounds how often PATCH /api/config redoes a change that
+// reloads of the file keep overtaking.
+const maxConfigAttempts = 3
+
+// queryBool parses the optional boolean query parameter name.
+func queryBool(c *gin.Context, name string) (bool, error) {
+	v := c.Query(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %q", name, v)
+	}
+	return b, nil
+}
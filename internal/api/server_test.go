@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -92,6 +95,7 @@ var mutatingRoutes = []struct {
 	{"DELETE /api/hint/:source", http.MethodDelete, "/api/hint/whisper", nil},
 	{"POST /api/override", http.MethodPost, "/api/override", []byte(validOverrideBody)},
 	{"DELETE /api/override", http.MethodDelete, "/api/override", nil},
+	{"PATCH /api/config", http.MethodPatch, "/api/config", []byte(`{"fan_control":{"cpu_threshold":70}}`)},
 }
 
 func TestMutatingRequiresTokenWhenConfigured(t *testing.T) {
@@ -284,3 +288,76 @@ func TestOverrideClampedViaAPI(t *testing.T) {
 		t.Fatalf("override expiry must be finite (not infinite), got %q", status.Override.ExpiresAt)
 	}
 }
+
+func TestPatchConfig(t *testing.T) {
+	s := newTestServer(t, "")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("fan_control:\n  cpu_threshold: 65 # ramp start\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	patch := []byte(`{"fan_control":{"cpu_threshold":70}}`)
+
+	var resp struct {
+		Valid   bool            `json:"valid"`
+		Error   string          `json:"error"`
+		Changes []config.Change `json:"changes"`
+	}
+	w := doRequest(s, http.MethodPatch, "/api/config?dry_run=true", "", "127.0.0.1:4000", patch)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("dry run: got %d %s", w.Code, w.Body.String())
+	}
+	if !resp.Valid || len(resp.Changes) != 1 || resp.Changes[0].Path != "fan_control.cpu_threshold" || resp.Changes[0].New != 70.0 {
+		t.Fatalf("dry run = %+v, want cpu_threshold 70 as the one change", resp)
+	}
+
+	// critical_cpu_temp at or below cpu_threshold fails Config.Validate.
+	w = doRequest(s, http.MethodPatch, "/api/config", "", "127.0.0.1:4000", []byte(`{"fan_control":{"critical_cpu_temp":60}}`))
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusBadRequest || resp.Valid || resp.Error == "" {
+		t.Fatalf("invalid patch: got %d %s, want 400 with the validation error", w.Code, w.Body.String())
+	}
+
+	if w := doRequest(s, http.MethodPatch, "/api/config?persist=true", "", "127.0.0.1:4000", patch); w.Code != http.StatusBadRequest {
+		t.Fatalf("persist without a config file: got %d, want 400", w.Code)
+	}
+	s.UseConfigFile(path)
+	if w := doRequest(s, http.MethodPatch, "/api/config?persist=true", "", "127.0.0.1:4000", patch); w.Code != http.StatusOK {
+		t.Fatalf("persist: got %d %s", w.Code, w.Body.String())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "cpu_threshold: 70 # ramp start") {
+		t.Fatalf("persisted file = %q, want cpu_threshold 70 with its comment", data)
+	}
+}
+
+// Concurrent PATCHes to different fields must both land: neither may be
+// derived from a config the other has already replaced.
+func TestConcurrentPatchesKeepBothChanges(t *testing.T) {
+	s := newTestServer(t, "")
+	patches := []string{`{"fan_control":{"cpu_threshold":70}}`, `{"fan_control":{"gpu_threshold":72}}`}
+
+	var wg sync.WaitGroup
+	for _, p := range patches {
+		wg.Add(1)
+		go func(body string) {
+			defer wg.Done()
+			if w := doRequest(s, http.MethodPatch, "/api/config", "", "127.0.0.1:4000", []byte(body)); w.Code != http.StatusOK {
+				t.Errorf("PATCH %s: got %d %s", body, w.Code, w.Body.String())
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	w := doRequest(s, http.MethodGet, "/api/config", "", "127.0.0.1:4000", nil)
+	var got struct {
+		FanControl config.FanControlConfig `json:"fan_control"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	if got.FanControl.CPUThreshold != 70 || got.FanControl.GPUThreshold != 72 {
+		t.Fatalf("thresholds = %d/%d, want both patches applied (70/72)", got.FanControl.CPUThreshold, got.FanControl.GPUThreshold)
+	}
+}
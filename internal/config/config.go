@@ -4,14 +4,24 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
 	IDRAC      IDRACConfig      `yaml:"idrac"`
+	Actuator   ActuatorConfig   `yaml:"actuator"`
 	Monitoring MonitoringConfig `yaml:"monitoring"`
 	GPU        GPUConfig        `yaml:"gpu"`
+	// Sensors are extra named temperature inputs (inlet/exhaust, NVMe, HBA,
+	// DIMM, ...) monitored and optionally controlled on alongside the built-in
+	// cpu and gpu sensors.
+	Sensors    []SensorConfig   `yaml:"sensors"`
 	Zones      []Zone           `yaml:"zones"`
 	FanControl FanControlConfig `yaml:"fan_control"`
 	API        APIConfig        `yaml:"api"`
@@ -38,16 +48,421 @@ type IDRACConfig struct {
 	Host     string `yaml:"host"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+
+	// Transport selects how a remote BMC is reached: "native" (default) keeps
+	// one in-process RMCP+ session open, "ipmitool" spawns ipmitool -I lanplus
+	// per command. The native transport falls back to ipmitool while the BMC
+	// cannot be reached over it. A "local" host always uses ipmitool.
+	Transport string `yaml:"transport"`
+
+	// PasswordFile, when set, is read at startup and replaces Password, so the
+	// secret can live in a root-only file or a Docker secret
+	// (/run/secrets/...) instead of the config file or environment.
+	PasswordFile string `yaml:"password_file"`
+}
+
+// ResolvePassword loads Password from PasswordFile when one is configured.
+// A single trailing newline (as left by echo or most editors) is stripped.
+func (i *IDRACConfig) ResolvePassword() error {
+	if i.PasswordFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(i.PasswordFile)
+	if err != nil {
+		return fmt.Errorf("idrac.password_file: %w", err)
+	}
+	pw := strings.TrimSuffix(string(data), "\n")
+	i.Password = strings.TrimSuffix(pw, "\r")
+	return nil
+}
+
+// IpmitoolConnection returns the ipmitool arguments that select the BMC and
+// the extra environment the command needs. For a remote host the password is
+// passed as IPMI_PASSWORD with -E, never as -P: argv is readable by every user
+// on the host through ps and /proc, the environment only by the same user.
+func (i IDRACConfig) IpmitoolConnection() (args, env []string) {
+	if i.Host == "local" {
+		return nil, nil
+	}
+	args = []string{"-I", "lanplus", "-H", i.Host, "-U", i.Username, "-E"}
+	return args, []string{"IPMI_PASSWORD=" + i.Password}
+}
+
+// BMC transports accepted by idrac.transport.
+const (
+	TransportNative   = "native"
+	TransportIPMItool = "ipmitool"
+)
+
+// EffectiveTransport returns the configured transport, defaulting to native.
+func (i IDRACConfig) EffectiveTransport() string {
+	if i.Transport == "" {
+		return TransportNative
+	}
+	return i.Transport
+}
+
+// UsesNativeIPMI reports whether the BMC is reached over the in-process RMCP+
+// client: a remote host with the native transport.
+func (i IDRACConfig) UsesNativeIPMI() bool {
+	return i.Host != "local" && i.EffectiveTransport() == TransportNative
+}
+
+// ActuatorConfig selects how fan speeds are written. The IPMI backends (dell,
+// supermicro) reach the BMC through ipmitool using the idrac connection
+// settings; hwmon writes a Linux pwm chip's sysfs files directly.
+type ActuatorConfig struct {
+	Backend string              `yaml:"backend" json:"backend"` // "dell" (default), "supermicro" or "hwmon"
+	Hwmon   HwmonActuatorConfig `yaml:"hwmon" json:"hwmon"`
+}
+
+// HwmonActuatorConfig configures the hwmon pwm backend. Fan index i (as used by
+// fan_control.groups) is pwm channel Channels[i].
+type HwmonActuatorConfig struct {
+	Path     string `yaml:"path" json:"path"`           // hwmon device directory, e.g. /sys/class/hwmon/hwmon2
+	Channels []int  `yaml:"channels" json:"channels"`   // pwmN channel numbers driven by the controller
+	AutoMode int    `yaml:"auto_mode" json:"auto_mode"` // pwmN_enable value that hands a channel back to the chip (0 = 2)
+}
+
+// Fan actuator backends accepted by actuator.backend.
+const (
+	BackendDell       = "dell"
+	BackendSupermicro = "supermicro"
+	BackendHwmon      = "hwmon"
+)
+
+// EffectiveBackend returns the actuator backend actually in force, treating an
+// unset backend as Dell (the only backend before actuators were pluggable).
+func (a ActuatorConfig) EffectiveBackend() string {
+	if a.Backend == "" {
+		return BackendDell
+	}
+	return a.Backend
+}
+
+// EffectiveAutoMode returns the pwmN_enable value for automatic control,
+// defaulting to 2, which most hwmon drivers treat as their automatic mode.
+func (h HwmonActuatorConfig) EffectiveAutoMode() int {
+	if h.AutoMode > 0 {
+		return h.AutoMode
+	}
+	return 2
 }
 
 type MonitoringConfig struct {
 	Interval         int `yaml:"interval"`          // Seconds between checks
 	HistoryRetention int `yaml:"history_retention"` // Seconds to keep history
+	// TrendWindow is how many seconds of history the trend regression fits
+	// (0 = 60s). TrendSmoothing is an optional EWMA weight in [0, 1) on the
+	// previous smoothed sample, applied before fitting: 0 disables it and higher
+	// values smooth harder.
+	TrendWindow    int     `yaml:"trend_window"`
+	TrendSmoothing float64 `yaml:"trend_smoothing"`
+
+	// Plausibility screens CPU and GPU readings before they are used.
+	Plausibility PlausibilityConfig `yaml:"plausibility"`
+
+	// CPUSource selects where CPU temperatures come from: "ipmi" (default, the
+	// BMC's temperature sensors) or "hwmon" (the local kernel's hwmon drivers,
+	// for a controller running on the host itself).
+	CPUSource string            `yaml:"cpu_source"`
+	Hwmon     HwmonSourceConfig `yaml:"hwmon"`
+
+	// SDRProfile names the built-in BMC sensor map for the platform (see
+	// SDRProfiles); empty is SDRProfileGeneric. SDRMap entries are tried
+	// before the profile's, so they can remap or ignore any sensor.
+	SDRProfile string       `yaml:"sdr_profile"`
+	SDRMap     []SDRMapping `yaml:"sdr_map"`
+}
+
+// SDRMapping gives the BMC temperature sensors it matches a role. Name is a
+// regular expression on the SDR sensor name, Entity the entity ID as ipmitool
+// prints it ("3.1", or "3" for any instance) and Number the sensor number; an
+// unset field matches anything, and the first matching entry decides.
+type SDRMapping struct {
+	Name   string `yaml:"name" json:"name,omitempty"`
+	Entity string `yaml:"entity" json:"entity,omitempty"`
+	Number *int   `yaml:"number" json:"number,omitempty"`
+	Role   string `yaml:"role" json:"role"`
+}
+
+// SDR sensor roles. A CPU sensor is either numbered by socket (cpu0, cpu1, ...)
+// or plain "cpu", which follows the numbered ones in SDR order.
+const (
+	SDRRoleCPU     = "cpu"
+	SDRRoleInlet   = "inlet"
+	SDRRoleExhaust = "exhaust"
+	SDRRoleIgnore  = "ignore"
+)
+
+var sdrCPURoleRe = regexp.MustCompile(`^cpu([0-9])?$`)
+
+// SDRCPUSocket reports whether role is a CPU role and, for cpuN, its socket
+// (-1 for plain "cpu").
+func SDRCPUSocket(role string) (socket int, ok bool) {
+	m := sdrCPURoleRe.FindStringSubmatch(role)
+	if m == nil {
+		return 0, false
+	}
+	if m[1] == "" {
+		return -1, true
+	}
+	return int(m[1][0] - '0'), true
+}
+
+// SDRProfileGeneric is the map used without monitoring.sdr_profile: Dell's
+// unnamed "Temp" CPU sensors, processor entities (3.x), sensors named like a
+// CPU temperature, and inlet/exhaust by name. Anything else (DIMM, PSU, board
+// sensors) is ignored.
+const SDRProfileGeneric = "generic"
+
+// dellIDRAC8 is the sensor layout of iDRAC7/8 (R620, R720, R730): the two CPU
+// sensors are both called "Temp" and told apart by entity instance.
+var dellIDRAC8 = []SDRMapping{
+	{Name: `^Inlet Temp$`, Role: SDRRoleInlet},
+	{Name: `^Exhaust Temp$`, Role: SDRRoleExhaust},
+	{Entity: "3.1", Role: "cpu0"},
+	{Entity: "3.2", Role: "cpu1"},
+}
+
+// dellIDRAC9 is iDRAC9 (R740, R750), where firmware may name the CPU sensors
+// "CPU1 Temp" and "CPU2 Temp" instead of "Temp".
+var dellIDRAC9 = append([]SDRMapping{
+	{Name: `^CPU1 Temp$`, Role: "cpu0"},
+	{Name: `^CPU2 Temp$`, Role: "cpu1"},
+}, dellIDRAC8...)
+
+// SDRProfiles are the built-in sensor maps selectable with
+// monitoring.sdr_profile.
+var SDRProfiles = map[string][]SDRMapping{
+	SDRProfileGeneric: {
+		{Name: `(?i)inlet`, Role: SDRRoleInlet},
+		{Name: `(?i)exhaust`, Role: SDRRoleExhaust},
+		{Entity: "3", Role: SDRRoleCPU},
+		{Name: `^Temp$`, Role: SDRRoleCPU},
+		{Name: `(?i)^(cpu|proc(essor)?)\s*[0-9]*\s*temp`, Role: SDRRoleCPU},
+	},
+	"dell": append(append([]SDRMapping{}, dellIDRAC9...), SDRMapping{Name: `^Temp$`, Role: SDRRoleCPU}),
+	"r620": dellIDRAC8,
+	"r720": dellIDRAC8,
+	"r730": dellIDRAC8,
+	"r740": dellIDRAC9,
+	"r750": dellIDRAC9,
+}
+
+// EffectiveSDRMap returns the sensor map applied to BMC temperature sensors:
+// the configured entries, then the profile's.
+func (m MonitoringConfig) EffectiveSDRMap() []SDRMapping {
+	profile := m.SDRProfile
+	if profile == "" {
+		profile = SDRProfileGeneric
+	}
+	return append(append([]SDRMapping{}, m.SDRMap...), SDRProfiles[strings.ToLower(profile)]...)
+}
+
+// CPU temperature sources accepted by monitoring.cpu_source.
+const (
+	CPUSourceIPMI  = "ipmi"
+	CPUSourceHwmon = "hwmon"
+)
+
+// EffectiveCPUSource returns the CPU temperature source, defaulting to IPMI.
+func (m MonitoringConfig) EffectiveCPUSource() string {
+	if m.CPUSource == "" {
+		return CPUSourceIPMI
+	}
+	return m.CPUSource
+}
+
+// HwmonSourceConfig selects the hwmon temperature inputs read when
+// monitoring.cpu_source is "hwmon". Every tempN_input matching any entry of
+// Sensors counts as a CPU temperature; the hottest drives the fans.
+type HwmonSourceConfig struct {
+	Root    string        `yaml:"root"`    // hwmon class directory (empty = /sys/class/hwmon)
+	Sensors []HwmonSensor `yaml:"sensors"` // empty = the CPU package sensors of coretemp and k10temp
+}
+
+// HwmonSensor matches hwmon temperature inputs by driver and label. Both are
+// shell-style patterns (path.Match): Chip against the hwmon device's name
+// file (coretemp, k10temp, nvme, drivetemp, ...), Label against tempN_label.
+// An empty Label matches every input of the chip, including unlabelled ones.
+type HwmonSensor struct {
+	Chip  string `yaml:"chip"`
+	Label string `yaml:"label"`
+}
+
+// DefaultHwmonSensors picks the CPU package temperature on Intel (coretemp)
+// and AMD (k10temp) systems.
+var DefaultHwmonSensors = []HwmonSensor{
+	{Chip: "coretemp", Label: "Package id *"},
+	{Chip: "k10temp", Label: "Tctl"},
+}
+
+// EffectiveRoot returns the hwmon class directory.
+func (h HwmonSourceConfig) EffectiveRoot() string {
+	if h.Root == "" {
+		return "/sys/class/hwmon"
+	}
+	return h.Root
+}
+
+// EffectiveSensors returns the configured sensor selection, or
+// DefaultHwmonSensors when none is configured.
+func (h HwmonSourceConfig) EffectiveSensors() []HwmonSensor {
+	if len(h.Sensors) == 0 {
+		return DefaultHwmonSensors
+	}
+	return h.Sensors
+}
+
+// PlausibilityConfig rejects CPU and GPU readings that cannot be real; each
+// check is off when zero. A rejected reading counts as a sensor failure toward
+// fan_control.sensor_failure_limit, like a failed read.
+type PlausibilityConfig struct {
+	// StuckTicks is how many consecutive reads may return exactly the same
+	// temperatures from a source before it is taken to be frozen.
+	StuckTicks int `yaml:"stuck_ticks" json:"stuck_ticks"`
+	// MaxSlew is the fastest a temperature may move, in °C per second since
+	// the last accepted reading.
+	MaxSlew float64 `yaml:"max_slew" json:"max_slew"`
+	// Median replaces each temperature with the median of its last three
+	// samples, so a one-off spike is dropped rather than acted on.
+	Median bool `yaml:"median" json:"median"`
+}
+
+// defaultTrendWindow is the trend regression window used when
+// monitoring.trend_window is unset.
+const defaultTrendWindow = 60
+
+// EffectiveTrendWindow returns the trend regression window in seconds,
+// substituting the default when unset.
+func (m MonitoringConfig) EffectiveTrendWindow() int {
+	if m.TrendWindow > 0 {
+		return m.TrendWindow
+	}
+	return defaultTrendWindow
+}
+
+// SensorConfig is one named temperature sensor. Its temperature is the hottest
+// input matching the source's selector: a BMC SDR sensor name for "ipmi", a
+// chip/label pair for "hwmon".
+//
+// Above Threshold the sensor asks for a fan speed that rises linearly from the
+// idle speed to MaxSpeed at Critical, scaled by Weight; the fans run at least
+// that fast whatever fan_control.mode decides. Reaching Critical triggers the
+// emergency ramp just like critical_cpu_temp. A sensor with neither threshold
+// nor critical set is only monitored.
+type SensorConfig struct {
+	Name      string      `yaml:"name" json:"name"`           // lower-case identifier: [a-z0-9_]+
+	Source    string      `yaml:"source" json:"source"`       // "ipmi" or "hwmon"
+	IPMIName  string      `yaml:"ipmi_name" json:"ipmi_name"` // SDR sensor name pattern, e.g. "Inlet Temp"
+	Hwmon     HwmonSensor `yaml:"hwmon" json:"hwmon"`
+	Threshold int         `yaml:"threshold" json:"threshold"` // °C; 0 = takes no part in the fan speed
+	Critical  int         `yaml:"critical" json:"critical"`   // °C; 0 = no emergency trigger
+	Weight    float64     `yaml:"weight" json:"weight"`       // ramp scale; 0 = 1
+}
+
+// Sensor sources accepted by sensors[].source.
+const (
+	SensorSourceIPMI  = "ipmi"
+	SensorSourceHwmon = "hwmon"
+)
+
+// EffectiveWeight returns the sensor's ramp weight, defaulting to 1.
+func (s SensorConfig) EffectiveWeight() float64 {
+	if s.Weight == 0 {
+		return 1
+	}
+	return s.Weight
 }
 
 type GPUConfig struct {
 	Enabled       bool   `yaml:"enabled"`
 	NvidiaSmiPath string `yaml:"nvidia_smi_path"`
+
+	// Backend selects how GPUs are read: "nvidia" (nvidia-smi), "rocm"
+	// (rocm-smi --json, AMD), "hwmon" (the amdgpu/i915/xe hwmon nodes) or
+	// "auto" (default), which uses the first of those that is present.
+	Backend     string `yaml:"backend"`
+	RocmSmiPath string `yaml:"rocm_smi_path"` // empty = rocm-smi from PATH
+
+	// Persistent keeps one nvidia-smi running in loop mode, holding its NVML
+	// handle open, and reads the rows it streams instead of starting
+	// nvidia-smi every interval. Stale or missing rows fall back to the
+	// one-shot query. nvidia backend only.
+	Persistent bool `yaml:"persistent"`
+
+	// Devices overrides the GPU threshold, critical temperature and weight of
+	// individual cards, or excludes them from the fan decision.
+	Devices []GPUDeviceConfig `yaml:"devices"`
+}
+
+// GPUDeviceConfig applies to the GPUs it matches by Index, UUID (nvidia-smi's
+// "GPU-..." UUID) or Name (a shell-style pattern on the model name); every
+// field set must match, and the first matching entry wins.
+//
+// A card's temperature counts against its own Threshold, and how far it is
+// above it is scaled by Weight, so the fans react to the card furthest over
+// its own threshold. An Excluded card takes no part in that, but it is still
+// read and still triggers the emergency ramp at its Critical temperature.
+type GPUDeviceConfig struct {
+	Index     *int    `yaml:"index" json:"index"`
+	UUID      string  `yaml:"uuid" json:"uuid"`
+	Name      string  `yaml:"name" json:"name"`
+	Threshold int     `yaml:"threshold" json:"threshold"` // °C; 0 = fan_control.gpu_threshold
+	Critical  int     `yaml:"critical" json:"critical"`   // °C; 0 = fan_control.critical_gpu_temp
+	Weight    float64 `yaml:"weight" json:"weight"`       // scale above threshold; 0 = 1
+	Exclude   bool    `yaml:"exclude" json:"exclude"`
+}
+
+// EffectiveThreshold returns the card's threshold, defaulting to fc's.
+func (d GPUDeviceConfig) EffectiveThreshold(fc FanControlConfig) int {
+	if d.Threshold == 0 {
+		return fc.EffectiveGPUThreshold()
+	}
+	return d.Threshold
+}
+
+// EffectiveCritical returns the card's critical temperature, defaulting to
+// fc's.
+func (d GPUDeviceConfig) EffectiveCritical(fc FanControlConfig) int {
+	if d.Critical == 0 {
+		return fc.CriticalGPUTemp
+	}
+	return d.Critical
+}
+
+// EffectiveWeight returns the card's weight, defaulting to 1.
+func (d GPUDeviceConfig) EffectiveWeight() float64 {
+	if d.Weight == 0 {
+		return 1
+	}
+	return d.Weight
+}
+
+// GPU backends accepted by gpu.backend.
+const (
+	GPUBackendAuto   = "auto"
+	GPUBackendNvidia = "nvidia"
+	GPUBackendROCm   = "rocm"
+	GPUBackendHwmon  = "hwmon"
+)
+
+// EffectiveBackend returns the configured GPU backend, defaulting to auto.
+func (g GPUConfig) EffectiveBackend() string {
+	if g.Backend == "" {
+		return GPUBackendAuto
+	}
+	return g.Backend
+}
+
+// EffectiveRocmSmiPath returns the rocm-smi command, defaulting to the one on
+// PATH.
+func (g GPUConfig) EffectiveRocmSmiPath() string {
+	if g.RocmSmiPath == "" {
+		return "rocm-smi"
+	}
+	return g.RocmSmiPath
 }
 
 type Zone struct {
@@ -58,6 +473,13 @@ type Zone struct {
 }
 
 type FanControlConfig struct {
+	// Mode selects the control strategy: "step" (the default threshold/step
+	// ramp with cooldown hysteresis), "pid" (closed-loop PID against per-source
+	// setpoints) or "curve" (piecewise-linear interpolation between the zones).
+	// The critical-temp emergency ramp and manual override precede every
+	// strategy.
+	Mode string `yaml:"mode" json:"mode"`
+
 	MinSpeed      int `yaml:"min_speed" json:"min_speed"`
 	MaxSpeed      int `yaml:"max_speed" json:"max_speed"`
 	IdleSpeed     int `yaml:"idle_speed" json:"idle_speed"`         // Base fan speed when idle
@@ -75,12 +497,340 @@ type FanControlConfig struct {
 	// hands cooling back to the BMC's automatic fan control.
 	SensorFailureLimit int `yaml:"sensor_failure_limit" json:"sensor_failure_limit"` // Consecutive sensor read failures before restoring auto mode
 	WriteFailureLimit  int `yaml:"write_failure_limit" json:"write_failure_limit"`   // Consecutive fan-write failures before restoring auto mode
+	// SafeSpeed adds an intermediate fail-safe stage (0 = off): after
+	// sensor_failure_limit consecutive sensor failures the fans are pinned at
+	// this speed under manual control, and cooling is only handed back to the
+	// BMC after SafeSpeedFailures more, or at once if pinning the fans fails.
+	// For hardware whose BMC auto mode is worse than a fixed speed (100% with
+	// third-party GPUs).
+	SafeSpeed         int `yaml:"safe_speed" json:"safe_speed"`
+	SafeSpeedFailures int `yaml:"safe_speed_failures" json:"safe_speed_failures"` // Further sensor failures at safe_speed before restoring auto mode (0 = 3)
+	// WriteRecovery lets the write fail-safe recover without a restart.
+	WriteRecovery WriteRecoveryConfig `yaml:"write_recovery" json:"write_recovery"`
+	// Verify reads the fan tachometers back to check the fans obey the
+	// speed writes.
+	Verify FanVerifyConfig `yaml:"verify" json:"verify"`
+	// ModeWatch reasserts manual control when the BMC silently goes back to
+	// automatic fan control.
+	ModeWatch ModeWatchConfig `yaml:"mode_watch" json:"mode_watch"`
+	// Degraded keeps control going on the healthy source when only one of the
+	// CPU and GPU sources fails.
+	Degraded DegradedConfig `yaml:"degraded" json:"degraded"`
+	// PID tunes the "pid" control mode. Ignored in step mode.
+	PID PIDConfig `yaml:"pid" json:"pid"`
+	// Predictive enables trend feed-forward in the step and curve modes.
+	Predictive PredictiveConfig `yaml:"predictive" json:"predictive"`
+	// Ambient compensates the control for the chassis inlet temperature.
+	Ambient AmbientConfig `yaml:"ambient" json:"ambient"`
+	// Groups drives banks of fans independently. Empty (the default) means one
+	// speed for every fan, as before. With groups, FanCount is required so the
+	// fans outside every group can be addressed one by one instead of with the
+	// all-fans (0xff) command, which would also hit the grouped fans.
+	Groups   []FanGroup `yaml:"groups" json:"groups"`
+	FanCount int        `yaml:"fan_count" json:"fan_count"` // Chassis fans, indexes 0..fan_count-1
 	// Legacy fields (still supported)
 	RampUpStep   int  `yaml:"ramp_up_step" json:"ramp_up_step"`
 	RampDownStep int  `yaml:"ramp_down_step" json:"ramp_down_step"`
 	ConstantIdle bool `yaml:"constant_idle" json:"constant_idle"`
 }
 
+// FanVerifyConfig configures fan readback. When enabled the BMC's fan
+// tachometers are read every tick, reported in the status, history and MQTT,
+// and a fan below StallRPM while commanded to spin is flagged as stalled.
+// With MaxRPM set, each fan's RPM is also compared with the commanded speed's
+// share of it once the speed has held for SettleTicks ticks: a fan further off
+// than Tolerance (a fraction of MaxRPM) is a mismatch, and a tick where most
+// fans are off, or every fan is stalled, fails verification. FailureLimit
+// failed ticks in a row mean the BMC is not obeying the writes, and cooling is
+// handed back to it.
+//
+// A single fan that is stalled, or (with MaxRPM) below FailedRatio of the
+// RPM expected at the commanded speed, has failed rather than been ignored:
+// the other fans are raised by Compensation percentage points until it turns
+// again.
+type FanVerifyConfig struct {
+	Enabled      bool    `yaml:"enabled" json:"enabled"`
+	MaxRPM       int     `yaml:"max_rpm" json:"max_rpm"`             // RPM at 100%; 0 = stall detection only
+	Tolerance    float64 `yaml:"tolerance" json:"tolerance"`         // Allowed RPM error as a fraction of max_rpm (0 = 0.25)
+	StallRPM     int     `yaml:"stall_rpm" json:"stall_rpm"`         // A fan below this while commanded to spin is stalled (0 = 300)
+	SettleTicks  int     `yaml:"settle_ticks" json:"settle_ticks"`   // Ticks after a speed change before checking (0 = 2)
+	FailureLimit int     `yaml:"failure_limit" json:"failure_limit"` // Consecutive failed checks before restoring auto mode (0 = 3)
+	FailedRatio  float64 `yaml:"failed_ratio" json:"failed_ratio"`   // A fan below this fraction of its expected RPM has failed (0 = 0.5)
+	Compensation int     `yaml:"compensation" json:"compensation"`   // Percentage points added to the other fans while one has failed (0 = 20)
+}
+
+// EffectiveTolerance returns the allowed RPM error as a fraction of max_rpm,
+// defaulting to 0.25.
+func (v FanVerifyConfig) EffectiveTolerance() float64 {
+	if v.Tolerance == 0 {
+		return 0.25
+	}
+	return v.Tolerance
+}
+
+// EffectiveStallRPM returns the stall threshold, defaulting to 300 RPM.
+func (v FanVerifyConfig) EffectiveStallRPM() int {
+	if v.StallRPM == 0 {
+		return 300
+	}
+	return v.StallRPM
+}
+
+// EffectiveSettleTicks returns how many ticks a speed must hold before it is
+// checked, defaulting to 2.
+func (v FanVerifyConfig) EffectiveSettleTicks() int {
+	if v.SettleTicks == 0 {
+		return 2
+	}
+	return v.SettleTicks
+}
+
+// EffectiveFailureLimit returns how many failed checks in a row restore auto
+// mode, defaulting to 3.
+func (v FanVerifyConfig) EffectiveFailureLimit() int {
+	if v.FailureLimit == 0 {
+		return 3
+	}
+	return v.FailureLimit
+}
+
+// EffectiveFailedRatio returns the fraction of the expected RPM below which a
+// fan has failed, defaulting to 0.5.
+func (v FanVerifyConfig) EffectiveFailedRatio() float64 {
+	if v.FailedRatio == 0 {
+		return 0.5
+	}
+	return v.FailedRatio
+}
+
+// EffectiveCompensation returns the percentage points the other fans are
+// raised by while one has failed, defaulting to 20.
+func (v FanVerifyConfig) EffectiveCompensation() int {
+	if v.Compensation == 0 {
+		return 20
+	}
+	return v.Compensation
+}
+
+// ModeWatchConfig configures the watch for the BMC reverting to automatic fan
+// control behind the controller's back (a BMC reset, a firmware update, some
+// PSU events), after which it silently ignores the speed writes. Every
+// Interval seconds the Supermicro and hwmon backends read the fan mode back;
+// the Dell iDRAC has no command for that, so the mode is inferred from the fan
+// tachometers instead, which needs fan_control.verify with max_rpm: when most
+// fans ignore the commanded speed, manual control is reasserted once before
+// the failed checks count toward the verify fail-safe.
+type ModeWatchConfig struct {
+	Enabled  bool `yaml:"enabled" json:"enabled"`
+	Interval int  `yaml:"interval" json:"interval"` // Seconds between fan mode reads (0 = 60)
+}
+
+// EffectiveInterval returns the seconds between fan mode reads, defaulting to
+// 60.
+func (m ModeWatchConfig) EffectiveInterval() int {
+	if m.Interval == 0 {
+		return 60
+	}
+	return m.Interval
+}
+
+// WriteRecoveryConfig configures recovery from the write fail-safe, which is
+// otherwise sticky until the process restarts. While it is active the BMC is
+// probed with a read-only request, backing off exponentially after a failed
+// probe; after HealthyProbes consecutive healthy probes manual control is
+// reclaimed once, guarded by a verifying fan write. A relapse (the reclaim
+// failing, or the write fail-safe tripping again within StableWindow of one)
+// counts as a flap, and after MaxFlaps of them the fail-safe stays sticky
+// until restart. A reclaim that holds for StableWindow forgets the flaps.
+type WriteRecoveryConfig struct {
+	Enabled          bool `yaml:"enabled" json:"enabled"`
+	ProbeInterval    int  `yaml:"probe_interval" json:"probe_interval"`         // Seconds between probes, and before the first (0 = 30)
+	MaxProbeInterval int  `yaml:"max_probe_interval" json:"max_probe_interval"` // Backoff cap in seconds (0 = 900)
+	HealthyProbes    int  `yaml:"healthy_probes" json:"healthy_probes"`         // Consecutive healthy probes before reclaiming (0 = 3)
+	MaxFlaps         int  `yaml:"max_flaps" json:"max_flaps"`                   // Relapses before the fail-safe stays sticky (0 = 3)
+	StableWindow     int  `yaml:"stable_window" json:"stable_window"`           // Seconds a reclaim must hold before tripping again is a new incident (0 = 3600)
+}
+
+// EffectiveProbeInterval returns the base probe interval in seconds,
+// defaulting to 30.
+func (w WriteRecoveryConfig) EffectiveProbeInterval() int {
+	if w.ProbeInterval == 0 {
+		return 30
+	}
+	return w.ProbeInterval
+}
+
+// EffectiveMaxProbeInterval returns the backoff cap in seconds, defaulting to
+// 900 and never below the base interval.
+func (w WriteRecoveryConfig) EffectiveMaxProbeInterval() int {
+	limit := w.MaxProbeInterval
+	if limit == 0 {
+		limit = 900
+	}
+	return max(limit, w.EffectiveProbeInterval())
+}
+
+// EffectiveHealthyProbes returns how many consecutive healthy probes precede
+// a reclaim, defaulting to 3.
+func (w WriteRecoveryConfig) EffectiveHealthyProbes() int {
+	if w.HealthyProbes == 0 {
+		return 3
+	}
+	return w.HealthyProbes
+}
+
+// EffectiveMaxFlaps returns how many relapses are tolerated before the write
+// fail-safe stays sticky, defaulting to 3.
+func (w WriteRecoveryConfig) EffectiveMaxFlaps() int {
+	if w.MaxFlaps == 0 {
+		return 3
+	}
+	return w.MaxFlaps
+}
+
+// EffectiveStableWindow returns how long in seconds a reclaim must hold
+// before a new write fail-safe no longer counts as a relapse, defaulting to
+// 3600.
+func (w WriteRecoveryConfig) EffectiveStableWindow() int {
+	if w.StableWindow == 0 {
+		return 3600
+	}
+	return w.StableWindow
+}
+
+// DegradedConfig configures degraded-mode control. When enabled and one of
+// the CPU and GPU sources cannot be read (or is rejected as implausible) while
+// the other can, the controller keeps controlling on the healthy source and
+// holds the fans at least at the lost source's floor. Only a tick with no
+// usable source counts toward sensor_failure_limit. Disabled, losing either
+// source counts.
+type DegradedConfig struct {
+	Enabled  bool `yaml:"enabled" json:"enabled"`
+	CPUFloor int  `yaml:"cpu_floor" json:"cpu_floor"` // Fan % floor while the CPU source is lost (0 = 70)
+	GPUFloor int  `yaml:"gpu_floor" json:"gpu_floor"` // Fan % floor while the GPU source is lost (0 = 60)
+}
+
+// EffectiveCPUFloor returns the fan floor while the CPU source is lost,
+// defaulting to 70%.
+func (d DegradedConfig) EffectiveCPUFloor() int {
+	if d.CPUFloor == 0 {
+		return 70
+	}
+	return d.CPUFloor
+}
+
+// EffectiveGPUFloor returns the fan floor while the GPU source is lost,
+// defaulting to 60%.
+func (d DegradedConfig) EffectiveGPUFloor() int {
+	if d.GPUFloor == 0 {
+		return 60
+	}
+	return d.GPUFloor
+}
+
+// PIDConfig configures the PID control mode. The error term is measured
+// temperature minus setpoint (positive when too hot), so all gains are
+// non-negative. Output is a fan percentage added on top of idle_speed.
+type PIDConfig struct {
+	CPUSetpoint int     `yaml:"cpu_setpoint" json:"cpu_setpoint"` // Target CPU temp (°C); 0 = effective cpu_threshold
+	GPUSetpoint int     `yaml:"gpu_setpoint" json:"gpu_setpoint"` // Target GPU temp (°C); 0 = effective gpu_threshold
+	Kp          float64 `yaml:"kp" json:"kp"`                     // % fan per °C of error
+	Ki          float64 `yaml:"ki" json:"ki"`                     // % fan per °C·s of accumulated error
+	Kd          float64 `yaml:"kd" json:"kd"`                     // % fan per °C/s of temperature change
+	// IntegralLimit bounds the integral term's contribution (in % fan) so a long
+	// stretch above or below setpoint cannot wind it up past what the fans can
+	// actually deliver.
+	IntegralLimit float64 `yaml:"integral_limit" json:"integral_limit"`
+	// Slew limits cap how far the fan speed may move per control tick (%). 0
+	// means unlimited in that direction.
+	MaxStepUp   int `yaml:"max_step_up" json:"max_step_up"`
+	MaxStepDown int `yaml:"max_step_down" json:"max_step_down"`
+}
+
+// PredictiveConfig configures trend feed-forward. When enabled, a source whose
+// temperature trend predicts crossing its threshold within Horizon seconds is
+// pre-ramped as though it had already crossed, and ramp-down is held off while
+// any source is still rising by at least MinTrend °C/min. It is not available
+// in pid mode, whose derivative term (kd) already reacts to the rate of change.
+type PredictiveConfig struct {
+	Enabled  bool    `yaml:"enabled" json:"enabled"`
+	Horizon  int     `yaml:"horizon" json:"horizon"`     // Seconds of look-ahead for a predicted threshold crossing
+	MinTrend float64 `yaml:"min_trend" json:"min_trend"` // °C/min below which a rise is treated as noise
+}
+
+// EffectiveMinTrend returns the °C/min below which a rise is noise,
+// defaulting to 1.
+func (p PredictiveConfig) EffectiveMinTrend() float64 {
+	if p.MinTrend <= 0 {
+		return 1.0
+	}
+	return p.MinTrend
+}
+
+// AmbientConfig configures inlet compensation. When enabled, every inlet
+// degree above Reference lowers the CPU/GPU thresholds (and PID setpoints and
+// curve zones) by Gain degrees, up to MaxShift either way, so a hot room
+// starts the ramp earlier and a cold one later. The critical temperatures are
+// never shifted. With DeltaThreshold set, an exhaust-minus-inlet rise above it
+// is read as chassis load and raises the fan floor by DeltaStep per degree.
+//
+// The inlet and exhaust come from the BMC's "Inlet Temp"/"Exhaust Temp"
+// sensors, or from named sensors called inlet and exhaust when the BMC does
+// not report them (e.g. with monitoring.cpu_source: hwmon).
+type AmbientConfig struct {
+	Enabled        bool    `yaml:"enabled" json:"enabled"`
+	Reference      int     `yaml:"reference" json:"reference"`             // Inlet °C at which thresholds apply as configured (0 = 25)
+	Gain           float64 `yaml:"gain" json:"gain"`                       // Threshold shift per °C of inlet off reference (0 = 1)
+	MaxShift       int     `yaml:"max_shift" json:"max_shift"`             // Cap on the shift either way, °C (0 = 10)
+	DeltaThreshold int     `yaml:"delta_threshold" json:"delta_threshold"` // Exhaust-inlet °C above which fans are floored (0 = off)
+	DeltaStep      int     `yaml:"delta_step" json:"delta_step"`           // Fan % above idle per °C of delta over delta_threshold
+}
+
+// EffectiveReference returns the inlet reference temperature, defaulting to
+// 25°C.
+func (a AmbientConfig) EffectiveReference() int {
+	if a.Reference == 0 {
+		return 25
+	}
+	return a.Reference
+}
+
+// EffectiveGain returns the threshold shift per inlet degree, defaulting to 1.
+func (a AmbientConfig) EffectiveGain() float64 {
+	if a.Gain == 0 {
+		return 1
+	}
+	return a.Gain
+}
+
+// EffectiveMaxShift returns the cap on the threshold shift, defaulting to
+// 10°C.
+func (a AmbientConfig) EffectiveMaxShift() int {
+	if a.MaxShift == 0 {
+		return 10
+	}
+	return a.MaxShift
+}
+
+// FanGroup is a bank of fans driven by its own sensors and curve, e.g. the
+// PCIe-side fans of an R740xd cooling passive GPUs while the CPU-side fans stay
+// quiet. The group's speed is interpolated along Zones (the top-level zones
+// when empty) from the hottest of its Sources, whatever fan_control.mode is:
+// the mode drives only the fans not listed in any group. The critical ramp,
+// manual override, hint floor and min/max clamp apply to every group.
+type FanGroup struct {
+	Name    string   `yaml:"name" json:"name"`
+	Fans    []int    `yaml:"fans" json:"fans"`       // iDRAC fan indexes (0-based), as in "raw 0x30 0x30 0x02 <fan> <speed>"
+	Sources []string `yaml:"sources" json:"sources"` // "cpu" and/or "gpu"
+	Zones   []Zone   `yaml:"zones" json:"zones"`     // This group's fan curve; empty = the top-level zones
+}
+
+// Temperature sources a fan group can follow.
+const (
+	SourceCPU = "cpu"
+	SourceGPU = "gpu"
+)
+
 type APIConfig struct {
 	Host string `yaml:"host"`
 	Port int    `yaml:"port"`
@@ -129,6 +879,40 @@ func (fc FanControlConfig) EffectiveGPUThreshold() int {
 	return defaultGPUThreshold
 }
 
+// Control strategies accepted by fan_control.mode.
+const (
+	ModeStep  = "step"
+	ModePID   = "pid"
+	ModeCurve = "curve"
+)
+
+// EffectiveMode returns the control strategy actually in force, treating an
+// unset mode as the step ramp.
+func (fc FanControlConfig) EffectiveMode() string {
+	if fc.Mode == "" {
+		return ModeStep
+	}
+	return fc.Mode
+}
+
+// EffectiveCPUSetpoint returns the PID CPU setpoint, falling back to the
+// effective CPU threshold when unset.
+func (fc FanControlConfig) EffectiveCPUSetpoint() int {
+	if fc.PID.CPUSetpoint > 0 {
+		return fc.PID.CPUSetpoint
+	}
+	return fc.EffectiveCPUThreshold()
+}
+
+// EffectiveGPUSetpoint returns the PID GPU setpoint, falling back to the
+// effective GPU threshold when unset.
+func (fc FanControlConfig) EffectiveGPUSetpoint() int {
+	if fc.PID.GPUSetpoint > 0 {
+		return fc.PID.GPUSetpoint
+	}
+	return fc.EffectiveGPUThreshold()
+}
+
 // Load reads configuration from a YAML file
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -151,6 +935,44 @@ func Load(path string) (*Config, error) {
 	return cfg, nil
 }
 
+// Reload merges next, a freshly loaded config, into c for a running process.
+// Only fan_control and zones are read afresh by the control loop; the other
+// sections were used to set up the BMC connection, monitors, API server, MQTT
+// bridge and store, so merged keeps c's values for them and restart names the
+// ones that differ, which need a restart to take effect. Fan readback is set
+// up at startup too, so fan_control.verify.enabled is kept the same way.
+// merged still needs validating: its sections come from two configs.
+func (c *Config) Reload(next *Config) (merged *Config, restart []string) {
+	merged = new(Config)
+	*merged = *c
+	merged.FanControl = next.FanControl
+	merged.Zones = next.Zones
+
+	if next.FanControl.Verify.Enabled != c.FanControl.Verify.Enabled {
+		merged.FanControl.Verify.Enabled = c.FanControl.Verify.Enabled
+		restart = append(restart, "fan_control.verify.enabled")
+	}
+	for _, s := range []struct {
+		name      string
+		cur, next any
+	}{
+		{"idrac", c.IDRAC, next.IDRAC},
+		{"actuator", c.Actuator, next.Actuator},
+		{"monitoring", c.Monitoring, next.Monitoring},
+		{"gpu", c.GPU, next.GPU},
+		{"sensors", c.Sensors, next.Sensors},
+		{"api", c.API, next.API},
+		{"dashboard", c.Dashboard, next.Dashboard},
+		{"storage", c.Storage, next.Storage},
+		{"mqtt", c.MQTT, next.MQTT},
+	} {
+		if !reflect.DeepEqual(s.cur, s.next) {
+			restart = append(restart, s.name)
+		}
+	}
+	return merged, restart
+}
+
 // Validate checks that the safety-critical parts of the config are sane. It is
 // intentionally strict about the fields that drive the emergency ramp and the
 // fail-safe thresholds; an invalid config is rejected by Load so main falls back
@@ -179,13 +1001,102 @@ func (c *Config) Validate() error {
 	if gpuT := fc.EffectiveGPUThreshold(); fc.CriticalGPUTemp <= gpuT {
 		return fmt.Errorf("critical_gpu_temp (%d) must exceed effective gpu_threshold (%d)", fc.CriticalGPUTemp, gpuT)
 	}
-	// Zones are informational (dashboard display), but if provided they must be
-	// monotonic non-decreasing so the display stays coherent.
-	for i := 1; i < len(c.Zones); i++ {
-		prev, cur := c.Zones[i-1], c.Zones[i]
-		if cur.CPUMax < prev.CPUMax || cur.GPUMax < prev.GPUMax || cur.FanSpeed < prev.FanSpeed {
-			return fmt.Errorf("zones must be monotonic non-decreasing; zone %q breaks ordering", cur.Name)
+	switch fc.EffectiveMode() {
+	case ModeStep:
+	case ModePID:
+		if err := fc.validatePID(); err != nil {
+			return err
+		}
+	case ModeCurve:
+		if err := c.validateCurve(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid fan_control.mode %q (require %q, %q or %q)", fc.Mode, ModeStep, ModePID, ModeCurve)
+	}
+	if p := fc.Predictive; p.Enabled {
+		if fc.EffectiveMode() == ModePID {
+			return fmt.Errorf("fan_control.predictive is not supported in pid mode (use pid.kd for rate-of-change response)")
 		}
+		if p.Horizon <= 0 || p.Horizon > 600 {
+			return fmt.Errorf("invalid fan_control.predictive.horizon: %d (require 1..600 seconds)", p.Horizon)
+		}
+		if p.MinTrend <= 0 {
+			return fmt.Errorf("invalid fan_control.predictive.min_trend: %g (require > 0)", p.MinTrend)
+		}
+	}
+	if fc.SafeSpeed != 0 && (fc.SafeSpeed < fc.MinSpeed || fc.SafeSpeed > fc.MaxSpeed) {
+		return fmt.Errorf("invalid fan_control.safe_speed: %d (require 0 (off) or min_speed..max_speed = %d..%d)", fc.SafeSpeed, fc.MinSpeed, fc.MaxSpeed)
+	}
+	if fc.SafeSpeedFailures < 0 {
+		return fmt.Errorf("invalid fan_control.safe_speed_failures: %d (require >= 0)", fc.SafeSpeedFailures)
+	}
+	if w := fc.WriteRecovery; w.ProbeInterval < 0 || w.MaxProbeInterval < 0 || w.HealthyProbes < 0 || w.MaxFlaps < 0 || w.StableWindow < 0 {
+		return fmt.Errorf("invalid fan_control.write_recovery: probe_interval=%d max_probe_interval=%d healthy_probes=%d max_flaps=%d stable_window=%d (require >= 0)",
+			w.ProbeInterval, w.MaxProbeInterval, w.HealthyProbes, w.MaxFlaps, w.StableWindow)
+	}
+	if err := c.validateFanVerify(); err != nil {
+		return err
+	}
+	if err := c.validateModeWatch(); err != nil {
+		return err
+	}
+	if d := fc.Degraded; d.CPUFloor < 0 || d.CPUFloor > 100 || d.GPUFloor < 0 || d.GPUFloor > 100 {
+		return fmt.Errorf("invalid fan_control.degraded floors: cpu_floor=%d gpu_floor=%d (require 0..100)", d.CPUFloor, d.GPUFloor)
+	}
+	if err := c.validateAmbient(); err != nil {
+		return err
+	}
+	// Zones drive the fan curve in curve mode and are dashboard display
+	// otherwise. Either way, if provided they must be monotonic non-decreasing so
+	// the curve (and the display) stays coherent.
+	if err := checkZoneOrder(c.Zones); err != nil {
+		return err
+	}
+	if err := c.validateGroups(); err != nil {
+		return err
+	}
+	if err := c.validateActuator(); err != nil {
+		return err
+	}
+	if err := c.validateCPUSource(); err != nil {
+		return err
+	}
+	if err := c.validateSDRMap(); err != nil {
+		return err
+	}
+	if err := c.validateSensors(); err != nil {
+		return err
+	}
+	switch c.GPU.EffectiveBackend() {
+	case GPUBackendAuto, GPUBackendNvidia, GPUBackendROCm, GPUBackendHwmon:
+	default:
+		return fmt.Errorf("invalid gpu.backend %q (want auto, nvidia, rocm or hwmon)", c.GPU.Backend)
+	}
+	if err := c.validateGPUDevices(); err != nil {
+		return err
+	}
+	if b := c.GPU.EffectiveBackend(); c.GPU.Persistent && b != GPUBackendAuto && b != GPUBackendNvidia {
+		return fmt.Errorf("gpu.persistent requires the nvidia backend (gpu.backend is %q)", b)
+	}
+	switch c.IDRAC.EffectiveTransport() {
+	case TransportNative, TransportIPMItool:
+	default:
+		return fmt.Errorf("invalid idrac.transport %q (want native or ipmitool)", c.IDRAC.Transport)
+	}
+	// The trend window must fit inside the in-memory history, or the fit
+	// silently runs on less data than the operator asked for.
+	if w := c.Monitoring.TrendWindow; w < 0 || (c.Monitoring.HistoryRetention > 0 && w > c.Monitoring.HistoryRetention) {
+		return fmt.Errorf("invalid monitoring.trend_window: %d (require 0..history_retention=%d)", w, c.Monitoring.HistoryRetention)
+	}
+	if a := c.Monitoring.TrendSmoothing; a < 0 || a >= 1 {
+		return fmt.Errorf("invalid monitoring.trend_smoothing: %g (require 0 <= value < 1)", a)
+	}
+	if p := c.Monitoring.Plausibility; p.StuckTicks < 0 || p.StuckTicks == 1 {
+		return fmt.Errorf("invalid monitoring.plausibility.stuck_ticks: %d (require 0 or >= 2)", p.StuckTicks)
+	}
+	if p := c.Monitoring.Plausibility; p.MaxSlew < 0 {
+		return fmt.Errorf("invalid monitoring.plausibility.max_slew: %g (require >= 0)", p.MaxSlew)
 	}
 	if c.Storage.RetentionDays <= 0 {
 		return fmt.Errorf("invalid storage.retention_days: %d (require > 0)", c.Storage.RetentionDays)
@@ -217,6 +1128,391 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// validatePID checks the PID tuning. Setpoints must sit below the critical
+// trigger (otherwise the loop would happily regulate into the emergency ramp),
+// gains must be non-negative with at least one non-zero, and the windup/slew
+// limits must not be negative.
+func (fc FanControlConfig) validatePID() error {
+	p := fc.PID
+	if p.Kp < 0 || p.Ki < 0 || p.Kd < 0 {
+		return fmt.Errorf("invalid fan_control.pid gains: kp=%g ki=%g kd=%g (require >= 0)", p.Kp, p.Ki, p.Kd)
+	}
+	if p.Kp == 0 && p.Ki == 0 && p.Kd == 0 {
+		return fmt.Errorf("fan_control.pid gains are all zero; the PID loop would never move the fans")
+	}
+	if p.IntegralLimit < 0 || p.MaxStepUp < 0 || p.MaxStepDown < 0 {
+		return fmt.Errorf("invalid fan_control.pid limits: integral_limit=%g max_step_up=%d max_step_down=%d (require >= 0)",
+			p.IntegralLimit, p.MaxStepUp, p.MaxStepDown)
+	}
+	if p.CPUSetpoint < 0 || p.GPUSetpoint < 0 {
+		return fmt.Errorf("invalid fan_control.pid setpoints: cpu=%d gpu=%d (require >= 0)", p.CPUSetpoint, p.GPUSetpoint)
+	}
+	if sp := fc.EffectiveCPUSetpoint(); sp >= fc.CriticalCPUTemp {
+		return fmt.Errorf("fan_control.pid cpu setpoint (%d) must be below critical_cpu_temp (%d)", sp, fc.CriticalCPUTemp)
+	}
+	if sp := fc.EffectiveGPUSetpoint(); sp >= fc.CriticalGPUTemp {
+		return fmt.Errorf("fan_control.pid gpu setpoint (%d) must be below critical_gpu_temp (%d)", sp, fc.CriticalGPUTemp)
+	}
+	return nil
+}
+
+// validateCurve checks the zones are usable as fan-curve points. The ordering
+// check in Validate applies on top of this. The curve never decides the
+// emergency ramp (that is IsCritical alone), so a low top zone is allowed —
+// it only means fans stop climbing at that speed until the critical trigger.
+func (c *Config) validateCurve() error {
+	if len(c.Zones) == 0 {
+		return fmt.Errorf("fan_control.mode is %q but no zones are configured", ModeCurve)
+	}
+	for _, z := range c.Zones {
+		if z.Name == "" {
+			return fmt.Errorf("zones used as a fan curve must be named")
+		}
+		if z.FanSpeed < 0 || z.FanSpeed > 100 {
+			return fmt.Errorf("invalid fan_speed %d in zone %q (require 0..100)", z.FanSpeed, z.Name)
+		}
+	}
+	return nil
+}
+
+// checkZoneOrder requires zones to be monotonic non-decreasing in every column.
+func checkZoneOrder(zones []Zone) error {
+	for i := 1; i < len(zones); i++ {
+		prev, cur := zones[i-1], zones[i]
+		if cur.CPUMax < prev.CPUMax || cur.GPUMax < prev.GPUMax || cur.FanSpeed < prev.FanSpeed {
+			return fmt.Errorf("zones must be monotonic non-decreasing; zone %q breaks ordering", cur.Name)
+		}
+	}
+	return nil
+}
+
+// validateGroups checks the fan groups. Each needs a unique name, at least one
+// fan and one known source, and a usable curve; a fan may belong to only one
+// group, or two groups would fight over it every tick.
+func (c *Config) validateGroups() error {
+	if len(c.FanControl.Groups) == 0 {
+		return nil
+	}
+	if n := c.FanControl.FanCount; n < 1 || n > 0xff {
+		return fmt.Errorf("invalid fan_control.fan_count: %d (require 1..255 when fan groups are configured)", n)
+	}
+	names := make(map[string]bool)
+	owner := make(map[int]string)
+	for _, g := range c.FanControl.Groups {
+		if g.Name == "" {
+			return fmt.Errorf("fan_control.groups entries must be named")
+		}
+		if names[g.Name] {
+			return fmt.Errorf("duplicate fan group %q", g.Name)
+		}
+		names[g.Name] = true
+		if len(g.Fans) == 0 {
+			return fmt.Errorf("fan group %q has no fans", g.Name)
+		}
+		for _, fan := range g.Fans {
+			if fan < 0 || fan >= c.FanControl.FanCount {
+				return fmt.Errorf("invalid fan %d in fan group %q (require 0..fan_count-1=%d)", fan, g.Name, c.FanControl.FanCount-1)
+			}
+			if other, ok := owner[fan]; ok {
+				return fmt.Errorf("fan %d is in both fan group %q and %q", fan, other, g.Name)
+			}
+			owner[fan] = g.Name
+		}
+		if len(g.Sources) == 0 {
+			return fmt.Errorf("fan group %q has no sources (require %q and/or %q)", g.Name, SourceCPU, SourceGPU)
+		}
+		followsCPU := false
+		for _, src := range g.Sources {
+			if src != SourceCPU && src != SourceGPU {
+				return fmt.Errorf("invalid source %q in fan group %q (require %q or %q)", src, g.Name, SourceCPU, SourceGPU)
+			}
+			followsCPU = followsCPU || src == SourceCPU
+		}
+		// With GPU monitoring off, a GPU-only group would sit at the bottom of its
+		// curve no matter how hot the cards run.
+		if !followsCPU && !c.GPU.Enabled {
+			return fmt.Errorf("fan group %q follows only %q but gpu.enabled is false", g.Name, SourceGPU)
+		}
+		zones := c.GroupZones(g)
+		if len(zones) == 0 {
+			return fmt.Errorf("fan group %q has no zones and no top-level zones are configured", g.Name)
+		}
+		for _, z := range zones {
+			if z.FanSpeed < 0 || z.FanSpeed > 100 {
+				return fmt.Errorf("invalid fan_speed %d in fan group %q zone %q (require 0..100)", z.FanSpeed, g.Name, z.Name)
+			}
+		}
+		if err := checkZoneOrder(g.Zones); err != nil {
+			return fmt.Errorf("fan group %q: %w", g.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateCPUSource checks the CPU temperature source. hwmon sensor entries
+// need a chip pattern, and both patterns must be valid: a bad pattern would
+// otherwise just match nothing and surface as a sensor loss at runtime.
+func (c *Config) validateCPUSource() error {
+	switch c.Monitoring.EffectiveCPUSource() {
+	case CPUSourceIPMI:
+		return nil
+	case CPUSourceHwmon:
+	default:
+		return fmt.Errorf("invalid monitoring.cpu_source %q (want ipmi or hwmon)", c.Monitoring.CPUSource)
+	}
+	for i, s := range c.Monitoring.Hwmon.Sensors {
+		if s.Chip == "" {
+			return fmt.Errorf("monitoring.hwmon.sensors[%d]: chip is required", i)
+		}
+		for _, p := range []string{s.Chip, s.Label} {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("monitoring.hwmon.sensors[%d]: bad pattern %q: %w", i, p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateSDRMap checks the BMC sensor map: a known profile, and entries with
+// a valid role and regular expression that match on something.
+func (c *Config) validateSDRMap() error {
+	if p := c.Monitoring.SDRProfile; p != "" {
+		if _, ok := SDRProfiles[strings.ToLower(p)]; !ok {
+			names := make([]string, 0, len(SDRProfiles))
+			for name := range SDRProfiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown monitoring.sdr_profile %q (want one of %s)", p, strings.Join(names, ", "))
+		}
+	}
+	for i, e := range c.Monitoring.SDRMap {
+		switch _, cpu := SDRCPUSocket(e.Role); {
+		case cpu, e.Role == SDRRoleInlet, e.Role == SDRRoleExhaust, e.Role == SDRRoleIgnore:
+		default:
+			return fmt.Errorf("monitoring.sdr_map[%d]: invalid role %q (want cpu, cpu0..cpu9, inlet, exhaust or ignore)", i, e.Role)
+		}
+		if e.Name == "" && e.Entity == "" && e.Number == nil {
+			return fmt.Errorf("monitoring.sdr_map[%d]: set name, entity or number", i)
+		}
+		if _, err := regexp.Compile(e.Name); err != nil {
+			return fmt.Errorf("monitoring.sdr_map[%d]: bad name pattern: %w", i, err)
+		}
+		if e.Number != nil && (*e.Number < 0 || *e.Number > 255) {
+			return fmt.Errorf("monitoring.sdr_map[%d]: invalid number %d (require 0..255)", i, *e.Number)
+		}
+	}
+	return nil
+}
+
+// validateAmbient checks inlet compensation. Only the ipmi CPU source reads
+// the BMC's inlet sensor, so with hwmon a named inlet sensor is required.
+func (c *Config) validateAmbient() error {
+	a := c.FanControl.Ambient
+	if !a.Enabled {
+		return nil
+	}
+	if a.Reference < 0 || a.Reference > 50 {
+		return fmt.Errorf("invalid fan_control.ambient.reference: %d (require 0..50)", a.Reference)
+	}
+	if a.Gain < 0 || a.Gain > 5 {
+		return fmt.Errorf("invalid fan_control.ambient.gain: %g (require 0..5)", a.Gain)
+	}
+	if a.MaxShift < 0 || a.MaxShift > 30 {
+		return fmt.Errorf("invalid fan_control.ambient.max_shift: %d (require 0..30)", a.MaxShift)
+	}
+	if a.DeltaThreshold < 0 || a.DeltaStep < 0 {
+		return fmt.Errorf("invalid fan_control.ambient delta: delta_threshold=%d delta_step=%d (require >= 0)", a.DeltaThreshold, a.DeltaStep)
+	}
+	if a.DeltaThreshold > 0 && a.DeltaStep == 0 {
+		return fmt.Errorf("fan_control.ambient.delta_threshold is set but delta_step is 0")
+	}
+	if c.Monitoring.EffectiveCPUSource() != CPUSourceIPMI && !c.hasSensor("inlet") {
+		return fmt.Errorf("fan_control.ambient needs the BMC inlet sensor (cpu_source: ipmi) or a named sensor called inlet")
+	}
+	return nil
+}
+
+// validateFanVerify checks fan readback. The tachometers are read from the
+// BMC, so the hwmon backend, which has none, cannot verify its writes.
+func (c *Config) validateFanVerify() error {
+	v := c.FanControl.Verify
+	if !v.Enabled {
+		return nil
+	}
+	if v.MaxRPM < 0 || v.StallRPM < 0 || v.SettleTicks < 0 || v.FailureLimit < 0 {
+		return fmt.Errorf("invalid fan_control.verify: max_rpm=%d stall_rpm=%d settle_ticks=%d failure_limit=%d (require >= 0)",
+			v.MaxRPM, v.StallRPM, v.SettleTicks, v.FailureLimit)
+	}
+	if v.Tolerance < 0 || v.Tolerance >= 1 {
+		return fmt.Errorf("invalid fan_control.verify.tolerance: %g (require 0 <= tolerance < 1)", v.Tolerance)
+	}
+	if v.FailedRatio < 0 || v.FailedRatio >= 1 {
+		return fmt.Errorf("invalid fan_control.verify.failed_ratio: %g (require 0 <= failed_ratio < 1)", v.FailedRatio)
+	}
+	if v.Compensation < 0 || v.Compensation > 100 {
+		return fmt.Errorf("invalid fan_control.verify.compensation: %d (require 0-100)", v.Compensation)
+	}
+	if v.MaxRPM > 0 && v.EffectiveStallRPM() >= v.MaxRPM {
+		return fmt.Errorf("fan_control.verify.stall_rpm (%d) must be below max_rpm (%d)", v.EffectiveStallRPM(), v.MaxRPM)
+	}
+	if c.Actuator.EffectiveBackend() == BackendHwmon {
+		return fmt.Errorf("fan_control.verify reads the BMC's fan tachometers and is not available with actuator.backend %q", BackendHwmon)
+	}
+	return nil
+}
+
+// validateModeWatch checks fan_control.mode_watch. The Dell backend cannot
+// read the fan mode, so it needs the tachometers to infer it from.
+func (c *Config) validateModeWatch() error {
+	m := c.FanControl.ModeWatch
+	if !m.Enabled {
+		return nil
+	}
+	if m.Interval < 0 {
+		return fmt.Errorf("invalid fan_control.mode_watch.interval: %d (require >= 0)", m.Interval)
+	}
+	if v := c.FanControl.Verify; c.Actuator.EffectiveBackend() == BackendDell && (!v.Enabled || v.MaxRPM == 0) {
+		return fmt.Errorf("fan_control.mode_watch with actuator.backend %q infers the fan mode from the fan RPMs and needs fan_control.verify with max_rpm", BackendDell)
+	}
+	return nil
+}
+
+// hasSensor reports whether a named sensor called name is configured.
+func (c *Config) hasSensor(name string) bool {
+	for _, s := range c.Sensors {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSensors checks the named sensors. Names become JSON keys and MQTT
+// object ids, so they are restricted to [a-z0-9_], must be unique and cannot
+// shadow the built-in cpu and gpu sensors. A threshold needs a critical value
+// above it, which is where its ramp ends.
+func (c *Config) validateSensors() error {
+	seen := map[string]bool{"cpu": true, "gpu": true}
+	for i, s := range c.Sensors {
+		if s.Name == "" || strings.Trim(s.Name, "abcdefghijklmnopqrstuvwxyz0123456789_") != "" {
+			return fmt.Errorf("sensors[%d]: invalid name %q (require [a-z0-9_]+)", i, s.Name)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("sensors[%d]: name %q is already used", i, s.Name)
+		}
+		seen[s.Name] = true
+
+		var patterns []string
+		switch s.Source {
+		case SensorSourceIPMI:
+			if s.IPMIName == "" {
+				return fmt.Errorf("sensor %q: ipmi_name is required for source %q", s.Name, s.Source)
+			}
+			patterns = []string{s.IPMIName}
+		case SensorSourceHwmon:
+			if s.Hwmon.Chip == "" {
+				return fmt.Errorf("sensor %q: hwmon.chip is required for source %q", s.Name, s.Source)
+			}
+			patterns = []string{s.Hwmon.Chip, s.Hwmon.Label}
+		default:
+			return fmt.Errorf("sensor %q: invalid source %q (want ipmi or hwmon)", s.Name, s.Source)
+		}
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("sensor %q: bad pattern %q: %w", s.Name, p, err)
+			}
+		}
+
+		if s.Threshold < 0 || s.Threshold > 120 {
+			return fmt.Errorf("sensor %q: invalid threshold %d (require 0..120)", s.Name, s.Threshold)
+		}
+		// A critical that low would pin the fans at max_speed for good.
+		if s.Critical != 0 && (s.Critical < 40 || s.Critical > 120) {
+			return fmt.Errorf("sensor %q: invalid critical %d (require 0 (off) or 40..120)", s.Name, s.Critical)
+		}
+		if s.Threshold > 0 && s.Critical <= s.Threshold {
+			return fmt.Errorf("sensor %q: critical (%d) must exceed threshold (%d)", s.Name, s.Critical, s.Threshold)
+		}
+		if s.Weight < 0 {
+			return fmt.Errorf("sensor %q: invalid weight %g (require >= 0)", s.Name, s.Weight)
+		}
+	}
+	return nil
+}
+
+// validateGPUDevices checks the per-GPU overrides: each must match on
+// something, and its own threshold must stay below its own critical
+// temperature.
+func (c *Config) validateGPUDevices() error {
+	for i, d := range c.GPU.Devices {
+		if d.Index == nil && d.UUID == "" && d.Name == "" {
+			return fmt.Errorf("gpu.devices[%d]: set index, uuid or name", i)
+		}
+		if d.Index != nil && *d.Index < 0 {
+			return fmt.Errorf("gpu.devices[%d]: invalid index %d (require >= 0)", i, *d.Index)
+		}
+		if _, err := path.Match(d.Name, ""); err != nil {
+			return fmt.Errorf("gpu.devices[%d]: bad name pattern %q: %w", i, d.Name, err)
+		}
+		if d.Threshold < 0 || d.Threshold > 120 || d.Critical < 0 || d.Critical > 120 {
+			return fmt.Errorf("gpu.devices[%d]: invalid threshold=%d critical=%d (require 0..120)", i, d.Threshold, d.Critical)
+		}
+		if t, crit := d.EffectiveThreshold(c.FanControl), d.EffectiveCritical(c.FanControl); crit <= t {
+			return fmt.Errorf("gpu.devices[%d]: critical (%d) must exceed threshold (%d)", i, crit, t)
+		}
+		if d.Weight < 0 {
+			return fmt.Errorf("gpu.devices[%d]: invalid weight %g (require >= 0)", i, d.Weight)
+		}
+	}
+	return nil
+}
+
+// validateActuator checks the fan actuator backend. The hwmon backend needs a
+// device path and at least one distinct pwm channel, and with fan groups every
+// fan index must map onto a configured channel.
+func (c *Config) validateActuator() error {
+	switch c.Actuator.EffectiveBackend() {
+	case BackendDell, BackendSupermicro:
+		return nil
+	case BackendHwmon:
+	default:
+		return fmt.Errorf("invalid actuator.backend %q (require %q, %q or %q)",
+			c.Actuator.Backend, BackendDell, BackendSupermicro, BackendHwmon)
+	}
+	h := c.Actuator.Hwmon
+	if h.Path == "" {
+		return fmt.Errorf("actuator.backend is %q but actuator.hwmon.path is empty", BackendHwmon)
+	}
+	if len(h.Channels) == 0 {
+		return fmt.Errorf("actuator.backend is %q but actuator.hwmon.channels is empty", BackendHwmon)
+	}
+	seen := make(map[int]bool)
+	for _, ch := range h.Channels {
+		if ch < 1 || seen[ch] {
+			return fmt.Errorf("invalid actuator.hwmon.channels: %v (require distinct pwm numbers >= 1)", h.Channels)
+		}
+		seen[ch] = true
+	}
+	if h.AutoMode < 0 {
+		return fmt.Errorf("invalid actuator.hwmon.auto_mode: %d (require >= 0)", h.AutoMode)
+	}
+	if len(c.FanControl.Groups) > 0 && c.FanControl.FanCount > len(h.Channels) {
+		return fmt.Errorf("fan_control.fan_count (%d) exceeds the %d configured actuator.hwmon.channels",
+			c.FanControl.FanCount, len(h.Channels))
+	}
+	return nil
+}
+
+// GroupZones returns the curve a fan group follows: its own zones, or the
+// top-level zones when it has none.
+func (c *Config) GroupZones(g FanGroup) []Zone {
+	if len(g.Zones) > 0 {
+		return g.Zones
+	}
+	return c.Zones
+}
+
 // Default returns a configuration with sensible defaults
 func Default() *Config {
 	return &Config{
@@ -225,9 +1521,14 @@ func Default() *Config {
 			Username: "root", // Dell iDRAC default
 			Password: "",     // Must be set via config or IDRAC_PASSWORD env var
 		},
+		Actuator: ActuatorConfig{
+			Backend: BackendDell,
+		},
 		Monitoring: MonitoringConfig{
 			Interval:         10,
 			HistoryRetention: 3600,
+			TrendWindow:      60,
+			TrendSmoothing:   0,
 		},
 		GPU: GPUConfig{
 			Enabled:       true,
@@ -241,6 +1542,7 @@ func Default() *Config {
 			{Name: "critical", CPUMax: 999, GPUMax: 999, FanSpeed: 100},
 		},
 		FanControl: FanControlConfig{
+			Mode:               ModeStep, // Threshold/step ramp; "pid" for closed-loop control, "curve" to follow the zones
 			MinSpeed:           5,
 			MaxSpeed:           100,
 			IdleSpeed:          20, // Base fan speed when idle (quiet)
@@ -255,6 +1557,19 @@ func Default() *Config {
 			RampUpStep:         10,
 			RampDownStep:       5,
 			ConstantIdle:       true,
+			PID: PIDConfig{
+				Kp:            2.0,  // +2% fan per °C over setpoint
+				Ki:            0.02, // integral builds slowly so steady offset is trimmed without overshoot
+				Kd:            5.0,  // damp fast rises (°C/s is small, so this is a modest term)
+				IntegralLimit: 40,
+				MaxStepUp:     15,
+				MaxStepDown:   5,
+			},
+			Predictive: PredictiveConfig{
+				Enabled:  false,
+				Horizon:  60,  // Pre-ramp if the threshold is predicted within a minute
+				MinTrend: 1.0, // Ignore rises slower than 1°C/min
+			},
 		},
 		API: APIConfig{
 			Host: "0.0.0.0",
@@ -72,6 +72,41 @@ func TestMQTTPasswordNotMarshaled(t *testing.T) {
 	}
 }
 
+func TestResolvePasswordFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idrac_password")
+	if err := os.WriteFile(path, []byte("from-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	i := IDRACConfig{Password: "from-config", PasswordFile: path}
+	if err := i.ResolvePassword(); err != nil {
+		t.Fatalf("ResolvePassword: %v", err)
+	}
+	if i.Password != "from-secret" {
+		t.Fatalf("password = %q, want the file contents without the trailing newline", i.Password)
+	}
+
+	missing := IDRACConfig{PasswordFile: filepath.Join(t.TempDir(), "absent")}
+	if err := missing.ResolvePassword(); err == nil {
+		t.Fatal("an unreadable password_file must be an error, not an empty password")
+	}
+}
+
+func TestIpmitoolConnectionKeepsPasswordOutOfArgs(t *testing.T) {
+	i := IDRACConfig{Host: "10.0.0.5", Username: "root", Password: "s3cret"}
+	args, env := i.IpmitoolConnection()
+	if strings.Contains(strings.Join(args, " "), "s3cret") {
+		t.Fatalf("password in ipmitool args: %v", args)
+	}
+	if len(env) != 1 || env[0] != "IPMI_PASSWORD=s3cret" {
+		t.Fatalf("env = %v, want IPMI_PASSWORD", env)
+	}
+
+	args, env = IDRACConfig{Host: "local", Password: "s3cret"}.IpmitoolConnection()
+	if args != nil || env != nil {
+		t.Fatalf("local host got args %v env %v, want none", args, env)
+	}
+}
+
 // TestExampleConfigIsValid loads config.example.yaml verbatim (as a fresh
 // install would) and confirms it passes Validate(), so the shipped example
 // never silently bit-rots into a config the service refuses to start with.
@@ -85,6 +120,110 @@ func TestExampleConfigIsValid(t *testing.T) {
 	}
 }
 
+func TestReloadKeepsSectionsThatNeedRestart(t *testing.T) {
+	cur := Default()
+	next := Default()
+	next.FanControl.CPUThreshold = 70
+	next.Zones = next.Zones[:2]
+	next.API.Port = 9090
+	next.MQTT.Enabled = true
+	next.FanControl.Verify.Enabled = true
+
+	merged, restart := cur.Reload(next)
+	if merged.FanControl.CPUThreshold != 70 || len(merged.Zones) != 2 {
+		t.Fatalf("merged fan_control/zones = %d, %d zones; want the new ones", merged.FanControl.CPUThreshold, len(merged.Zones))
+	}
+	if merged.API.Port != cur.API.Port || merged.MQTT.Enabled || merged.FanControl.Verify.Enabled {
+		t.Fatalf("merged kept api.port=%d mqtt.enabled=%v verify.enabled=%v, want the running values",
+			merged.API.Port, merged.MQTT.Enabled, merged.FanControl.Verify.Enabled)
+	}
+	if got := strings.Join(restart, ","); got != "fan_control.verify.enabled,api,mqtt" {
+		t.Fatalf("restart = %q, want fan_control.verify.enabled,api,mqtt", got)
+	}
+	if cur.FanControl.CPUThreshold == 70 {
+		t.Fatal("Reload modified the running config")
+	}
+}
+
+func TestPatchMergesLiveSections(t *testing.T) {
+	cur := Default()
+	next, err := cur.Patch([]byte(`{"fan_control": {"cpu_threshold": 70, "verify": {"max_rpm": 12000}}}`))
+	if err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	if next.FanControl.CPUThreshold != 70 || next.FanControl.Verify.MaxRPM != 12000 || next.FanControl.GPUThreshold != cur.FanControl.GPUThreshold {
+		t.Fatalf("patched fan_control = %+v, want cpu_threshold and max_rpm changed and the rest kept", next.FanControl)
+	}
+	if cur.FanControl.CPUThreshold == 70 {
+		t.Fatal("Patch modified the running config")
+	}
+
+	changes, err := cur.Changes(next)
+	if err != nil {
+		t.Fatalf("Changes: %v", err)
+	}
+	if len(changes) != 2 || changes[0].Path != "fan_control.cpu_threshold" || changes[0].New != 70.0 ||
+		changes[1].Path != "fan_control.verify.max_rpm" {
+		t.Fatalf("changes = %+v, want cpu_threshold then verify.max_rpm", changes)
+	}
+
+	for _, patch := range []string{`{"api": {"port": 1}}`, `{"fan_control": {"cpu_treshold": 70}}`, `[]`, `null`} {
+		if _, err := cur.Patch([]byte(patch)); err == nil {
+			t.Errorf("Patch(%s) succeeded, want an error", patch)
+		}
+	}
+}
+
+func TestWriteLiveKeepsComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	orig := `# My fan controller
+idrac:
+  host: "local"  # direct access
+
+fan_control:
+  cpu_threshold: 65  # where the ramp starts
+  gpu_threshold: 70
+  verify:
+`
+	if err := os.WriteFile(path, []byte(orig), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	cur, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	next, err := cur.Patch([]byte(`{"fan_control": {"cpu_threshold": 72, "verify": {"max_rpm": 12000}}, "zones": [{"name": "all", "cpu_max": 100, "gpu_max": 100, "fan_speed": 60}]}`))
+	if err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	changes, err := cur.Changes(next)
+	if err != nil {
+		t.Fatalf("Changes: %v", err)
+	}
+	if err := WriteLive(path, next, changes); err != nil {
+		t.Fatalf("WriteLive: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# My fan controller", "host: \"local\" # direct access", "cpu_threshold: 72 # where the ramp starts", "gpu_threshold: 70\n", "max_rpm: 12000", "fan_speed: 60"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("written file lacks %q:\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "idle_speed") {
+		t.Errorf("written file gained unchanged settings:\n%s", data)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o640 {
+		t.Errorf("file mode = %v, %v; want 0640 kept", info.Mode(), err)
+	}
+	if reread, err := Load(path); err != nil || reread.FanControl.CPUThreshold != 72 || reread.FanControl.Verify.MaxRPM != 12000 || len(reread.Zones) != 1 {
+		t.Fatalf("reloaded config = %+v, %v; want the written changes", reread, err)
+	}
+}
+
 // TestLegacyLoggingSectionIsIgnored confirms that removing the logging config
 // struct doesn't break loading of older config files that still have a
 // (now-unused) `logging:` section: yaml.v3 ignores unknown keys, so this
@@ -165,6 +304,504 @@ func TestValidate(t *testing.T) {
 			mutate:  func(c *Config) { c.FanControl.MinSpeed = 90; c.FanControl.MaxSpeed = 80 },
 			wantErr: true,
 		},
+		{
+			name:    "unknown fan_control mode is rejected",
+			mutate:  func(c *Config) { c.FanControl.Mode = "bang-bang" },
+			wantErr: true,
+		},
+		{
+			name:    "pid mode with default tuning is valid",
+			mutate:  func(c *Config) { c.FanControl.Mode = ModePID },
+			wantErr: false,
+		},
+		{
+			name: "pid mode with negative gain is rejected",
+			mutate: func(c *Config) {
+				c.FanControl.Mode = ModePID
+				c.FanControl.PID.Kp = -1
+			},
+			wantErr: true,
+		},
+		{
+			name: "pid mode with all-zero gains is rejected",
+			mutate: func(c *Config) {
+				c.FanControl.Mode = ModePID
+				c.FanControl.PID.Kp, c.FanControl.PID.Ki, c.FanControl.PID.Kd = 0, 0, 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "pid setpoint at/above critical is rejected",
+			mutate: func(c *Config) {
+				c.FanControl.Mode = ModePID
+				c.FanControl.PID.CPUSetpoint = c.FanControl.CriticalCPUTemp
+			},
+			wantErr: true,
+		},
+		{
+			name:    "curve mode with default zones is valid",
+			mutate:  func(c *Config) { c.FanControl.Mode = ModeCurve },
+			wantErr: false,
+		},
+		{
+			name: "curve mode without zones is rejected",
+			mutate: func(c *Config) {
+				c.FanControl.Mode = ModeCurve
+				c.Zones = nil
+			},
+			wantErr: true,
+		},
+		{
+			name: "curve mode with out-of-range zone speed is rejected",
+			mutate: func(c *Config) {
+				c.FanControl.Mode = ModeCurve
+				c.Zones[len(c.Zones)-1].FanSpeed = 150
+			},
+			wantErr: true,
+		},
+		{
+			name:    "predictive with default horizon is valid",
+			mutate:  func(c *Config) { c.FanControl.Predictive.Enabled = true },
+			wantErr: false,
+		},
+		{
+			name: "predictive with zero horizon is rejected",
+			mutate: func(c *Config) {
+				c.FanControl.Predictive.Enabled = true
+				c.FanControl.Predictive.Horizon = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "predictive in pid mode is rejected",
+			mutate: func(c *Config) {
+				c.FanControl.Mode = ModePID
+				c.FanControl.Predictive.Enabled = true
+			},
+			wantErr: true,
+		},
+		{
+			name:    "trend window beyond history retention is rejected",
+			mutate:  func(c *Config) { c.Monitoring.TrendWindow = c.Monitoring.HistoryRetention + 1 },
+			wantErr: true,
+		},
+		{
+			name:    "trend smoothing of 1 is rejected",
+			mutate:  func(c *Config) { c.Monitoring.TrendSmoothing = 1 },
+			wantErr: true,
+		},
+		{
+			name:    "trend smoothing within range is valid",
+			mutate:  func(c *Config) { c.Monitoring.TrendSmoothing = 0.3 },
+			wantErr: false,
+		},
+		{
+			name: "safe speed stage is valid",
+			mutate: func(c *Config) {
+				c.FanControl.SafeSpeed = 60
+				c.FanControl.SafeSpeedFailures = 6
+			},
+			wantErr: false,
+		},
+		{
+			name: "safe speed above max_speed is rejected",
+			mutate: func(c *Config) {
+				c.FanControl.MaxSpeed = 80
+				c.FanControl.SafeSpeed = 90
+			},
+			wantErr: true,
+		},
+		{
+			name: "fan verification is valid",
+			mutate: func(c *Config) {
+				c.FanControl.Verify = FanVerifyConfig{Enabled: true, MaxRPM: 15000, Tolerance: 0.3}
+			},
+			wantErr: false,
+		},
+		{
+			name: "fan verification with stall_rpm above max_rpm is rejected",
+			mutate: func(c *Config) {
+				c.FanControl.Verify = FanVerifyConfig{Enabled: true, MaxRPM: 200}
+			},
+			wantErr: true,
+		},
+		{
+			name: "fan failure compensation above 100 is rejected",
+			mutate: func(c *Config) {
+				c.FanControl.Verify = FanVerifyConfig{Enabled: true, Compensation: 120}
+			},
+			wantErr: true,
+		},
+		{
+			name: "mode watch with fan verification is valid",
+			mutate: func(c *Config) {
+				c.FanControl.ModeWatch = ModeWatchConfig{Enabled: true, Interval: 30}
+				c.FanControl.Verify = FanVerifyConfig{Enabled: true, MaxRPM: 15000}
+			},
+			wantErr: false,
+		},
+		{
+			name:    "mode watch on dell without max_rpm is rejected",
+			mutate:  func(c *Config) { c.FanControl.ModeWatch.Enabled = true },
+			wantErr: true,
+		},
+		{
+			name: "write recovery is valid",
+			mutate: func(c *Config) {
+				c.FanControl.WriteRecovery = WriteRecoveryConfig{Enabled: true, ProbeInterval: 60, HealthyProbes: 5}
+			},
+			wantErr: false,
+		},
+		{
+			name:    "negative write recovery flap limit is rejected",
+			mutate:  func(c *Config) { c.FanControl.WriteRecovery.MaxFlaps = -1 },
+			wantErr: true,
+		},
+		{
+			name: "degraded mode is valid",
+			mutate: func(c *Config) {
+				c.FanControl.Degraded = DegradedConfig{Enabled: true, CPUFloor: 80, GPUFloor: 50}
+			},
+			wantErr: false,
+		},
+		{
+			name:    "degraded floor above 100 is rejected",
+			mutate:  func(c *Config) { c.FanControl.Degraded.GPUFloor = 110 },
+			wantErr: true,
+		},
+		{
+			name: "plausibility checks are valid",
+			mutate: func(c *Config) {
+				c.Monitoring.Plausibility = PlausibilityConfig{StuckTicks: 360, MaxSlew: 3, Median: true}
+			},
+			wantErr: false,
+		},
+		{
+			name:    "a stuck window of one tick is rejected",
+			mutate:  func(c *Config) { c.Monitoring.Plausibility.StuckTicks = 1 },
+			wantErr: true,
+		},
+		{
+			name:    "negative max slew is rejected",
+			mutate:  func(c *Config) { c.Monitoring.Plausibility.MaxSlew = -1 },
+			wantErr: true,
+		},
+		{
+			name: "fan groups with fan_count are valid",
+			mutate: func(c *Config) {
+				c.FanControl.FanCount = 6
+				c.FanControl.Groups = []FanGroup{
+					{Name: "cpu", Fans: []int{0, 1, 2}, Sources: []string{SourceCPU}},
+					{Name: "pcie", Fans: []int{3, 4, 5}, Sources: []string{SourceGPU, SourceCPU}},
+				}
+			},
+			wantErr: false,
+		},
+		{
+			name: "fan groups without fan_count are rejected",
+			mutate: func(c *Config) {
+				c.FanControl.Groups = []FanGroup{{Name: "cpu", Fans: []int{0}, Sources: []string{SourceCPU}}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "fan beyond fan_count is rejected",
+			mutate: func(c *Config) {
+				c.FanControl.FanCount = 6
+				c.FanControl.Groups = []FanGroup{{Name: "cpu", Fans: []int{6}, Sources: []string{SourceCPU}}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "fan in two groups is rejected",
+			mutate: func(c *Config) {
+				c.FanControl.FanCount = 6
+				c.FanControl.Groups = []FanGroup{
+					{Name: "cpu", Fans: []int{0, 1}, Sources: []string{SourceCPU}},
+					{Name: "pcie", Fans: []int{1, 2}, Sources: []string{SourceGPU}},
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "fan group with unknown source is rejected",
+			mutate: func(c *Config) {
+				c.FanControl.FanCount = 6
+				c.FanControl.Groups = []FanGroup{{Name: "cpu", Fans: []int{0}, Sources: []string{"inlet"}}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "gpu-only fan group with gpu disabled is rejected",
+			mutate: func(c *Config) {
+				c.GPU.Enabled = false
+				c.FanControl.FanCount = 6
+				c.FanControl.Groups = []FanGroup{{Name: "pcie", Fans: []int{3}, Sources: []string{SourceGPU}}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "fan group with non-monotonic zones is rejected",
+			mutate: func(c *Config) {
+				c.FanControl.FanCount = 6
+				c.FanControl.Groups = []FanGroup{{Name: "pcie", Fans: []int{3}, Sources: []string{SourceGPU}, Zones: []Zone{
+					{Name: "a", GPUMax: 60, FanSpeed: 50},
+					{Name: "b", GPUMax: 70, FanSpeed: 40},
+				}}}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "supermicro backend is valid",
+			mutate:  func(c *Config) { c.Actuator.Backend = BackendSupermicro },
+			wantErr: false,
+		},
+		{
+			name:    "unknown backend is rejected",
+			mutate:  func(c *Config) { c.Actuator.Backend = "hpe" },
+			wantErr: true,
+		},
+		{
+			name: "hwmon backend with path and channels is valid",
+			mutate: func(c *Config) {
+				c.Actuator = ActuatorConfig{Backend: BackendHwmon, Hwmon: HwmonActuatorConfig{Path: "/sys/class/hwmon/hwmon2", Channels: []int{1, 2}}}
+			},
+			wantErr: false,
+		},
+		{
+			name: "hwmon backend without channels is rejected",
+			mutate: func(c *Config) {
+				c.Actuator = ActuatorConfig{Backend: BackendHwmon, Hwmon: HwmonActuatorConfig{Path: "/sys/class/hwmon/hwmon2"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "hwmon fan groups beyond the channels are rejected",
+			mutate: func(c *Config) {
+				c.Actuator = ActuatorConfig{Backend: BackendHwmon, Hwmon: HwmonActuatorConfig{Path: "/sys/class/hwmon/hwmon2", Channels: []int{1, 2}}}
+				c.FanControl.FanCount = 3
+				c.FanControl.Groups = []FanGroup{{Name: "cpu", Fans: []int{2}, Sources: []string{SourceCPU}}}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "ipmitool transport is valid",
+			mutate:  func(c *Config) { c.IDRAC.Transport = TransportIPMItool },
+			wantErr: false,
+		},
+		{
+			name:    "unknown transport is rejected",
+			mutate:  func(c *Config) { c.IDRAC.Transport = "telnet" },
+			wantErr: true,
+		},
+		{
+			name: "hwmon cpu source with a label pattern is valid",
+			mutate: func(c *Config) {
+				c.Monitoring.CPUSource = CPUSourceHwmon
+				c.Monitoring.Hwmon.Sensors = []HwmonSensor{{Chip: "nvme", Label: "Composite"}}
+			},
+			wantErr: false,
+		},
+		{
+			name:    "unknown cpu source is rejected",
+			mutate:  func(c *Config) { c.Monitoring.CPUSource = "lm-sensors" },
+			wantErr: true,
+		},
+		{
+			name: "hwmon sensor without a chip is rejected",
+			mutate: func(c *Config) {
+				c.Monitoring.CPUSource = CPUSourceHwmon
+				c.Monitoring.Hwmon.Sensors = []HwmonSensor{{Label: "Tctl"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed hwmon label pattern is rejected",
+			mutate: func(c *Config) {
+				c.Monitoring.CPUSource = CPUSourceHwmon
+				c.Monitoring.Hwmon.Sensors = []HwmonSensor{{Chip: "coretemp", Label: "Package id ["}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "sdr profile and map are valid",
+			mutate: func(c *Config) {
+				c.Monitoring.SDRProfile = "R740"
+				number := 0x31
+				c.Monitoring.SDRMap = []SDRMapping{
+					{Name: "^SoC Temp$", Role: "cpu0"},
+					{Entity: "65", Number: &number, Role: SDRRoleCPU},
+					{Name: "(?i)dimm", Role: SDRRoleIgnore},
+				}
+			},
+			wantErr: false,
+		},
+		{
+			name:    "unknown sdr profile is rejected",
+			mutate:  func(c *Config) { c.Monitoring.SDRProfile = "r910" },
+			wantErr: true,
+		},
+		{
+			name:    "unknown sdr map role is rejected",
+			mutate:  func(c *Config) { c.Monitoring.SDRMap = []SDRMapping{{Name: "Temp", Role: "cpu10"}} },
+			wantErr: true,
+		},
+		{
+			name:    "malformed sdr map pattern is rejected",
+			mutate:  func(c *Config) { c.Monitoring.SDRMap = []SDRMapping{{Name: "CPU[", Role: SDRRoleCPU}} },
+			wantErr: true,
+		},
+		{
+			name:    "sdr map entry matching everything is rejected",
+			mutate:  func(c *Config) { c.Monitoring.SDRMap = []SDRMapping{{Role: SDRRoleIgnore}} },
+			wantErr: true,
+		},
+		{
+			name: "named ipmi and hwmon sensors are valid",
+			mutate: func(c *Config) {
+				c.Sensors = []SensorConfig{
+					{Name: "inlet", Source: SensorSourceIPMI, IPMIName: "Inlet Temp", Threshold: 30, Critical: 45},
+					{Name: "nvme0", Source: SensorSourceHwmon, Hwmon: HwmonSensor{Chip: "nvme", Label: "Composite"}, Threshold: 55, Critical: 75, Weight: 0.5},
+					{Name: "exhaust", Source: SensorSourceIPMI, IPMIName: "Exhaust Temp"},
+				}
+			},
+			wantErr: false,
+		},
+		{
+			name: "sensor named after a built-in is rejected",
+			mutate: func(c *Config) {
+				c.Sensors = []SensorConfig{{Name: "cpu", Source: SensorSourceIPMI, IPMIName: "Temp"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate sensor names are rejected",
+			mutate: func(c *Config) {
+				c.Sensors = []SensorConfig{
+					{Name: "inlet", Source: SensorSourceIPMI, IPMIName: "Inlet Temp"},
+					{Name: "inlet", Source: SensorSourceIPMI, IPMIName: "System Board Inlet Temp"},
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "sensor name outside [a-z0-9_] is rejected",
+			mutate: func(c *Config) {
+				c.Sensors = []SensorConfig{{Name: "Inlet Temp", Source: SensorSourceIPMI, IPMIName: "Inlet Temp"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "ipmi sensor without ipmi_name is rejected",
+			mutate: func(c *Config) {
+				c.Sensors = []SensorConfig{{Name: "inlet", Source: SensorSourceIPMI}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "sensor threshold without a critical above it is rejected",
+			mutate: func(c *Config) {
+				c.Sensors = []SensorConfig{{Name: "inlet", Source: SensorSourceIPMI, IPMIName: "Inlet Temp", Threshold: 30}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "sensor critical below 40 is rejected",
+			mutate: func(c *Config) {
+				c.Sensors = []SensorConfig{{Name: "inlet", Source: SensorSourceIPMI, IPMIName: "Inlet Temp", Critical: 5}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative sensor weight is rejected",
+			mutate: func(c *Config) {
+				c.Sensors = []SensorConfig{{Name: "inlet", Source: SensorSourceIPMI, IPMIName: "Inlet Temp", Threshold: 30, Critical: 45, Weight: -1}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "inlet compensation with a delta floor is valid",
+			mutate: func(c *Config) {
+				c.FanControl.Ambient = AmbientConfig{Enabled: true, Reference: 22, Gain: 0.5, DeltaThreshold: 15, DeltaStep: 2}
+			},
+			wantErr: false,
+		},
+		{
+			name: "ambient delta_threshold without delta_step is rejected",
+			mutate: func(c *Config) {
+				c.FanControl.Ambient = AmbientConfig{Enabled: true, DeltaThreshold: 15}
+			},
+			wantErr: true,
+		},
+		{
+			name: "excessive ambient max_shift is rejected",
+			mutate: func(c *Config) {
+				c.FanControl.Ambient = AmbientConfig{Enabled: true, MaxShift: 45}
+			},
+			wantErr: true,
+		},
+		{
+			name: "ambient with hwmon cpu source needs a named inlet sensor",
+			mutate: func(c *Config) {
+				c.Monitoring.CPUSource = CPUSourceHwmon
+				c.FanControl.Ambient.Enabled = true
+			},
+			wantErr: true,
+		},
+		{
+			name: "ambient with hwmon cpu source and a named inlet sensor is valid",
+			mutate: func(c *Config) {
+				c.Monitoring.CPUSource = CPUSourceHwmon
+				c.FanControl.Ambient.Enabled = true
+				c.Sensors = []SensorConfig{{Name: "inlet", Source: SensorSourceHwmon, Hwmon: HwmonSensor{Chip: "acpitz"}}}
+			},
+			wantErr: false,
+		},
+		{
+			name:    "rocm gpu backend is valid",
+			mutate:  func(c *Config) { c.GPU.Backend = GPUBackendROCm },
+			wantErr: false,
+		},
+		{
+			name:    "unknown gpu backend is rejected",
+			mutate:  func(c *Config) { c.GPU.Backend = "opencl" },
+			wantErr: true,
+		},
+		{
+			name:    "persistent nvidia reader is valid with auto detection",
+			mutate:  func(c *Config) { c.GPU.Persistent = true },
+			wantErr: false,
+		},
+		{
+			name: "per-GPU overrides are valid",
+			mutate: func(c *Config) {
+				idx := 1
+				c.GPU.Devices = []GPUDeviceConfig{
+					{Name: "NVIDIA H100*", Threshold: 80, Critical: 95},
+					{Index: &idx, Exclude: true},
+				}
+			},
+			wantErr: false,
+		},
+		{
+			name:    "per-GPU override matching nothing is rejected",
+			mutate:  func(c *Config) { c.GPU.Devices = []GPUDeviceConfig{{Threshold: 80}} },
+			wantErr: true,
+		},
+		{
+			// 95 against the default critical_gpu_temp of 90.
+			name:    "per-GPU threshold above its critical is rejected",
+			mutate:  func(c *Config) { c.GPU.Devices = []GPUDeviceConfig{{UUID: "GPU-1", Threshold: 95}} },
+			wantErr: true,
+		},
+		{
+			name: "persistent reader with the rocm backend is rejected",
+			mutate: func(c *Config) {
+				c.GPU.Backend = GPUBackendROCm
+				c.GPU.Persistent = true
+			},
+			wantErr: true,
+		},
 		{
 			name: "non-monotonic zones are rejected",
 			mutate: func(c *Config) {
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// liveSections are the sections a running process applies without a restart
+// (see Reload), named as in the YAML file and the API.
+type liveSections struct {
+	FanControl FanControlConfig `yaml:"fan_control" json:"fan_control"`
+	Zones      []Zone           `yaml:"zones" json:"zones"`
+}
+
+// Change is one setting that differs between two configs, by its dotted path
+// (fan_control.verify.max_rpm). Lists are compared whole, so a change to a
+// zone is the whole zones list.
+type Change struct {
+	Path string `json:"path"`
+	Old  any    `json:"old"`
+	New  any    `json:"new"`
+}
+
+// Patch applies patch, a JSON merge patch (RFC 7386) of fan_control and
+// zones, to a copy of c: objects merge key by key, anything else (lists
+// included) replaces the current value, and null clears it. Other sections
+// and unknown keys are rejected. The result still needs validating.
+func (c *Config) Patch(patch []byte) (*Config, error) {
+	var p map[string]any
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("invalid config patch: %w", err)
+	}
+	if p == nil {
+		return nil, fmt.Errorf("invalid config patch: want an object with fan_control and/or zones")
+	}
+	cur, err := c.liveTree()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(mergePatch(cur, p))
+	if err != nil {
+		return nil, err
+	}
+
+	var live liveSections
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&live); err != nil {
+		return nil, fmt.Errorf("invalid config patch: %w (only fan_control and zones can be changed at runtime)", err)
+	}
+	next := new(Config)
+	*next = *c
+	next.FanControl = live.FanControl
+	next.Zones = live.Zones
+	return next, nil
+}
+
+// Changes lists the fan_control and zones settings that differ from c in
+// next, sorted by path.
+func (c *Config) Changes(next *Config) ([]Change, error) {
+	cur, err := c.liveTree()
+	if err != nil {
+		return nil, err
+	}
+	nxt, err := next.liveTree()
+	if err != nil {
+		return nil, err
+	}
+	old, updated := make(map[string]any), make(map[string]any)
+	flatten("", cur, old)
+	flatten("", nxt, updated)
+
+	var changes []Change
+	for path, v := range updated {
+		if !reflect.DeepEqual(old[path], v) {
+			changes = append(changes, Change{Path: path, Old: old[path], New: v})
+		}
+	}
+	for path, v := range old {
+		if _, ok := updated[path]; !ok {
+			changes = append(changes, Change{Path: path, Old: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// liveTree is c's fan_control and zones as decoded JSON.
+func (c *Config) liveTree() (map[string]any, error) {
+	data, err := json.Marshal(liveSections{FanControl: c.FanControl, Zones: c.Zones})
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]any
+	err = json.Unmarshal(data, &tree)
+	return tree, err
+}
+
+// mergePatch applies the merge patch p to dst, which it modifies.
+func mergePatch(dst, p map[string]any) map[string]any {
+	for k, v := range p {
+		switch v := v.(type) {
+		case nil:
+			delete(dst, k)
+		case map[string]any:
+			sub, _ := dst[k].(map[string]any)
+			if sub == nil {
+				sub = make(map[string]any)
+			}
+			dst[k] = mergePatch(sub, v)
+		default:
+			dst[k] = v
+		}
+	}
+	return dst
+}
+
+// flatten records the leaves of the decoded JSON v in out by dotted path.
+func flatten(prefix string, v any, out map[string]any) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		out[prefix] = v
+		return
+	}
+	for k, sub := range m {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+		flatten(k, sub, out)
+	}
+}
+
+// WriteLive writes the changed settings from next into the YAML file at path,
+// leaving the rest of the file, comments included, as it is. A setting the
+// file lacks is added. The file is replaced atomically, by renaming a new file
+// in the same directory over it, so the directory must be writable.
+func WriteLive(path string, next *Config, changes []Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: the top level is not a mapping", path)
+	}
+	var from yaml.Node
+	if err := from.Encode(liveSections{FanControl: next.FanControl, Zones: next.Zones}); err != nil {
+		return err
+	}
+	for _, ch := range changes {
+		if err := setPath(root, &from, strings.Split(ch.Path, ".")); err != nil {
+			return fmt.Errorf("write %s: %w", ch.Path, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return replaceFile(path, buf.Bytes(), info.Mode().Perm())
+}
+
+// setPath sets the value at the dotted keys in the mapping m to the value at
+// the same keys in from, keeping the comments of the value it replaces.
+func setPath(m, from *yaml.Node, keys []string) error {
+	src := mappingValue(from, keys[0])
+	if src == nil {
+		return fmt.Errorf("no setting %q", keys[0])
+	}
+	dst := mappingValue(m, keys[0])
+	if dst == nil {
+		dst = &yaml.Node{Kind: yaml.MappingNode}
+		m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: keys[0]}, dst)
+	}
+	if len(keys) > 1 {
+		if dst.Kind != yaml.MappingNode {
+			// An empty section ("verify:") is null; it becomes a mapping.
+			*dst = yaml.Node{Kind: yaml.MappingNode, HeadComment: dst.HeadComment, LineComment: dst.LineComment, FootComment: dst.FootComment}
+		}
+		return setPath(dst, src, keys[1:])
+	}
+	head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+	*dst = *src
+	dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+	return nil
+}
+
+// mappingValue returns the value of key in the mapping m, nil when m is not a
+// mapping or lacks the key.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	if m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// replaceFile writes data to a temporary file next to path and renames it
+// over path, so a reader sees either the old file or the new one.
+func replaceFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
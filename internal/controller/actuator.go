@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+)
+
+// actuator writes fan speeds and hands control between the controller and the
+// platform's own fan management. The fail-safe state machine only ever talks
+// to it through enableManual (take control) and restoreAuto (give it back), so
+// each backend decides what those mean on its hardware.
+//
+// Every method must be safe to retry: restoreAuto in particular is re-issued
+// every tick until it succeeds.
+type actuator interface {
+	// name identifies the backend in Status and logs.
+	name() string
+	// enableManual takes fan control away from the BMC/chip.
+	enableManual() error
+	// restoreAuto hands fan control back to the BMC/chip.
+	restoreAuto() error
+	// setAll drives every fan at speed (0-100%).
+	setAll(speed int) error
+	// setFan drives a single fan (an index as used by fan_control.groups).
+	setFan(fan, speed int) error
+	// probe checks that the BMC/chip answers without changing any fan state;
+	// fan_control.write_recovery uses it while the BMC is in charge.
+	probe() error
+	// manualMode reads back whether the controller still has fan control;
+	// fan_control.mode_watch uses it to catch the BMC reverting to auto.
+	// Backends without a way to read it return errModeUnreadable without
+	// touching the BMC.
+	manualMode() (bool, error)
+}
+
+// errModeUnreadable is manualMode's answer on hardware that cannot report its
+// fan mode.
+var errModeUnreadable = errors.New("fan mode cannot be read back")
+
+// newActuator builds the configured backend. The IPMI backends send their raw
+// commands through fc.ipmiRaw, so they pick up the BMC transport and
+// connection settings, the command timeout and any test stub of runCommand.
+func newActuator(fc *FanController) actuator {
+	switch fc.cfg.Actuator.EffectiveBackend() {
+	case config.BackendSupermicro:
+		return supermicroActuator{raw: fc.ipmiRaw, read: fc.ipmiRawRead}
+	case config.BackendHwmon:
+		return newHwmonActuator(fc.cfg.Actuator.Hwmon)
+	default:
+		return dellActuator{raw: fc.ipmiRaw}
+	}
+}
+
+// rawFunc sends one raw IPMI request (network function, command, data bytes).
+type rawFunc func(netfn, cmd byte, data ...byte) error
+
+// rawReadFunc sends one raw IPMI request and returns the response data.
+type rawReadFunc func(netfn, cmd byte, data ...byte) ([]byte, error)
+
+// hexByte formats a value as an ipmitool raw argument.
+func hexByte(v int) string {
+	return fmt.Sprintf("0x%02x", v)
+}
+
+// IPMI network functions and commands used by the backends.
+const (
+	netfnApp         = 0x06
+	cmdGetDeviceID   = 0x01 // read-only; any healthy BMC answers it
+	netfnOEM         = 0x30
+	cmdDellFan       = 0x30
+	cmdSupermicroFan = 0x45
+	cmdSupermicroPWM = 0x70
+)
+
+// dellActuator drives Dell iDRAC fans with the 0x30 0x30 OEM commands:
+// 0x01 0x00/0x01 toggles manual/automatic control and 0x02 <fan|0xff> <speed>
+// sets a duty cycle. Restoring automatic control hands every fan back at once.
+type dellActuator struct {
+	raw rawFunc
+}
+
+func (dellActuator) name() string { return config.BackendDell }
+
+func (d dellActuator) enableManual() error {
+	return d.raw(netfnOEM, cmdDellFan, 0x01, 0x00)
+}
+
+func (d dellActuator) restoreAuto() error {
+	return d.raw(netfnOEM, cmdDellFan, 0x01, 0x01)
+}
+
+func (d dellActuator) setAll(speed int) error {
+	return d.raw(netfnOEM, cmdDellFan, 0x02, 0xff, byte(speed))
+}
+
+func (d dellActuator) setFan(fan, speed int) error {
+	return d.raw(netfnOEM, cmdDellFan, 0x02, byte(fan), byte(speed))
+}
+
+func (d dellActuator) probe() error {
+	return d.raw(netfnApp, cmdGetDeviceID)
+}
+
+// manualMode is unreadable: the iDRAC has no documented command to read the
+// 0x01 toggle back.
+func (dellActuator) manualMode() (bool, error) {
+	return false, errModeUnreadable
+}
+
+// Supermicro fan modes for raw 0x30 0x45 0x01 <mode>; raw 0x30 0x45 0x00
+// reads the current one back.
+const (
+	supermicroModeFull    = 0x01 // BMC stops adjusting duty cycles; ours stick
+	supermicroModeOptimal = 0x02 // BMC automatic control
+)
+
+// supermicroActuator drives Supermicro X10/X11-style BMCs. Manual control is
+// the BMC's "full" fan mode (otherwise it overwrites our duty cycles within
+// seconds) and the hand-back is "optimal" mode. Duty cycles are set per fan
+// zone with 0x30 0x70 0x66 0x01 <zone> <duty>; zone 0 is the CPU/system zone
+// and zone 1 the peripheral zone, and those are the fan indexes groups use.
+type supermicroActuator struct {
+	raw  rawFunc
+	read rawReadFunc
+}
+
+// supermicroZones are the zones setAll writes.
+var supermicroZones = []int{0, 1}
+
+func (supermicroActuator) name() string { return config.BackendSupermicro }
+
+func (s supermicroActuator) enableManual() error {
+	return s.raw(netfnOEM, cmdSupermicroFan, 0x01, supermicroModeFull)
+}
+
+func (s supermicroActuator) restoreAuto() error {
+	return s.raw(netfnOEM, cmdSupermicroFan, 0x01, supermicroModeOptimal)
+}
+
+func (s supermicroActuator) setAll(speed int) error {
+	for _, zone := range supermicroZones {
+		if err := s.setFan(zone, speed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s supermicroActuator) setFan(zone, speed int) error {
+	return s.raw(netfnOEM, cmdSupermicroPWM, 0x66, 0x01, byte(zone), byte(speed))
+}
+
+func (s supermicroActuator) probe() error {
+	return s.raw(netfnApp, cmdGetDeviceID)
+}
+
+func (s supermicroActuator) manualMode() (bool, error) {
+	resp, err := s.read(netfnOEM, cmdSupermicroFan, 0x00)
+	if err != nil {
+		return false, err
+	}
+	if len(resp) == 0 {
+		return false, errors.New("empty fan mode response")
+	}
+	return resp[0] == supermicroModeFull, nil
+}
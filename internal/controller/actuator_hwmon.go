@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+)
+
+// hwmonActuator drives fans through a Linux hwmon pwm chip (nct6775, it87,
+// ...): pwmN takes a 0-255 duty cycle and pwmN_enable selects manual (1) or
+// the chip's automatic mode. There is no BMC, so "restore auto" hands each
+// channel back to the chip's own fan control.
+type hwmonActuator struct {
+	dir      string
+	channels []int
+	autoMode int
+	// writeFile is os.WriteFile; a field so tests can fail individual writes.
+	writeFile func(name string, data []byte, perm os.FileMode) error
+	// readFile is os.ReadFile, for the same reason.
+	readFile func(name string) ([]byte, error)
+}
+
+func newHwmonActuator(cfg config.HwmonActuatorConfig) *hwmonActuator {
+	return &hwmonActuator{
+		dir:       cfg.Path,
+		channels:  cfg.Channels,
+		autoMode:  cfg.EffectiveAutoMode(),
+		writeFile: os.WriteFile,
+		readFile:  os.ReadFile,
+	}
+}
+
+func (*hwmonActuator) name() string { return config.BackendHwmon }
+
+func (h *hwmonActuator) write(file string, value int) error {
+	path := filepath.Join(h.dir, file)
+	if err := h.writeFile(path, []byte(strconv.Itoa(value)), 0o644); err != nil {
+		return fmt.Errorf("hwmon write %s: %w", path, err)
+	}
+	return nil
+}
+
+// setEnable writes mode to every channel's pwmN_enable. All channels are
+// attempted even if one fails, so a restore never leaves the remaining
+// channels in manual mode just because an earlier one errored.
+func (h *hwmonActuator) setEnable(mode int) error {
+	var errs []error
+	for _, ch := range h.channels {
+		if err := h.write(fmt.Sprintf("pwm%d_enable", ch), mode); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *hwmonActuator) enableManual() error { return h.setEnable(1) }
+
+func (h *hwmonActuator) restoreAuto() error { return h.setEnable(h.autoMode) }
+
+// probe reads back every channel's pwmN_enable, which fails once the chip's
+// driver is gone.
+func (h *hwmonActuator) probe() error {
+	var errs []error
+	for _, ch := range h.channels {
+		path := filepath.Join(h.dir, fmt.Sprintf("pwm%d_enable", ch))
+		if _, err := h.readFile(path); err != nil {
+			errs = append(errs, fmt.Errorf("hwmon read %s: %w", path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// manualMode reads every channel's pwmN_enable; a channel anywhere but 1 has
+// gone back to the chip, as happens when its driver is reloaded.
+func (h *hwmonActuator) manualMode() (bool, error) {
+	for _, ch := range h.channels {
+		path := filepath.Join(h.dir, fmt.Sprintf("pwm%d_enable", ch))
+		b, err := h.readFile(path)
+		if err != nil {
+			return false, fmt.Errorf("hwmon read %s: %w", path, err)
+		}
+		mode, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err != nil {
+			return false, fmt.Errorf("hwmon read %s: %w", path, err)
+		}
+		if mode != 1 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (h *hwmonActuator) setAll(speed int) error {
+	for fan := range h.channels {
+		if err := h.setFan(fan, speed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *hwmonActuator) setFan(fan, speed int) error {
+	if fan < 0 || fan >= len(h.channels) {
+		return fmt.Errorf("hwmon fan %d has no configured pwm channel", fan)
+	}
+	return h.write(fmt.Sprintf("pwm%d", h.channels[fan]), pwmDuty(speed))
+}
+
+// pwmDuty converts a 0-100% fan speed to a 0-255 pwm value, rounding to the
+// nearest step.
+func pwmDuty(speed int) int {
+	speed = max(0, min(100, speed))
+	return (speed*255 + 50) / 100
+}
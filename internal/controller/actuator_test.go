@@ -0,0 +1,300 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+	"github.com/sethpjohnson/only-fan-controller/internal/ipmi"
+)
+
+func TestDefaultActuatorIsDell(t *testing.T) {
+	rec := &cmdRecorder{}
+	cfg := testConfig()
+	cfg.IDRAC.Host = "local"
+	fc := NewFanController(cfg, nil, nil, nil)
+	fc.runCommand = rec.run
+
+	if err := fc.setFanSpeed(40); err != nil {
+		t.Fatalf("setFanSpeed: %v", err)
+	}
+	if len(rec.cmds) != 1 || strings.Join(rec.cmds[0], " ") != "ipmitool raw 0x30 0x30 0x02 0xff 0x28" {
+		t.Fatalf("dell fan write = %v, want ipmitool raw 0x30 0x30 0x02 0xff 0x28", rec.cmds)
+	}
+	if got := fc.GetStatus().Backend; got != config.BackendDell {
+		t.Fatalf("status backend = %q, want %q", got, config.BackendDell)
+	}
+}
+
+// TestSupermicroActuatorFailsafe drives the Supermicro backend through the
+// write fail-safe: manual control is the BMC's full mode, duty cycles go to
+// both zones, and the hand-back is optimal mode.
+func TestSupermicroActuatorFailsafe(t *testing.T) {
+	rec := &cmdRecorder{}
+	cfg := testConfig()
+	cfg.IDRAC.Host = "local"
+	cfg.Actuator.Backend = config.BackendSupermicro
+	cfg.FanControl.WriteFailureLimit = 1
+	fc := NewFanController(cfg, staticCPU{max: 50}, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = func(ctx context.Context, env []string, name string, args ...string) error {
+		// The Supermicro duty command has no 0x02 argument; fail it explicitly.
+		if containsArg(args, "0x66") {
+			rec.cmds = append(rec.cmds, append([]string{name}, args...))
+			return errors.New("simulated duty-cycle write failure")
+		}
+		return rec.run(ctx, env, name, args...)
+	}
+
+	if err := fc.enableManualMode(); err != nil {
+		t.Fatalf("enableManualMode: %v", err)
+	}
+	fc.controlLoop()
+
+	var got []string
+	for _, c := range rec.cmds {
+		got = append(got, strings.Join(c[1:], " "))
+	}
+	want := []string{
+		"raw 0x30 0x45 0x01 0x01",           // full mode
+		"raw 0x30 0x70 0x66 0x01 0x00 0x14", // zone 0 at idle 20% (fails)
+		"raw 0x30 0x45 0x01 0x02",           // fail-safe: optimal mode
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("supermicro commands:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if fc.currentFailsafeCause() != failsafeWrite || !fc.isRestoreConfirmed() {
+		t.Fatal("failed supermicro write did not hand control back through the fail-safe")
+	}
+}
+
+// fakeHwmon lays out pwm files for the given channels under a temp dir.
+func fakeHwmon(t *testing.T, channels ...int) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, ch := range channels {
+		for _, f := range []string{"pwm%d", "pwm%d_enable"} {
+			name := filepath.Join(dir, fmt.Sprintf(f, ch))
+			if err := os.WriteFile(name, []byte("0"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return dir
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func hwmonConfig(dir string) *config.Config {
+	cfg := testConfig()
+	cfg.Actuator = config.ActuatorConfig{
+		Backend: config.BackendHwmon,
+		Hwmon:   config.HwmonActuatorConfig{Path: dir, Channels: []int{1, 3}},
+	}
+	return cfg
+}
+
+func TestHwmonActuatorWritesPwm(t *testing.T) {
+	dir := fakeHwmon(t, 1, 3)
+	fc := NewFanController(hwmonConfig(dir), nil, nil, nil)
+
+	if err := fc.enableManualMode(); err != nil {
+		t.Fatalf("enableManualMode: %v", err)
+	}
+	if err := fc.setFanSpeed(50); err != nil {
+		t.Fatalf("setFanSpeed: %v", err)
+	}
+	for _, ch := range []string{"1", "3"} {
+		if got := readFile(t, dir, "pwm"+ch+"_enable"); got != "1" {
+			t.Fatalf("pwm%s_enable = %q after enableManual, want 1", ch, got)
+		}
+		if got := readFile(t, dir, "pwm"+ch); got != "128" {
+			t.Fatalf("pwm%s = %q at 50%%, want 128", ch, got)
+		}
+	}
+
+	if err := fc.RestoreAutoMode(); err != nil {
+		t.Fatalf("RestoreAutoMode: %v", err)
+	}
+	if got := readFile(t, dir, "pwm3_enable"); got != "2" {
+		t.Fatalf("pwm3_enable = %q after restore, want the default auto mode 2", got)
+	}
+}
+
+// TestHwmonRestoreAttemptsEveryChannel: one channel refusing the hand-back must
+// not leave the others in manual mode, and the failure must still be reported
+// so the fail-safe keeps retrying.
+func TestHwmonRestoreAttemptsEveryChannel(t *testing.T) {
+	dir := fakeHwmon(t, 1, 3)
+	fc := NewFanController(hwmonConfig(dir), nil, nil, nil)
+	hw := fc.act.(*hwmonActuator)
+	hw.writeFile = func(name string, data []byte, perm os.FileMode) error {
+		if filepath.Base(name) == "pwm1_enable" {
+			return errors.New("simulated EBUSY")
+		}
+		return os.WriteFile(name, data, perm)
+	}
+
+	if err := fc.RestoreAutoMode(); err == nil {
+		t.Fatal("expected the pwm1_enable failure to be reported")
+	}
+	if got := readFile(t, dir, "pwm3_enable"); got != "2" {
+		t.Fatalf("pwm3_enable = %q, want 2 even though pwm1 failed", got)
+	}
+}
+
+func TestHwmonProbeOnlyReads(t *testing.T) {
+	dir := fakeHwmon(t, 1, 3)
+	fc := NewFanController(hwmonConfig(dir), nil, nil, nil)
+	hw := fc.act.(*hwmonActuator)
+	hw.writeFile = func(name string, data []byte, perm os.FileMode) error {
+		t.Fatalf("probe wrote %s", name)
+		return nil
+	}
+	if err := fc.act.probe(); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "pwm3_enable")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.act.probe(); err == nil {
+		t.Fatal("expected the probe to fail with pwm3_enable gone")
+	}
+}
+
+func TestPwmDuty(t *testing.T) {
+	for speed, want := range map[int]int{0: 0, 20: 51, 50: 128, 100: 255, 120: 255} {
+		if got := pwmDuty(speed); got != want {
+			t.Errorf("pwmDuty(%d) = %d, want %d", speed, got, want)
+		}
+	}
+}
+
+// fakeNative stands in for the native RMCP+ session.
+type fakeNative struct {
+	reqs []string
+	err  error
+}
+
+func (f *fakeNative) Usable() bool { return true }
+
+func (f *fakeNative) Raw(ctx context.Context, netfn, cmd byte, data []byte) ([]byte, error) {
+	f.reqs = append(f.reqs, fmt.Sprintf("0x%02x 0x%02x % x", netfn, cmd, data))
+	return nil, f.err
+}
+
+func TestNativeTransportSkipsIpmitool(t *testing.T) {
+	rec := &cmdRecorder{}
+	native := &fakeNative{}
+	fc := NewFanController(testConfig(), nil, nil, nil)
+	fc.runCommand = rec.run
+	fc.native = native
+
+	if err := fc.setFanSpeed(40); err != nil {
+		t.Fatalf("setFanSpeed: %v", err)
+	}
+	if len(rec.cmds) != 0 {
+		t.Fatalf("ipmitool spawned with a healthy native session: %v", rec.cmds)
+	}
+	if len(native.reqs) != 1 || native.reqs[0] != "0x30 0x30 02 ff 28" {
+		t.Fatalf("native requests = %q, want the 0x30 0x30 0x02 0xff 0x28 write", native.reqs)
+	}
+}
+
+func TestNativeTransportFallsBackToIpmitool(t *testing.T) {
+	rec := &cmdRecorder{}
+	fc := NewFanController(testConfig(), nil, nil, nil)
+	fc.runCommand = rec.run
+	fc.native = &fakeNative{err: errors.New("no response from BMC")}
+
+	if err := fc.RestoreAutoMode(); err != nil {
+		t.Fatalf("RestoreAutoMode: %v", err)
+	}
+	if rec.restoreCount() != 1 {
+		t.Fatalf("restore not retried through ipmitool after the native session failed: %v", rec.cmds)
+	}
+}
+
+func TestNativeCompletionErrorIsNotRetried(t *testing.T) {
+	rec := &cmdRecorder{}
+	fc := NewFanController(testConfig(), nil, nil, nil)
+	fc.runCommand = rec.run
+	fc.native = &fakeNative{err: &ipmi.CompletionError{NetFn: 0x30, Cmd: 0x30, Code: 0xc1}}
+
+	if err := fc.enableManualMode(); err == nil {
+		t.Fatal("a command refused by the BMC must be reported")
+	}
+	if len(rec.cmds) != 0 {
+		t.Fatalf("refused command re-sent through ipmitool: %v", rec.cmds)
+	}
+}
+
+// TestIpmitoolPasswordNotInArgv: the BMC password must reach ipmitool through
+// IPMI_PASSWORD (-E), never as an argument visible in ps.
+func TestIpmitoolPasswordNotInArgv(t *testing.T) {
+	rec := &cmdRecorder{}
+	cfg := testConfig()
+	cfg.IDRAC.Host = "10.0.0.5"
+	cfg.IDRAC.Username = "root"
+	cfg.IDRAC.Password = "s3cret-pw"
+	fc := NewFanController(cfg, nil, nil, nil)
+	fc.runCommand = rec.run
+
+	if err := fc.enableManualMode(); err != nil {
+		t.Fatalf("enableManualMode: %v", err)
+	}
+	if err := fc.setFanSpeed(30); err != nil {
+		t.Fatalf("setFanSpeed: %v", err)
+	}
+	if err := fc.RestoreAutoMode(); err != nil {
+		t.Fatalf("RestoreAutoMode: %v", err)
+	}
+
+	if len(rec.cmds) != 3 {
+		t.Fatalf("commands = %v, want 3", rec.cmds)
+	}
+	for i, argv := range rec.cmds {
+		for _, a := range argv {
+			if strings.Contains(a, "s3cret-pw") || a == "-P" {
+				t.Fatalf("password on the command line: %v", argv)
+			}
+		}
+		if !containsArg(argv, "-E") {
+			t.Fatalf("argv %v does not ask ipmitool to read IPMI_PASSWORD", argv)
+		}
+		if !containsArg(rec.envs[i], "IPMI_PASSWORD=s3cret-pw") {
+			t.Fatalf("env %v does not carry the password", rec.envs[i])
+		}
+	}
+	want := "ipmitool -I lanplus -H 10.0.0.5 -U root -E raw 0x30 0x30 0x01 0x00"
+	if got := strings.Join(rec.cmds[0], " "); got != want {
+		t.Fatalf("argv = %q, want %q", got, want)
+	}
+}
+
+func TestLocalIpmitoolHasNoCredentials(t *testing.T) {
+	rec := &cmdRecorder{}
+	cfg := testConfig()
+	cfg.IDRAC.Host = "local"
+	cfg.IDRAC.Password = "s3cret-pw"
+	fc := NewFanController(cfg, nil, nil, nil)
+	fc.runCommand = rec.run
+
+	if err := fc.RestoreAutoMode(); err != nil {
+		t.Fatalf("RestoreAutoMode: %v", err)
+	}
+	if len(rec.envs) != 1 || len(rec.envs[0]) != 0 {
+		t.Fatalf("local ipmitool got env %v, want none", rec.envs)
+	}
+}
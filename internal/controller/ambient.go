@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"math"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/monitor"
+)
+
+// ambientView is the inlet compensation decided by the latest calculateTarget.
+// Guarded by mu.
+type ambientView struct {
+	shift      int // °C added to the CPU/GPU temperatures before the mode sees them
+	deltaFloor int // fan floor from the exhaust-inlet delta; 0 when not loaded
+}
+
+// chassisAir returns the inlet and exhaust air temperatures: the BMC's, read
+// with the CPU temperatures, or else the named sensors called inlet and
+// exhaust. Callers hold fc.mu.
+func (fc *FanController) chassisAir(cpu *monitor.CPUReading) (inlet, exhaust *int) {
+	if cpu != nil {
+		inlet, exhaust = cpu.Inlet, cpu.Exhaust
+	}
+	if t, ok := fc.lastSensors["inlet"]; ok && inlet == nil {
+		inlet = &t
+	}
+	if t, ok := fc.lastSensors["exhaust"]; ok && exhaust == nil {
+		exhaust = &t
+	}
+	return inlet, exhaust
+}
+
+// ambientFor computes the compensation for this tick. Without an inlet
+// reading there is no shift: the thresholds apply as configured. Callers hold
+// fc.mu.
+func (fc *FanController) ambientFor(inlet, exhaust *int) ambientView {
+	a := fc.cfg.FanControl.Ambient
+	if !a.Enabled || inlet == nil {
+		return ambientView{}
+	}
+
+	var v ambientView
+	limit := a.EffectiveMaxShift()
+	shift := int(math.Round(a.EffectiveGain() * float64(*inlet-a.EffectiveReference())))
+	v.shift = max(-limit, min(limit, shift))
+
+	if a.DeltaThreshold > 0 && exhaust != nil {
+		if over := *exhaust - *inlet - a.DeltaThreshold; over > 0 {
+			idle := fc.cfg.FanControl.IdleSpeed
+			if idle == 0 {
+				idle = 20
+			}
+			v.deltaFloor = idle + over*a.DeltaStep
+		}
+	}
+	return v
+}
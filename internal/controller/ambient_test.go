@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+	"github.com/sethpjohnson/only-fan-controller/internal/monitor"
+)
+
+func intPtr(v int) *int { return &v }
+
+// ambientConfig enables inlet compensation around a 25°C reference.
+func ambientConfig() *config.Config {
+	cfg := testConfig()
+	cfg.FanControl.Ambient = config.AmbientConfig{Enabled: true, Reference: 25}
+	return cfg
+}
+
+// withAir builds readings with the given chassis inlet/exhaust.
+func withAir(cpuMax, gpuMax int, inlet, exhaust *int) (*monitor.CPUReading, *monitor.GPUReading) {
+	cpuR, gpuR := cpuGpu(cpuMax, gpuMax)
+	cpuR.Inlet, cpuR.Exhaust = inlet, exhaust
+	return cpuR, gpuR
+}
+
+func TestAmbientShiftsThresholds(t *testing.T) {
+	tests := []struct {
+		name  string
+		cpu   int
+		inlet *int
+		want  int
+	}{
+		// cpu_threshold is 65: 60°C is below it at the reference inlet...
+		{name: "reference inlet leaves thresholds alone", cpu: 60, inlet: intPtr(25), want: 20},
+		// ...but 7°C of extra inlet moves the threshold to 58.
+		{name: "hot inlet ramps earlier", cpu: 60, inlet: intPtr(32), want: 30},
+		// 10°C below reference moves it to 75, so 67°C stays idle.
+		{name: "cold inlet ramps later", cpu: 67, inlet: intPtr(15), want: 20},
+		{name: "no inlet reading means no shift", cpu: 60, inlet: nil, want: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc := NewFanController(ambientConfig(), staticCPU{max: tt.cpu}, staticGPU{max: 30}, nil)
+			fc.currentSpeed = 20
+			cpuR, gpuR := withAir(tt.cpu, 30, tt.inlet, nil)
+			if got := fc.calculateTarget(cpuR, gpuR); got != tt.want {
+				t.Fatalf("calculateTarget(cpu=%d, inlet=%v) = %d, want %d", tt.cpu, tt.inlet, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAmbientNeverShiftsCritical(t *testing.T) {
+	fc := NewFanController(ambientConfig(), staticCPU{max: 85}, staticGPU{max: 30}, nil)
+	cpuR, gpuR := withAir(85, 30, intPtr(-5), nil) // shift capped at -10
+
+	if got := fc.calculateTarget(cpuR, gpuR); got != 100 {
+		t.Fatalf("critical CPU in a cold room = %d, want 100", got)
+	}
+	if fc.ambient.shift != -10 {
+		t.Fatalf("shift = %d, want the -10 cap", fc.ambient.shift)
+	}
+}
+
+func TestAirflowDeltaRaisesFloor(t *testing.T) {
+	cfg := ambientConfig()
+	cfg.FanControl.Ambient.DeltaThreshold = 15
+	cfg.FanControl.Ambient.DeltaStep = 4
+	fc := NewFanController(cfg, staticCPU{max: 50}, staticGPU{max: 30}, nil)
+	fc.currentSpeed = 20
+
+	// 45 - 25 = 20°C through the chassis, 5°C over the delta threshold.
+	cpuR, gpuR := withAir(50, 30, intPtr(25), intPtr(45))
+	if got := fc.calculateTarget(cpuR, gpuR); got != 40 {
+		t.Fatalf("calculateTarget with a 20°C airflow delta = %d, want 40", got)
+	}
+}
+
+func TestAmbientFallsBackToNamedInletSensor(t *testing.T) {
+	cfg := ambientConfig()
+	cfg.Sensors = []config.SensorConfig{{Name: "inlet", Source: config.SensorSourceHwmon, Hwmon: config.HwmonSensor{Chip: "acpitz"}}}
+	fc := NewFanController(cfg, staticCPU{max: 60}, staticGPU{max: 30}, nil)
+	fc.currentSpeed = 20
+	fc.lastSensors = map[string]int{"inlet": 32}
+	fc.lastCPUReading, _ = withAir(60, 30, nil, nil)
+
+	cpuR, gpuR := withAir(60, 30, nil, nil)
+	if got := fc.calculateTarget(cpuR, gpuR); got != 30 {
+		t.Fatalf("calculateTarget with a hot named inlet = %d, want 30", got)
+	}
+	status := fc.GetStatus()
+	if status.InletTemp == nil || *status.InletTemp != 32 || status.AmbientShift != 7 {
+		t.Fatalf("status inlet=%v shift=%d, want 32 and 7", status.InletTemp, status.AmbientShift)
+	}
+}
+
+func TestStatusReportsChassisAir(t *testing.T) {
+	fc := NewFanController(testConfig(), staticCPU{max: 40}, staticGPU{max: 30}, nil)
+	fc.lastCPUReading, _ = withAir(40, 30, intPtr(21), intPtr(33))
+
+	status := fc.GetStatus()
+	if status.InletTemp == nil || *status.InletTemp != 21 || status.ExhaustTemp == nil || *status.ExhaustTemp != 33 {
+		t.Fatalf("inlet/exhaust = %v/%v, want 21/33", status.InletTemp, status.ExhaustTemp)
+	}
+	if status.AirflowDelta == nil || *status.AirflowDelta != 12 {
+		t.Fatalf("airflow delta = %v, want 12", status.AirflowDelta)
+	}
+	if status.AmbientShift != 0 {
+		t.Fatalf("ambient shift = %d with compensation disabled, want 0", status.AmbientShift)
+	}
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"math"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+)
+
+// zoneAxis selects which temperature bound of a zone a curve is built from
+// (CPUMax for the CPU curve, GPUMax for the GPU curve).
+type zoneAxis func(z config.Zone) int
+
+func cpuAxis(z config.Zone) int { return z.CPUMax }
+func gpuAxis(z config.Zone) int { return z.GPUMax }
+
+// curveSpeed interpolates a fan speed for temp along the zone points
+// (axis(zone), zone.FanSpeed). Below the first point it returns the first
+// zone's speed and above the last point the last zone's speed. Zones are
+// validated as monotonic non-decreasing, so the points are already sorted; a
+// run of equal temperatures is a vertical step and resolves to the later zone.
+func curveSpeed(zones []config.Zone, temp int, axis zoneAxis) float64 {
+	if len(zones) == 0 {
+		return 0
+	}
+	if temp <= axis(zones[0]) {
+		return float64(zones[0].FanSpeed)
+	}
+	for i := 1; i < len(zones); i++ {
+		lo, hi := zones[i-1], zones[i]
+		if temp > axis(hi) {
+			continue
+		}
+		span := axis(hi) - axis(lo)
+		if span <= 0 {
+			return float64(hi.FanSpeed)
+		}
+		frac := float64(temp-axis(lo)) / float64(span)
+		return float64(lo.FanSpeed) + frac*float64(hi.FanSpeed-lo.FanSpeed)
+	}
+	return float64(zones[len(zones)-1].FanSpeed)
+}
+
+// curveZone returns the index of the last zone whose point temp has reached,
+// or the first zone below every point. Reporting the zone whose bound was
+// crossed (rather than the next one up) keeps a top "critical" zone from being
+// shown while the curve is merely past "hot": that label is reserved for the
+// emergency ramp in practice, which only the critical temperatures decide.
+func curveZone(zones []config.Zone, temp int, axis zoneAxis) int {
+	zone := 0
+	for i, z := range zones {
+		if temp >= axis(z) {
+			zone = i
+		}
+	}
+	return zone
+}
+
+// curveTarget computes the curve-mode fan speed: each source is interpolated
+// along its own curve and the higher speed wins. It also records the zone the
+// hotter source landed in as currentZone, replacing the fixed display labels
+// the other modes use.
+//
+// With predictive feed-forward enabled, the speed is read off the curve at the
+// temperature each source is heading for over the horizon (the zone still
+// reflects the actual temperature), and a still-rising trend holds the current
+// speed instead of following the curve back down. Callers hold fc.mu.
+func (fc *FanController) curveTarget(cpuMax, gpuMax int, pred prediction) int {
+	zones := fc.cfg.Zones
+	if len(zones) == 0 {
+		// Validate rejects curve mode without zones; this only guards a config
+		// built in code.
+		fc.currentZone = "idle"
+		return fc.cfg.FanControl.IdleSpeed
+	}
+
+	cpuLead, gpuLead := cpuMax, gpuMax
+	if fc.cfg.FanControl.Predictive.Enabled {
+		cpuLead = fc.lookahead(cpuMax, pred.cpuTrend)
+		gpuLead = fc.lookahead(gpuMax, pred.gpuTrend)
+	}
+
+	speed := curveSpeed(zones, cpuLead, cpuAxis)
+	zone := curveZone(zones, cpuMax, cpuAxis)
+	if fc.cfg.GPU.Enabled {
+		speed = math.Max(speed, curveSpeed(zones, gpuLead, gpuAxis))
+		zone = max(zone, curveZone(zones, gpuMax, gpuAxis))
+	}
+
+	fc.currentZone = zones[zone].Name
+	target := int(math.Round(speed))
+	if pred.rising && target < fc.currentSpeed {
+		target = fc.currentSpeed
+	}
+	return target
+}
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/monitor"
+)
+
+// sourceHealth is the outcome of the latest read of a built-in temperature
+// source. Guarded by mu.
+type sourceHealth struct {
+	err      error // nil when the latest read was usable
+	failures int   // consecutive unusable reads
+}
+
+func (h *sourceHealth) record(err error) {
+	h.err = err
+	if err != nil {
+		h.failures++
+	} else {
+		h.failures = 0
+	}
+}
+
+// status reports the health for SensorStatus.
+func (h sourceHealth) status() (healthy bool, reason string) {
+	if h.err != nil {
+		return false, h.err.Error()
+	}
+	return true, ""
+}
+
+// sensorRead is one tick's CPU and GPU readings. A source that failed to read,
+// or whose reading was rejected as implausible, has a nil reading and its
+// error.
+type sensorRead struct {
+	cpu    *monitor.CPUReading
+	gpu    *monitor.GPUReading
+	cpuErr error
+	gpuErr error
+}
+
+// readSensors reads both temperature sources, screens them for plausibility
+// and records each source's health, logging every failure. It never fabricates
+// a 0°C reading on failure.
+func (fc *FanController) readSensors() sensorRead {
+	var r sensorRead
+	var cpuSpikes, gpuSpikes int
+	var cpuFault, gpuFault error
+
+	r.cpu, r.cpuErr = fc.cpuMon.Read()
+	if r.cpuErr != nil {
+		log.Printf("CPU sensor read failed: %v", r.cpuErr)
+	} else if r.cpu, cpuSpikes, cpuFault = fc.plaus.screenCPU(r.cpu); cpuFault != nil {
+		r.cpuErr = cpuFault
+	}
+	r.gpu, r.gpuErr = fc.gpuMon.Read()
+	if r.gpuErr != nil {
+		log.Printf("GPU sensor read failed: %v", r.gpuErr)
+	} else if r.gpu, gpuSpikes, gpuFault = fc.plaus.screenGPU(r.gpu); gpuFault != nil {
+		r.gpuErr = gpuFault
+	}
+
+	fault := errors.Join(cpuFault, gpuFault)
+	fc.mu.Lock()
+	fc.plausStatus.SpikesFiltered += cpuSpikes + gpuSpikes
+	fc.plausStatus.Fault = ""
+	if fault != nil {
+		fc.plausStatus.Fault = fault.Error()
+		fc.plausStatus.Rejected++
+	}
+	fc.cpuHealth.record(r.cpuErr)
+	fc.gpuHealth.record(r.gpuErr)
+	fc.mu.Unlock()
+	if fault != nil {
+		log.Printf("Implausible sensor reading rejected: %v", fault)
+	}
+	return r
+}
+
+// usable returns the readings to control on, or ok=false when the tick counts
+// toward the sensor fail-safe. With both sources healthy that is the read
+// itself. With exactly one lost and fan_control.degraded enabled, control
+// goes on in degraded mode: the lost source is stood in for by an empty
+// reading, which can neither pull the fans up nor (see degradedFloor) let
+// them drop below the lost source's floor.
+func (fc *FanController) usable(r sensorRead) (*monitor.CPUReading, *monitor.GPUReading, bool) {
+	lost := ""
+	switch {
+	case r.cpuErr == nil && r.gpuErr == nil:
+	case !fc.cfg.FanControl.Degraded.Enabled || (r.cpuErr != nil && r.gpuErr != nil):
+		fc.mu.Lock()
+		fc.degraded = false
+		fc.mu.Unlock()
+		return nil, nil, false
+	case r.cpuErr != nil:
+		r.cpu, lost = &monitor.CPUReading{}, "CPU"
+	default:
+		r.gpu, lost = &monitor.GPUReading{}, "GPU"
+	}
+	fc.setDegraded(lost)
+	return r.cpu, r.gpu, true
+}
+
+// setDegraded records which source, if any, the control loop is running
+// without, logging the transitions.
+func (fc *FanController) setDegraded(lost string) {
+	fc.mu.Lock()
+	was := fc.degraded
+	fc.degraded = lost != ""
+	floor := fc.degradedFloor()
+	fc.mu.Unlock()
+	switch {
+	case !was && lost != "":
+		log.Printf("DEGRADED: %s sensors lost; controlling on the other source with a %d%% fan floor", lost, floor)
+	case was && lost == "":
+		log.Printf("Degraded mode cleared: CPU and GPU sensors both healthy")
+	}
+}
+
+// degradedFloor is the fan floor for the source lost in degraded mode, 0 when
+// both are healthy. Callers hold fc.mu.
+func (fc *FanController) degradedFloor() int {
+	if !fc.degraded {
+		return 0
+	}
+	d := fc.cfg.FanControl.Degraded
+	if fc.cpuHealth.err != nil {
+		return d.EffectiveCPUFloor()
+	}
+	return d.EffectiveGPUFloor()
+}
+
+// tempOrLost formats a source's temperature for the control loop log line.
+func tempOrLost(temp int, lost bool) string {
+	if lost {
+		return "lost"
+	}
+	return fmt.Sprintf("%d°C", temp)
+}
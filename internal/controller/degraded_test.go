@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/monitor"
+)
+
+// switchableGPU fails while down is set, so a test can lose and restore the
+// GPU source on one controller.
+type switchableGPU struct {
+	max  int
+	down bool
+}
+
+func (g *switchableGPU) Read() (*monitor.GPUReading, error) {
+	if g.down {
+		return nil, errors.New("simulated nvidia-smi failure")
+	}
+	return &monitor.GPUReading{Max: g.max, Devices: []monitor.GPUDevice{{Index: 0, Temp: g.max}}}, nil
+}
+
+func TestDegradedModeControlsOnHealthySource(t *testing.T) {
+	rec := &cmdRecorder{}
+	cfg := testConfig()
+	cfg.FanControl.SensorFailureLimit = 1
+	cfg.FanControl.Degraded.Enabled = true
+	cfg.FanControl.Degraded.GPUFloor = 50
+	gpu := &switchableGPU{max: 45, down: true}
+	fc := NewFanController(cfg, staticCPU{max: 40}, gpu, newTestStore(t))
+	fc.runCommand = rec.run
+
+	fc.controlLoop()
+	if fc.currentFailsafeCause() != failsafeNone {
+		t.Fatalf("fail-safe = %s with the CPU still reading, want none", fc.currentFailsafeCause())
+	}
+	st := fc.GetStatus()
+	if st.TargetSpeed != 50 || !st.Degraded {
+		t.Fatalf("target %d, degraded %v; want the 50%% GPU floor in degraded mode", st.TargetSpeed, st.Degraded)
+	}
+	if st.GPU != nil || len(st.Sensors) < 2 {
+		t.Fatalf("status GPU = %+v, sensors %+v; want no GPU reading", st.GPU, st.Sensors)
+	}
+	if cpu, g := st.Sensors[0], st.Sensors[1]; !cpu.Healthy || g.Healthy || g.Error == "" {
+		t.Fatalf("sensors = %+v, want cpu healthy and gpu lost with its error", st.Sensors)
+	}
+
+	// A hot CPU still drives the fans past the floor.
+	fc.cpuMon = staticCPU{max: 80}
+	for range 5 {
+		fc.controlLoop()
+	}
+	if got := fc.GetStatus().TargetSpeed; got <= 50 {
+		t.Fatalf("target = %d with the CPU at 80°C, want above the floor", got)
+	}
+
+	gpu.down = false
+	fc.cpuMon = staticCPU{max: 40}
+	fc.controlLoop()
+	if st := fc.GetStatus(); st.Degraded || st.GPU == nil || !st.Sensors[1].Healthy {
+		t.Fatalf("degraded %v, GPU %+v after the GPU came back; want healthy", st.Degraded, st.GPU)
+	}
+}
+
+func TestDegradedModeHandsBackWhenBothSourcesLost(t *testing.T) {
+	rec := &cmdRecorder{}
+	cfg := testConfig()
+	cfg.FanControl.SensorFailureLimit = 2
+	cfg.FanControl.Degraded.Enabled = true
+	gpu := &switchableGPU{max: 45, down: true}
+	fc := NewFanController(cfg, failingCPU{}, gpu, newTestStore(t))
+	fc.runCommand = rec.run
+
+	fc.controlLoop()
+	fc.controlLoop()
+	if fc.currentFailsafeCause() != failsafeSensor {
+		t.Fatalf("fail-safe = %s with both sources lost, want sensor-loss", fc.currentFailsafeCause())
+	}
+	if fc.GetStatus().Degraded {
+		t.Fatal("degraded reported with no source to control on")
+	}
+}
+
+func TestLosingOneSourceWithoutDegradedModeTripsFailsafe(t *testing.T) {
+	rec := &cmdRecorder{}
+	cfg := testConfig()
+	cfg.FanControl.SensorFailureLimit = 1
+	fc := NewFanController(cfg, staticCPU{max: 40}, &switchableGPU{down: true}, newTestStore(t))
+	fc.runCommand = rec.run
+
+	fc.controlLoop()
+	if fc.currentFailsafeCause() != failsafeSensor {
+		t.Fatalf("fail-safe = %s, want sensor-loss with degraded mode off", fc.currentFailsafeCause())
+	}
+}
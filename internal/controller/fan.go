@@ -3,13 +3,19 @@ package controller
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/sethpjohnson/only-fan-controller/internal/config"
+	"github.com/sethpjohnson/only-fan-controller/internal/ipmi"
 	"github.com/sethpjohnson/only-fan-controller/internal/monitor"
 	"github.com/sethpjohnson/only-fan-controller/internal/storage"
 )
@@ -25,31 +31,61 @@ type gpuReader interface {
 	Read() (*monitor.GPUReading, error)
 }
 
-// runCommandFunc executes an external command with a context deadline. It is a
-// field on FanController so tests can substitute a stub in place of real
-// ipmitool invocations.
-type runCommandFunc func(ctx context.Context, name string, args ...string) error
+// rawIPMI is the native BMC session (*ipmi.Client); an interface so tests can
+// stand in for the BMC.
+type rawIPMI interface {
+	Usable() bool
+	Raw(ctx context.Context, netfn, cmd byte, data []byte) ([]byte, error)
+}
+
+// runCommandFunc executes an external command with a context deadline and env
+// (NAME=value entries) added to the process environment. It is a field on
+// FanController so tests can substitute a stub in place of real ipmitool
+// invocations.
+type runCommandFunc func(ctx context.Context, env []string, name string, args ...string) error
+
+// runOutputFunc is runCommandFunc for the commands whose output is read
+// (ipmitool raw requests that return data); it returns the command's stdout.
+type runOutputFunc func(ctx context.Context, env []string, name string, args ...string) (string, error)
 
 // failsafeCause records why the controller handed cooling back to the BMC.
 type failsafeCause int
 
 const (
-	failsafeNone   failsafeCause = iota
-	failsafeSensor               // sensor loss — recoverable
-	failsafeWrite                // fan-write failures — sticky until restart
+	failsafeNone      failsafeCause = iota
+	failsafeSafeSpeed               // sensor loss, fans pinned at safe_speed — still under manual control
+	failsafeSensor                  // sensor loss — recoverable
+	failsafeWrite                   // fan-write failures — sticky until restart, or until write_recovery reclaims
+	failsafeVerify                  // fans not following the writes (fan_control.verify) — sticky until restart
 )
 
 func (c failsafeCause) String() string {
 	switch c {
+	case failsafeSafeSpeed:
+		return "safe-speed"
 	case failsafeSensor:
 		return "sensor-loss"
 	case failsafeWrite:
 		return "write-failure"
+	case failsafeVerify:
+		return "verify-failure"
 	default:
 		return "none"
 	}
 }
 
+// handsBack reports whether the cause has handed cooling back to the BMC. The
+// safe-speed stage has not: the controller still drives the fans.
+func (c failsafeCause) handsBack() bool {
+	return c == failsafeSensor || c.sticky()
+}
+
+// sticky reports whether the cause stays until restart: the write channel
+// itself is not to be trusted, so sensor recovery does not end it.
+func (c failsafeCause) sticky() bool {
+	return c == failsafeWrite || c == failsafeVerify
+}
+
 type FanController struct {
 	cfg    *config.Config
 	cpuMon cpuReader
@@ -58,6 +94,24 @@ type FanController struct {
 
 	// runCommand runs external commands (ipmitool). Defaults to realRunCommand.
 	runCommand runCommandFunc
+	// runOutput runs the ones whose output is read. Defaults to realRunOutput.
+	runOutput runOutputFunc
+
+	// act writes fan speeds and performs the manual/auto hand-back for the
+	// configured actuator backend.
+	act actuator
+
+	// native is the in-process RMCP+ session to the BMC, nil when commands go
+	// through ipmitool.
+	native rawIPMI
+
+	// sensorMon reads the named sensors (cfg.Sensors); nil when none are
+	// configured.
+	sensorMon sensorReader
+
+	// fanMon reads the fan tachometers (fan_control.verify); nil when fan
+	// readback is off.
+	fanMon fanReader
 
 	// State
 	mu             sync.RWMutex
@@ -71,6 +125,14 @@ type FanController struct {
 	running        bool
 	stopChan       chan struct{}
 
+	// reloadCh hands a reloaded config to the control loop goroutine, which
+	// swaps it in between ticks, and applyCh an update that waits for that;
+	// restartRequired lists the reloaded sections that only a restart
+	// applies. Guarded by mu.
+	reloadCh        chan *config.Config
+	applyCh         chan applyRequest
+	restartRequired []string
+
 	// Fail-safe state. failsafeCause and lastWriteFailed are read by GetStatus
 	// (API goroutine) so they are guarded by mu. The consecutive-failure counters
 	// are only ever touched from the control loop goroutine.
@@ -81,11 +143,16 @@ type FanController struct {
 	//   - a WRITE fail-safe is STICKY — once the fan-write channel proves
 	//     unreliable we leave the BMC in charge (no auto-recovery), because
 	//     probing it every tick would flap the BMC between manual and auto.
+	//     fan_control.write_recovery opts into a way out that cannot flap: see
+	//     writeRecovery.
 	failsafeCause    failsafeCause
 	restoreConfirmed bool // true once RestoreAutoMode has actually succeeded for the current fail-safe
 	lastWriteFailed  bool
 	sensorFailCount  int
 	writeFailCount   int
+	writeRec         writeRecovery
+	fanCheck         fanVerify
+	modeCheck        modeWatch
 
 	// History for trend analysis
 	cpuHistory []tempPoint
@@ -93,6 +160,37 @@ type FanController struct {
 
 	// Hysteresis tracking
 	lastOverThreshold time.Time
+
+	// PID loop memory (fan_control.mode: pid), one per source. Guarded by mu.
+	cpuPID pidState
+	gpuPID pidState
+
+	// Trend feed-forward view from the latest calculateTarget. Guarded by mu.
+	prediction prediction
+
+	// Per-group targets and write results (fan_control.groups). Guarded by mu.
+	groups []groupState
+
+	// Latest named sensor temperatures, keyed by name. Guarded by mu.
+	lastSensors map[string]int
+
+	// Inlet compensation from the latest calculateTarget. Guarded by mu.
+	ambient ambientView
+
+	// The GPUs as the latest calculateTarget saw them. Guarded by mu.
+	gpu gpuView
+
+	// plaus screens readings (monitoring.plausibility) in the control loop;
+	// plausStatus is its outcome for GetStatus, guarded by mu.
+	plaus       *plausibility
+	plausStatus PlausibilityStatus
+
+	// Health of the built-in sources as of the latest read, and whether the
+	// latest tick controlled on one of them alone (fan_control.degraded).
+	// Guarded by mu.
+	cpuHealth sourceHealth
+	gpuHealth sourceHealth
+	degraded  bool
 }
 
 type tempPoint struct {
@@ -125,6 +223,8 @@ type Status struct {
 	TargetSpeed  int                 `json:"target_speed"`
 	Zone         string              `json:"zone"`
 	Mode         string              `json:"mode"`
+	ControlMode  string              `json:"control_mode"` // "step", "pid" or "curve"
+	Backend      string              `json:"backend"`      // fan actuator: "dell", "supermicro" or "hwmon"
 	ActiveHints  []*WorkloadHint     `json:"active_hints"`
 	Override     *Override           `json:"override,omitempty"`
 	CPUTrend     float64             `json:"cpu_trend"`
@@ -135,30 +235,123 @@ type Status struct {
 	IdleSpeed    int                 `json:"idle_speed"`
 	// Fail-safe visibility for operators / the dashboard.
 	FailsafeActive  bool   `json:"failsafe_active"`   // true when cooling has been handed back to BMC auto mode
-	FailsafeReason  string `json:"failsafe_reason"`   // "none", "sensor-loss" or "write-failure"
+	FailsafeReason  string `json:"failsafe_reason"`   // "none", "safe-speed", "sensor-loss", "write-failure" or "verify-failure"
 	RestorePending  bool   `json:"restore_pending"`   // true when in fail-safe but RestoreAutoMode has not yet succeeded (BMC may still be in manual mode)
 	LastWriteFailed bool   `json:"last_write_failed"` // true when the most recent fan-speed write failed
+
+	// Trend feed-forward: seconds until each source's trend reaches its
+	// threshold (null when it is not heading there), and whether the predictive
+	// mode is pre-ramping on such a crossing right now.
+	CPUCrossingIn *float64 `json:"cpu_crossing_in"`
+	GPUCrossingIn *float64 `json:"gpu_crossing_in"`
+	PreRamp       bool     `json:"pre_ramp"`
+
+	// Trend fit quality: R² of the least-squares fit behind cpu_trend/gpu_trend
+	// and how many samples it used. A trend from few samples or with a low R²
+	// is noise, not a signal.
+	CPUTrendR2      float64 `json:"cpu_trend_r2"`
+	GPUTrendR2      float64 `json:"gpu_trend_r2"`
+	CPUTrendSamples int     `json:"cpu_trend_samples"`
+	GPUTrendSamples int     `json:"gpu_trend_samples"`
+
+	// FanGroups reports each configured fan group; omitted when all fans run
+	// at one speed.
+	FanGroups []FanGroupStatus `json:"fan_groups,omitempty"`
+
+	// Sensors is the sensor registry: the built-in cpu and gpu sensors and
+	// every configured named sensor with its latest temperature.
+	Sensors []SensorStatus `json:"sensors"`
+
+	// Chassis air: inlet and exhaust temperatures (null when unavailable),
+	// their difference, and the °C fan_control.ambient currently adds to the
+	// CPU/GPU temperatures (negative in a cold room; 0 when disabled).
+	InletTemp    *int `json:"inlet_temp"`
+	ExhaustTemp  *int `json:"exhaust_temp"`
+	AirflowDelta *int `json:"airflow_delta"`
+	AmbientShift int  `json:"ambient_shift"`
+
+	// GPUThrottled is set while any GPU is thermally throttling, which counts
+	// as the GPU being over gpu_threshold.
+	GPUThrottled bool `json:"gpu_throttled"`
+
+	// GPUDriver is the GPU whose temperature drove the last decision (see
+	// gpu.devices); null without per-card readings or with every card
+	// excluded.
+	GPUDriver *GPUDriverStatus `json:"gpu_driver"`
+
+	// Plausibility reports readings rejected by monitoring.plausibility; a
+	// rejected reading counts toward the sensor fail-safe.
+	Plausibility PlausibilityStatus `json:"plausibility"`
+
+	// Degraded is set while control runs on one of the CPU and GPU sources
+	// with the other lost (fan_control.degraded); each source's health is in
+	// Sensors.
+	Degraded bool `json:"degraded"`
+
+	// Fans reports each fan tachometer read back by fan_control.verify, and
+	// FanVerifyFault why the latest check failed; both omitted when the
+	// check is off or passed.
+	Fans           []FanStatus `json:"fans,omitempty"`
+	FanVerifyFault string      `json:"fan_verify_fault,omitempty"`
+
+	// FanFailure is set while a fan has failed (stalled or far below the
+	// commanded speed) and FanCompensation raises the others; FailedFans
+	// names them.
+	FanFailure      bool     `json:"fan_failure"`
+	FailedFans      []string `json:"failed_fans,omitempty"`
+	FanCompensation int      `json:"fan_compensation"`
+
+	// ModeReverts counts the times fan_control.mode_watch caught the BMC back
+	// in automatic fan control and reasserted manual control, the latest at
+	// LastModeRevert.
+	ModeReverts    int        `json:"mode_reverts"`
+	LastModeRevert *time.Time `json:"last_mode_revert,omitempty"`
+
+	// RestartRequired lists the config sections changed by a reload that only
+	// take effect after a restart; omitted when there are none.
+	RestartRequired []string `json:"restart_required,omitempty"`
+
+	// WriteRecovery reports fan_control.write_recovery's probes and flaps;
+	// omitted when it is off.
+	WriteRecovery *WriteRecoveryStatus `json:"write_recovery,omitempty"`
 }
 
 func NewFanController(cfg *config.Config, cpuMon cpuReader, gpuMon gpuReader, store *storage.Store) *FanController {
-	return &FanController{
+	fc := &FanController{
 		cfg:        cfg,
 		cpuMon:     cpuMon,
 		gpuMon:     gpuMon,
 		store:      store,
 		runCommand: realRunCommand,
+		runOutput:  realRunOutput,
 		hints:      make(map[string]*WorkloadHint),
 		stopChan:   make(chan struct{}),
+		reloadCh:   make(chan *config.Config, 1),
+		applyCh:    make(chan applyRequest),
 		cpuHistory: make([]tempPoint, 0),
 		gpuHistory: make([]tempPoint, 0),
+		plaus:      newPlausibility(cfg.Monitoring.Plausibility),
+		writeRec:   writeRecovery{now: time.Now},
+		modeCheck:  modeWatch{now: time.Now},
 	}
+	fc.act = newActuator(fc)
+	return fc
+}
+
+// UseNativeIPMI sends the controller's BMC commands over client instead of
+// spawning ipmitool for each one. Call it before Run.
+func (fc *FanController) UseNativeIPMI(client *ipmi.Client) {
+	fc.native = client
 }
 
 // realRunCommand runs an external command bounded by the context deadline. On a
 // deadline it returns a descriptive error so the caller can distinguish a hung
 // command (e.g. a stuck `ipmitool -I lanplus`) from a normal failure.
-func realRunCommand(ctx context.Context, name string, args ...string) error {
+func realRunCommand(ctx context.Context, env []string, name string, args ...string) error {
 	cmd := exec.CommandContext(ctx, name, args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
@@ -170,6 +363,24 @@ func realRunCommand(ctx context.Context, name string, args ...string) error {
 	return nil
 }
 
+// realRunOutput is realRunCommand returning stdout.
+func realRunOutput(ctx context.Context, env []string, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("%s timed out: %w", name, err)
+		}
+		return "", fmt.Errorf("%s error: %v, stderr: %s", name, err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
 func (fc *FanController) Run() {
 	fc.mu.Lock()
 	fc.running = true
@@ -190,6 +401,10 @@ func (fc *FanController) Run() {
 		select {
 		case <-ticker.C:
 			fc.controlLoop()
+		case next := <-fc.reloadCh:
+			fc.applyReload(next)
+		case req := <-fc.applyCh:
+			req.done <- fc.applyUpdate(req)
 		case <-fc.stopChan:
 			return
 		}
@@ -214,23 +429,30 @@ func (fc *FanController) controlLoop() {
 	// A WRITE fail-safe is sticky: once the fan-write channel has proven
 	// unreliable we leave the BMC in charge until the process restarts. Probing
 	// it every tick (reclaim manual -> write fails -> restore auto) would flap the
-	// BMC forever, so we deliberately do not auto-recover. We still refresh the
+	// BMC forever, so we deliberately do not auto-recover — unless
+	// fan_control.write_recovery is on, whose read-only probes reclaim manual
+	// control at most once per run of healthy probes. We still refresh the
 	// readings for /api/status visibility.
-	if fc.currentFailsafeCause() == failsafeWrite {
-		if cpuReading, gpuReading, ok := fc.readSensors(); ok {
+	// A verify fail-safe is sticky for the same reason, with no recovery: a
+	// write the BMC accepts and ignores looks healthy to any probe.
+	if cause := fc.currentFailsafeCause(); cause.sticky() && !(cause == failsafeWrite && fc.recoverWriteFailsafe()) {
+		if cpuReading, gpuReading, ok := fc.usable(fc.readSensors()); ok {
 			fc.recordReadings(cpuReading, gpuReading)
 		}
+		fc.readNamedSensors()
 		return
 	}
 
 	// Read temperatures. A read error must NEVER be treated as 0°C ("cold"),
 	// which would ramp the fans down and cook the machine. Instead we hold the
-	// current speed and, after repeated failures, hand cooling back to the BMC.
-	cpuReading, gpuReading, ok := fc.readSensors()
+	// current speed and, after repeated failures, hand cooling back to the BMC
+	// — or, in degraded mode, control on the source that still reads.
+	cpuReading, gpuReading, ok := fc.usable(fc.readSensors())
 	if !ok {
 		fc.handleSensorFailure()
 		return
 	}
+	sensors := fc.readNamedSensors()
 
 	// Sensors are healthy again: clear the failure count.
 	if fc.sensorFailCount > 0 {
@@ -238,6 +460,13 @@ func (fc *FanController) controlLoop() {
 		fc.sensorFailCount = 0
 	}
 
+	// The safe-speed stage never gave up manual control, so there is nothing
+	// to reclaim.
+	if fc.currentFailsafeCause() == failsafeSafeSpeed {
+		log.Printf("FAILSAFE CLEARED: sensors recovered, leaving the safe speed")
+		fc.clearFailsafe()
+	}
+
 	// If we were in a (recoverable) sensor fail-safe, reclaim manual control
 	// before acting on the reading — but only from a CONFIRMED-restored state, so
 	// the manual<->auto handoff stays coherent. If the hand-back to BMC auto is
@@ -264,64 +493,111 @@ func (fc *FanController) controlLoop() {
 	// Calculate target fan speed
 	target := fc.calculateTarget(cpuReading, gpuReading)
 
+	// A BMC back in auto mode ignores the write below; take control back
+	// first.
+	fc.watchMode()
+
 	// Apply fan speed. A failed write is a watchdog concern: after repeated
 	// failures we restore BMC auto mode rather than leave the fans wherever
 	// they were last set.
+	var fans map[string]int
 	if err := fc.setFanSpeed(target); err != nil {
 		fc.handleWriteFailure(err)
 	} else {
 		fc.writeFailCount = 0
+		fans = fc.verifyFans()
 	}
 
-	// Store reading
-	fc.store.RecordReading(cpuReading.Max, gpuReading.Max, target)
-
 	fc.mu.RLock()
 	zone := fc.currentZone
+	degraded := fc.degraded
+	cpuLost, gpuLost := fc.cpuHealth.err != nil, fc.gpuHealth.err != nil
 	fc.mu.RUnlock()
-	log.Printf("CPU: %d°C | GPU: %d°C | Zone: %s | Fan: %d%%",
-		cpuReading.Max, gpuReading.Max, zone, target)
-}
 
-// readSensors reads both temperature sources. It returns ok=false if either read
-// failed, logging each failure. It never fabricates a 0°C reading on failure.
-func (fc *FanController) readSensors() (*monitor.CPUReading, *monitor.GPUReading, bool) {
-	cpuReading, cpuErr := fc.cpuMon.Read()
-	gpuReading, gpuErr := fc.gpuMon.Read()
-	if cpuErr != nil {
-		log.Printf("CPU sensor read failed: %v", cpuErr)
-	}
-	if gpuErr != nil {
-		log.Printf("GPU sensor read failed: %v", gpuErr)
-	}
-	if cpuErr != nil || gpuErr != nil {
-		return nil, nil, false
+	// Store reading. History has no way to record a lost source, so a degraded
+	// tick is left out rather than stored as 0°C.
+	if degraded {
+		log.Printf("DEGRADED | CPU: %s | GPU: %s | Zone: %s | Fan: %d%%",
+			tempOrLost(cpuReading.Max, cpuLost), tempOrLost(gpuReading.Max, gpuLost), zone, target)
+		return
 	}
-	return cpuReading, gpuReading, true
+	fc.store.RecordReading(cpuReading.Max, gpuReading.Max, target, gpuTelemetry(gpuReading), sensors, fans)
+	log.Printf("CPU: %d°C | GPU: %d°C | Zone: %s | Fan: %d%%",
+		cpuReading.Max, gpuReading.Max, zone, target)
 }
 
 // recordReadings updates the last-known readings and history under the lock.
 func (fc *FanController) recordReadings(cpuReading *monitor.CPUReading, gpuReading *monitor.GPUReading) {
 	fc.mu.Lock()
 	defer fc.mu.Unlock()
-	fc.lastCPUReading = cpuReading
-	fc.lastGPUReading = gpuReading
+	// A source lost in degraded mode has no reading and no history point.
+	fc.lastCPUReading, fc.lastGPUReading = nil, nil
 	now := time.Now()
-	fc.cpuHistory = append(fc.cpuHistory, tempPoint{temp: cpuReading.Max, timestamp: now})
-	fc.gpuHistory = append(fc.gpuHistory, tempPoint{temp: gpuReading.Max, timestamp: now})
+	if fc.cpuHealth.err == nil {
+		fc.lastCPUReading = cpuReading
+		fc.cpuHistory = append(fc.cpuHistory, tempPoint{temp: cpuReading.Max, timestamp: now})
+	}
+	if fc.gpuHealth.err == nil {
+		fc.lastGPUReading = gpuReading
+		// The GPU trend follows the control temperature, so excluded cards and
+		// per-card thresholds apply to the prediction too.
+		fc.gpuHistory = append(fc.gpuHistory, tempPoint{temp: fc.gpuViewFor(gpuReading).temp, timestamp: now})
+	}
 	fc.trimHistory()
 	fc.cleanExpired()
 }
 
 // handleSensorFailure records a consecutive sensor read failure. It holds the
 // current fan speed (does not touch the fans) and, once the configured limit is
-// reached, hands cooling back to the BMC's automatic control.
+// reached, hands cooling back to the BMC's automatic control — or, with
+// safe_speed set, first pins the fans at that speed and hands back only
+// safe_speed_failures failures later.
 func (fc *FanController) handleSensorFailure() {
 	fc.sensorFailCount++
 	limit := fc.sensorFailureLimit()
-	log.Printf("SENSOR FAILURE %d/%d - holding fan speed (not treating missing data as 0°C)",
-		fc.sensorFailCount, limit)
-	if fc.sensorFailCount >= limit {
+	if fc.cfg.FanControl.SafeSpeed == 0 {
+		log.Printf("SENSOR FAILURE %d/%d - holding fan speed (not treating missing data as 0°C)",
+			fc.sensorFailCount, limit)
+		if fc.sensorFailCount >= limit {
+			fc.enterFailsafe(failsafeSensor)
+		}
+		return
+	}
+
+	handBack := limit + fc.safeSpeedFailures()
+	log.Printf("SENSOR FAILURE %d/%d (safe speed from %d) - not treating missing data as 0°C",
+		fc.sensorFailCount, handBack, limit)
+	switch {
+	case fc.sensorFailCount >= handBack:
+		fc.enterFailsafe(failsafeSensor)
+	case fc.sensorFailCount >= limit:
+		fc.holdSafeSpeed()
+	}
+}
+
+// holdSafeSpeed is the intermediate fail-safe stage: the fans are pinned at
+// safe_speed while the controller keeps manual control. The speed is written
+// on every failed tick; if a write fails, the fans cannot be trusted to be at
+// it and cooling goes back to the BMC at once.
+func (fc *FanController) holdSafeSpeed() {
+	fc.mu.Lock()
+	prev := fc.failsafeCause
+	if prev.handsBack() {
+		fc.mu.Unlock()
+		return
+	}
+	speed := fc.clampSpeed(fc.cfg.FanControl.SafeSpeed)
+	fc.failsafeCause = failsafeSafeSpeed
+	fc.targetSpeed = speed
+	fc.resetPID()
+	fc.pinGroups(speed)
+	fc.mu.Unlock()
+
+	if prev == failsafeNone {
+		log.Printf("FAILSAFE SAFE SPEED (sensor-loss): pinning fans at %d%% before handing back to the BMC", speed)
+	}
+	if err := fc.setFanSpeed(speed); err != nil {
+		log.Printf("Safe-speed write failed (%v); escalating to BMC auto mode", err)
 		fc.enterFailsafe(failsafeSensor)
 	}
 }
@@ -357,15 +633,22 @@ func (fc *FanController) isRestoreConfirmed() bool {
 func (fc *FanController) enterFailsafe(cause failsafeCause) {
 	fc.mu.Lock()
 	prev := fc.failsafeCause
-	// Write is the stickiest cause; never let a later sensor failure overwrite it.
-	if prev == failsafeWrite {
+	// Write and verify are the stickiest causes; never let a later failure
+	// overwrite them.
+	if prev.sticky() {
 		fc.mu.Unlock()
 		return
 	}
 	fc.failsafeCause = cause
+	if cause == failsafeWrite && prev != failsafeWrite {
+		fc.writeRec.trip(fc.cfg.FanControl.WriteRecovery)
+	}
+	// The BMC owns the fans until the fail-safe clears; the PID loop's integral
+	// and last sample say nothing about what it will find then.
+	fc.resetPID()
 	fc.mu.Unlock()
 
-	if prev != failsafeNone {
+	if prev.handsBack() {
 		// Already handed to BMC auto for some reason; ensureAutoRestored owns the
 		// (possibly still-pending) restore retry.
 		return
@@ -380,7 +663,7 @@ func (fc *FanController) enterFailsafe(cause failsafeCause) {
 // cannot flap.
 func (fc *FanController) ensureAutoRestored() {
 	fc.mu.RLock()
-	pending := fc.failsafeCause != failsafeNone && !fc.restoreConfirmed
+	pending := fc.failsafeCause.handsBack() && !fc.restoreConfirmed
 	fc.mu.RUnlock()
 	if !pending {
 		return
@@ -407,6 +690,7 @@ func (fc *FanController) clearFailsafe() {
 	fc.mu.Lock()
 	fc.failsafeCause = failsafeNone
 	fc.restoreConfirmed = false
+	fc.resetPID()
 	// sensorFailCount / writeFailCount are only ever touched from the single
 	// control-loop goroutine (here and in handleSensor/WriteFailure), so they
 	// need no lock; grouped here for a coherent reset of all fail-safe state.
@@ -422,6 +706,13 @@ func (fc *FanController) sensorFailureLimit() int {
 	return 3
 }
 
+func (fc *FanController) safeSpeedFailures() int {
+	if fc.cfg.FanControl.SafeSpeedFailures > 0 {
+		return fc.cfg.FanControl.SafeSpeedFailures
+	}
+	return 3
+}
+
 func (fc *FanController) writeFailureLimit() int {
 	if fc.cfg.FanControl.WriteFailureLimit > 0 {
 		return fc.cfg.FanControl.WriteFailureLimit
@@ -454,23 +745,75 @@ func commandTimeout(intervalSeconds int) time.Duration {
 	return interval
 }
 
-// ipmitool runs an ipmitool raw command against the configured BMC (local or
+// ipmiRaw sends a raw IPMI command to the BMC with a deadline. With the native
+// transport it goes over the persistent RMCP+ session; when that session
+// cannot reach the BMC the command is sent through ipmitool instead. A
+// command the BMC itself refused (a completion code) is not retried: ipmitool
+// would get the same answer.
+func (fc *FanController) ipmiRaw(netfn, cmd byte, data ...byte) error {
+	if fc.native != nil && fc.native.Usable() {
+		ctx, cancel := context.WithTimeout(context.Background(), fc.commandTimeout())
+		_, err := fc.native.Raw(ctx, netfn, cmd, data)
+		cancel()
+		var cerr *ipmi.CompletionError
+		if err == nil || errors.As(err, &cerr) {
+			return err
+		}
+		log.Printf("Native IPMI request failed (%v); falling back to ipmitool", err)
+	}
+
+	args := []string{"raw", hexByte(int(netfn)), hexByte(int(cmd))}
+	for _, b := range data {
+		args = append(args, hexByte(int(b)))
+	}
+	return fc.ipmitool(args...)
+}
+
+// ipmiRawRead is ipmiRaw for requests whose response data is needed.
+// ipmitool prints the data as hex bytes (" 01" or " 00 2f 01").
+func (fc *FanController) ipmiRawRead(netfn, cmd byte, data ...byte) ([]byte, error) {
+	if fc.native != nil && fc.native.Usable() {
+		ctx, cancel := context.WithTimeout(context.Background(), fc.commandTimeout())
+		resp, err := fc.native.Raw(ctx, netfn, cmd, data)
+		cancel()
+		var cerr *ipmi.CompletionError
+		if err == nil || errors.As(err, &cerr) {
+			return resp, err
+		}
+		log.Printf("Native IPMI request failed (%v); falling back to ipmitool", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fc.commandTimeout())
+	defer cancel()
+	args, env := fc.cfg.IDRAC.IpmitoolConnection()
+	args = append(args, "raw", hexByte(int(netfn)), hexByte(int(cmd)))
+	for _, b := range data {
+		args = append(args, hexByte(int(b)))
+	}
+	out, err := fc.runOutput(ctx, env, "ipmitool", args...)
+	if err != nil {
+		return nil, err
+	}
+	var resp []byte
+	for _, field := range strings.Fields(out) {
+		b, err := strconv.ParseUint(field, 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected ipmitool raw output %q", strings.TrimSpace(out))
+		}
+		resp = append(resp, byte(b))
+	}
+	return resp, nil
+}
+
+// ipmitool runs an ipmitool command against the configured BMC (local or
 // remote) with a deadline.
 func (fc *FanController) ipmitool(rawArgs ...string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), fc.commandTimeout())
 	defer cancel()
 
-	var args []string
-	if fc.cfg.IDRAC.Host != "local" {
-		args = append(args,
-			"-I", "lanplus",
-			"-H", fc.cfg.IDRAC.Host,
-			"-U", fc.cfg.IDRAC.Username,
-			"-P", fc.cfg.IDRAC.Password,
-		)
-	}
+	args, env := fc.cfg.IDRAC.IpmitoolConnection()
 	args = append(args, rawArgs...)
-	return fc.runCommand(ctx, "ipmitool", args...)
+	return fc.runCommand(ctx, env, "ipmitool", args...)
 }
 
 func (fc *FanController) calculateTarget(cpuReading *monitor.CPUReading, gpuReading *monitor.GPUReading) int {
@@ -478,7 +821,33 @@ func (fc *FanController) calculateTarget(cpuReading *monitor.CPUReading, gpuRead
 	defer fc.mu.Unlock()
 
 	cpuMax := cpuReading.Max
-	gpuMax := gpuReading.Max
+
+	cpuThreshold := fc.cfg.FanControl.EffectiveCPUThreshold()
+	gpuThreshold := fc.cfg.FanControl.EffectiveGPUThreshold()
+
+	// Inlet compensation: the control below works on the temperatures shifted
+	// by ambient, which moves every threshold, setpoint and zone by the same
+	// amount. The critical check keeps the real temperatures. The GPU
+	// temperature is the cards folded onto the gpu_threshold scale (gpus.go).
+	fc.ambient = fc.ambientFor(fc.chassisAir(cpuReading))
+	fc.gpu = fc.gpuViewFor(gpuReading)
+	ctlCPU := cpuMax + fc.ambient.shift
+	ctlGPU := fc.gpu.temp + fc.ambient.shift
+
+	// Trend feed-forward view for this tick. Computed before the critical and
+	// override branches so the reported crossing estimates never go stale; those
+	// branches clear the crossing flag, since pre_ramp must only claim a
+	// pre-ramp when feed-forward actually decided the speed.
+	pred := fc.predict(ctlCPU, ctlGPU, cpuThreshold, gpuThreshold)
+	fc.prediction = pred
+
+	// A thermally throttling GPU is over threshold whatever its temperature
+	// reads: it is already slowing itself down to stay cool, the clearest sign
+	// the chassis fans are too slow. It counts like one degree over, in every
+	// mode and fan group.
+	if fc.gpu.throttled {
+		ctlGPU = max(ctlGPU, gpuThreshold+1)
+	}
 
 	// Safety first: a critical temperature bypasses step ramping AND any manual
 	// override, driving fans straight to MaxSpeed (effectively 100%). The
@@ -486,13 +855,16 @@ func (fc *FanController) calculateTarget(cpuReading *monitor.CPUReading, gpuRead
 	// data — that way a bad zones list can never turn the "emergency" into a low
 	// speed. Leaving fans pinned low during a thermal emergency is exactly what
 	// this controller must never do, so critical cooling wins over everything.
-	if fc.cfg.IsCritical(cpuMax, gpuMax) {
+	if fc.cfg.IsCritical(cpuMax, fc.gpu.critTemp) || fc.sensorCritical() {
 		speed := fc.cfg.FanControl.MaxSpeed
 		if speed <= 0 || speed > 100 {
 			speed = 100
 		}
 		fc.currentZone = "critical"
 		fc.targetSpeed = speed
+		fc.resetPID()
+		fc.pinGroups(speed)
+		fc.prediction.crossing = false
 		return speed
 	}
 
@@ -505,14 +877,12 @@ func (fc *FanController) calculateTarget(cpuReading *monitor.CPUReading, gpuRead
 	if fc.override != nil {
 		speed := fc.clampSpeed(fc.override.Speed)
 		fc.targetSpeed = speed
+		fc.resetPID()
+		fc.pinGroups(speed)
+		fc.prediction.crossing = false
 		return speed
 	}
 
-	// Simple threshold-based control with hysteresis
-	// 1. Start with idle speed
-	// 2. If CPU or GPU exceeds threshold, increase fan speed
-	// 3. Only decrease after cooldown period below threshold
-
 	baseSpeed := fc.cfg.FanControl.IdleSpeed
 	if baseSpeed == 0 {
 		baseSpeed = 20 // Default idle speed
@@ -527,15 +897,12 @@ func (fc *FanController) calculateTarget(cpuReading *monitor.CPUReading, gpuRead
 	}
 
 	// Check if we're over thresholds
-	cpuThreshold := fc.cfg.FanControl.EffectiveCPUThreshold()
-	gpuThreshold := fc.cfg.FanControl.EffectiveGPUThreshold()
-
-	overThreshold := cpuMax > cpuThreshold || gpuMax > gpuThreshold
+	overThreshold := ctlCPU > cpuThreshold || ctlGPU > gpuThreshold
 
 	// Determine zone name for display
-	if cpuMax > 80 || gpuMax > 85 {
+	if ctlCPU > 80 || ctlGPU > 85 {
 		fc.currentZone = "hot"
-	} else if cpuMax > 70 || gpuMax > 75 {
+	} else if ctlCPU > 70 || ctlGPU > 75 {
 		fc.currentZone = "warm"
 	} else if overThreshold {
 		fc.currentZone = "active"
@@ -543,6 +910,63 @@ func (fc *FanController) calculateTarget(cpuReading *monitor.CPUReading, gpuRead
 		fc.currentZone = "idle"
 	}
 
+	var target int
+	switch fc.cfg.FanControl.EffectiveMode() {
+	case config.ModePID:
+		target = fc.pidTarget(ctlCPU, ctlGPU, baseSpeed)
+	case config.ModeCurve:
+		// Overwrites currentZone with the zone the curve actually derived.
+		target = fc.curveTarget(ctlCPU, ctlGPU, pred)
+	default:
+		target = fc.stepTarget(ctlCPU, ctlGPU, cpuThreshold, gpuThreshold, baseSpeed, pred)
+	}
+
+	// In degraded mode the lost source fed the mode no temperature; its PID
+	// loop must not resume from that when the source comes back.
+	if fc.degraded && fc.cpuHealth.err != nil {
+		fc.cpuPID.reset()
+	}
+	if fc.degraded && fc.gpuHealth.err != nil {
+		fc.gpuPID.reset()
+	}
+
+	// A named sensor over its threshold sets a floor too, in every mode.
+	sensorSpeed, sensorOver := fc.sensorDemand()
+	if sensorOver && fc.currentZone == "idle" {
+		fc.currentZone = "active"
+	}
+	floor := max(max(hintMinSpeed, sensorSpeed), max(fc.ambient.deltaFloor, fc.degradedFloor()))
+
+	// Apply the floor (workload hints, named sensors, chassis load and a
+	// source lost in degraded mode)
+	if floor > target {
+		target = floor
+	}
+
+	// A failed fan moves no air: the others make up for it.
+	target += fc.fanCompensation()
+
+	// Clamp to configured limits
+	target = max(fc.cfg.FanControl.MinSpeed, min(fc.cfg.FanControl.MaxSpeed, target))
+
+	fc.targetSpeed = target
+	fc.planGroups(ctlCPU, ctlGPU, pred, floor)
+	return target
+}
+
+// stepTarget is the threshold-based control with hysteresis:
+//  1. Start with idle speed
+//  2. If CPU or GPU exceeds threshold, increase fan speed
+//  3. Only decrease after cooldown period below threshold
+//
+// With predictive feed-forward enabled, a predicted crossing counts as over
+// threshold (one step above idle, and it restarts the cooldown timer), and a
+// still-rising trend holds the speed instead of ramping down.
+//
+// Callers hold fc.mu.
+func (fc *FanController) stepTarget(cpuMax, gpuMax, cpuThreshold, gpuThreshold, baseSpeed int, pred prediction) int {
+	overThreshold := cpuMax > cpuThreshold || gpuMax > gpuThreshold || pred.crossing
+
 	stepSize := fc.cfg.FanControl.StepSize
 	if stepSize == 0 {
 		stepSize = 10
@@ -578,6 +1002,8 @@ func (fc *FanController) calculateTarget(cpuReading *monitor.CPUReading, gpuRead
 		if fc.lastOverThreshold.IsZero() {
 			// Never been over threshold - go directly to idle speed
 			target = baseSpeed
+		} else if pred.rising {
+			// Still heating: hold rather than ramp down into the next crossing
 		} else if now.Sub(fc.lastOverThreshold) > cooldownDuration {
 			// Cooldown complete, can ramp down toward idle
 			if target > baseSpeed {
@@ -592,46 +1018,13 @@ func (fc *FanController) calculateTarget(cpuReading *monitor.CPUReading, gpuRead
 		// Otherwise hold current speed during cooldown (target = currentSpeed, unchanged)
 	}
 
-	// Apply hint minimum (workload hint sets a floor)
-	if hintMinSpeed > target {
-		target = hintMinSpeed
-	}
-
-	// Clamp to configured limits
-	target = max(fc.cfg.FanControl.MinSpeed, min(fc.cfg.FanControl.MaxSpeed, target))
-
-	fc.targetSpeed = target
 	return target
 }
 
-func (fc *FanController) calculateTrend(history []tempPoint) float64 {
-	if len(history) < 2 {
-		return 0
-	}
-
-	// Look at last 60 seconds
-	cutoff := time.Now().Add(-60 * time.Second)
-	var recent []tempPoint
-	for _, p := range history {
-		if p.timestamp.After(cutoff) {
-			recent = append(recent, p)
-		}
-	}
-
-	if len(recent) < 2 {
-		return 0
-	}
-
-	// Simple linear regression
-	first := recent[0]
-	last := recent[len(recent)-1]
-	duration := last.timestamp.Sub(first.timestamp).Minutes()
-
-	if duration < 0.1 {
-		return 0
-	}
-
-	return float64(last.temp-first.temp) / duration
+// resetPID clears both PID loops. Callers hold fc.mu.
+func (fc *FanController) resetPID() {
+	fc.cpuPID.reset()
+	fc.gpuPID.reset()
 }
 
 func (fc *FanController) trimHistory() {
@@ -669,13 +1062,14 @@ func (fc *FanController) cleanExpired() {
 }
 
 func (fc *FanController) setFanSpeed(speed int) error {
-	// Convert percentage to hex (0-100 -> 0x00-0x64)
-	hexSpeed := fmt.Sprintf("0x%02x", speed)
+	if len(fc.cfg.FanControl.Groups) > 0 {
+		return fc.setGroupedFanSpeed(speed)
+	}
 
 	// currentSpeed must reflect what the fans are ACTUALLY set to, so it is only
 	// updated after a confirmed successful write. A failed write is surfaced via
 	// lastWriteFailed in /api/status.
-	if err := fc.ipmitool("raw", "0x30", "0x30", "0x02", "0xff", hexSpeed); err != nil {
+	if err := fc.act.setAll(speed); err != nil {
 		fc.mu.Lock()
 		fc.lastWriteFailed = true
 		fc.mu.Unlock()
@@ -690,11 +1084,13 @@ func (fc *FanController) setFanSpeed(speed int) error {
 }
 
 func (fc *FanController) enableManualMode() error {
-	return fc.ipmitool("raw", "0x30", "0x30", "0x01", "0x00")
+	return fc.act.enableManual()
 }
 
+// RestoreAutoMode hands fan control back to the BMC (or, for hwmon, the pwm
+// chip) through the configured actuator backend.
 func (fc *FanController) RestoreAutoMode() error {
-	return fc.ipmitool("raw", "0x30", "0x30", "0x01", "0x01")
+	return fc.act.restoreAuto()
 }
 
 // AddHint registers a workload hint
@@ -788,11 +1184,21 @@ func (fc *FanController) GetStatus() *Status {
 		mode = "hinted"
 	}
 
+	cpuFit := fc.calculateTrend(fc.cpuHistory)
+	gpuFit := fc.calculateTrend(fc.gpuHistory)
+
 	// Build threshold info for dashboard
 	cpuThreshold := fc.cfg.FanControl.EffectiveCPUThreshold()
 	gpuThreshold := fc.cfg.FanControl.EffectiveGPUThreshold()
 
-	return &Status{
+	inlet, exhaust := fc.chassisAir(fc.lastCPUReading)
+	var delta *int
+	if inlet != nil && exhaust != nil {
+		d := *exhaust - *inlet
+		delta = &d
+	}
+
+	st := &Status{
 		Timestamp:       time.Now(),
 		CPU:             fc.lastCPUReading,
 		GPU:             fc.lastGPUReading,
@@ -800,18 +1206,61 @@ func (fc *FanController) GetStatus() *Status {
 		TargetSpeed:     fc.targetSpeed,
 		Zone:            fc.currentZone,
 		Mode:            mode,
+		ControlMode:     fc.cfg.FanControl.EffectiveMode(),
+		Backend:         fc.act.name(),
 		ActiveHints:     hints,
 		Override:        fc.override,
-		CPUTrend:        fc.calculateTrend(fc.cpuHistory),
-		GPUTrend:        fc.calculateTrend(fc.gpuHistory),
+		CPUTrend:        cpuFit.Slope,
+		GPUTrend:        gpuFit.Slope,
+		CPUCrossingIn:   fc.prediction.cpuCrossingIn,
+		GPUCrossingIn:   fc.prediction.gpuCrossingIn,
+		PreRamp:         fc.prediction.crossing,
+		CPUTrendR2:      cpuFit.R2,
+		GPUTrendR2:      gpuFit.R2,
+		CPUTrendSamples: cpuFit.Samples,
+		GPUTrendSamples: gpuFit.Samples,
 		Zones:           fc.cfg.Zones,
 		CPUThreshold:    cpuThreshold,
 		GPUThreshold:    gpuThreshold,
 		IdleSpeed:       fc.cfg.FanControl.IdleSpeed,
 		FailsafeActive:  fc.failsafeCause != failsafeNone,
 		FailsafeReason:  fc.failsafeCause.String(),
-		RestorePending:  fc.failsafeCause != failsafeNone && !fc.restoreConfirmed,
+		RestorePending:  fc.failsafeCause.handsBack() && !fc.restoreConfirmed,
 		LastWriteFailed: fc.lastWriteFailed,
+		FanGroups:       fc.groupStatus(),
+		Sensors:         fc.sensorStatus(),
+		InletTemp:       inlet,
+		ExhaustTemp:     exhaust,
+		AirflowDelta:    delta,
+		AmbientShift:    fc.ambient.shift,
+		GPUThrottled:    fc.gpu.throttled,
+		GPUDriver:       fc.gpu.driver,
+		Plausibility:    fc.plausStatus,
+		Degraded:        fc.degraded,
+		Fans:            slices.Clone(fc.fanCheck.fans),
+		FanVerifyFault:  fc.fanCheck.fault,
+		FanFailure:      len(fc.fanCheck.failed) > 0,
+		FailedFans:      slices.Clone(fc.fanCheck.failed),
+		FanCompensation: fc.fanCompensation(),
+		RestartRequired: slices.Clone(fc.restartRequired),
+	}
+	if fc.cfg.FanControl.WriteRecovery.Enabled {
+		st.WriteRecovery = fc.writeRec.status(fc.cfg.FanControl.WriteRecovery)
+	}
+	st.ModeReverts = fc.modeCheck.reverts
+	if !fc.modeCheck.lastRevert.IsZero() {
+		last := fc.modeCheck.lastRevert
+		st.LastModeRevert = &last
+	}
+	return st
+}
+
+// gpuTelemetry is the GPU cooling telemetry stored with each reading.
+func gpuTelemetry(r *monitor.GPUReading) storage.GPUTelemetry {
+	return storage.GPUTelemetry{
+		MemoryTemp: r.MaxMemoryTemp(),
+		FanSpeed:   r.MaxFanSpeed(),
+		Throttled:  r.ThermalThrottled(),
 	}
 }
 
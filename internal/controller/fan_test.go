@@ -3,6 +3,8 @@ package controller
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
 	"path/filepath"
 	"testing"
 	"time"
@@ -173,19 +175,29 @@ func TestCriticalRampIgnoresOperatorZoneData(t *testing.T) {
 // cmdRecorder captures the ipmitool invocations made through runCommand.
 type cmdRecorder struct {
 	cmds         [][]string
+	envs         [][]string // env handed to each command, parallel to cmds
 	failOnFanSet bool
-	failRestore  bool // simulate an unreachable BMC: RestoreAutoMode (0x01 0x01) fails
+	failRestore  bool   // simulate an unreachable BMC: RestoreAutoMode (0x01 0x01) fails
+	failFan      string // fail per-fan writes addressed to this fan index (e.g. "0x04")
+	failProbe    bool   // fail the read-only BMC probe (raw 0x06 0x01)
 }
 
-func (r *cmdRecorder) run(_ context.Context, name string, args ...string) error {
+func (r *cmdRecorder) run(_ context.Context, env []string, name string, args ...string) error {
+	r.envs = append(r.envs, env)
 	call := append([]string{name}, args...)
 	r.cmds = append(r.cmds, call)
 	if r.failOnFanSet && containsArg(args, "0x02") {
 		return errors.New("simulated ipmitool fan-set failure")
 	}
+	if r.failFan != "" && containsArg(args, "0x02") && len(args) >= 2 && args[len(args)-2] == r.failFan {
+		return errors.New("simulated ipmitool per-fan write failure")
+	}
 	if r.failRestore && endsWith(call, "0x01", "0x01") {
 		return errors.New("simulated unreachable BMC on RestoreAutoMode")
 	}
+	if r.failProbe && endsWith(call, "0x06", "0x01") {
+		return errors.New("simulated unreachable BMC on probe")
+	}
 	return nil
 }
 
@@ -411,6 +423,74 @@ func TestSensorFailsafeRecoversAndReclaimsManual(t *testing.T) {
 	}
 }
 
+func TestSafeSpeedStageBeforeHandBack(t *testing.T) {
+	rec := &cmdRecorder{}
+	cfg := testConfig()
+	cfg.FanControl.SensorFailureLimit = 2
+	cfg.FanControl.SafeSpeed = 60
+	cfg.FanControl.SafeSpeedFailures = 2
+	fc := NewFanController(cfg, failingCPU{}, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = rec.run
+	fc.currentSpeed = 30
+
+	fc.controlLoop()
+	if rec.fanSetCount() != 0 || fc.currentFailsafeCause() != failsafeNone {
+		t.Fatalf("first failure wrote %d speeds, fail-safe %s; want speed held", rec.fanSetCount(), fc.currentFailsafeCause())
+	}
+
+	// Failures 2 and 3: the fans sit at the safe speed, still under manual
+	// control.
+	fc.controlLoop()
+	fc.controlLoop()
+	st := fc.GetStatus()
+	if st.FailsafeReason != "safe-speed" || !st.FailsafeActive || st.RestorePending || st.CurrentSpeed != 60 {
+		t.Fatalf("status = reason %q active %v pending %v speed %d; want safe-speed at 60%%",
+			st.FailsafeReason, st.FailsafeActive, st.RestorePending, st.CurrentSpeed)
+	}
+	if rec.restoreCount() != 0 || rec.fanSetCount() != 2 {
+		t.Fatalf("restores %d, fan writes %d; want the safe speed written each tick and no hand-back", rec.restoreCount(), rec.fanSetCount())
+	}
+
+	// Failure 4 is safe_speed_failures past the limit: hand back to the BMC.
+	fc.controlLoop()
+	if fc.currentFailsafeCause() != failsafeSensor || rec.restoreCount() != 1 {
+		t.Fatalf("fail-safe %s after %d restores; want sensor-loss with one restore", fc.currentFailsafeCause(), rec.restoreCount())
+	}
+}
+
+func TestSafeSpeedWriteFailureEscalates(t *testing.T) {
+	rec := &cmdRecorder{failOnFanSet: true}
+	cfg := testConfig()
+	cfg.FanControl.SensorFailureLimit = 1
+	cfg.FanControl.SafeSpeed = 60
+	fc := NewFanController(cfg, failingCPU{}, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = rec.run
+
+	fc.controlLoop()
+	if fc.currentFailsafeCause() != failsafeSensor || rec.restoreCount() != 1 {
+		t.Fatalf("fail-safe %s with %d restores; want the failed safe-speed write to hand back at once", fc.currentFailsafeCause(), rec.restoreCount())
+	}
+}
+
+func TestSafeSpeedStageRecovers(t *testing.T) {
+	rec := &cmdRecorder{}
+	cfg := testConfig()
+	cfg.FanControl.SensorFailureLimit = 1
+	cfg.FanControl.SafeSpeed = 60
+	fc := NewFanController(cfg, &flakyCPU{failFor: 1, max: 50}, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = rec.run
+
+	fc.controlLoop()
+	if fc.currentFailsafeCause() != failsafeSafeSpeed {
+		t.Fatalf("fail-safe = %s, want safe-speed", fc.currentFailsafeCause())
+	}
+	fc.controlLoop()
+	if fc.currentFailsafeCause() != failsafeNone || rec.manualModeCount() != 0 || rec.restoreCount() != 0 {
+		t.Fatalf("fail-safe %s, manual reclaims %d, restores %d; want a clean return to control without touching the BMC mode",
+			fc.currentFailsafeCause(), rec.manualModeCount(), rec.restoreCount())
+	}
+}
+
 // TestFailsafeRetriesRestoreUntilConfirmed reproduces an unreachable BMC: the
 // very condition that trips fail-safe also fails RestoreAutoMode. The controller
 // must keep retrying every tick (not give up after one attempt) and report the
@@ -612,3 +692,571 @@ func TestCriticalOverridesClampedOverride(t *testing.T) {
 		t.Fatalf("critical ramp did not override clamped override: got %d, want 100", got)
 	}
 }
+
+// --- PID control mode ---
+
+// settableCPU returns whatever temperature the test last assigned, so a single
+// controller can be driven through a heat-up/cool-down sequence.
+type settableCPU struct{ max int }
+
+func (s *settableCPU) Read() (*monitor.CPUReading, error) {
+	return &monitor.CPUReading{Temps: []int{s.max}, Max: s.max}, nil
+}
+
+func pidConfig() *config.Config {
+	cfg := testConfig()
+	cfg.FanControl.Mode = config.ModePID
+	cfg.FanControl.CPUThreshold = 65 // effective CPU setpoint
+	cfg.FanControl.GPUThreshold = 60 // effective GPU setpoint
+	cfg.FanControl.PID = config.PIDConfig{
+		Kp:            2,
+		Ki:            0.02,
+		Kd:            0,
+		IntegralLimit: 30,
+		MaxStepUp:     15,
+		MaxStepDown:   5,
+	}
+	return cfg
+}
+
+func TestPIDModeHoldsIdleBelowSetpoint(t *testing.T) {
+	rec := &cmdRecorder{}
+	fc := NewFanController(pidConfig(), staticCPU{max: 50}, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = rec.run
+
+	for i := 0; i < 5; i++ {
+		fc.controlLoop()
+	}
+	if fc.currentSpeed != 20 {
+		t.Fatalf("PID below setpoint should sit at idle speed: got %d, want 20", fc.currentSpeed)
+	}
+	if got := fc.GetStatus().ControlMode; got != config.ModePID {
+		t.Fatalf("status control_mode = %q, want %q", got, config.ModePID)
+	}
+}
+
+func TestPIDModeSlewLimitsRampUp(t *testing.T) {
+	rec := &cmdRecorder{}
+	cpu := &settableCPU{max: 50}
+	fc := NewFanController(pidConfig(), cpu, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = rec.run
+
+	fc.controlLoop() // settle at idle (20%)
+	cpu.max = 84     // 19°C over setpoint: proportional demand alone is ~38%
+	fc.controlLoop()
+	if fc.currentSpeed != 35 {
+		t.Fatalf("ramp-up not slew-limited: got %d, want 20+15=35", fc.currentSpeed)
+	}
+	fc.controlLoop()
+	if fc.currentSpeed <= 35 || fc.currentSpeed > 50 {
+		t.Fatalf("second tick should keep climbing by at most max_step_up: got %d", fc.currentSpeed)
+	}
+}
+
+// TestPIDModeIntegralAntiWindup holds the CPU far above setpoint long enough
+// to saturate the integral, then drops it below setpoint. The integral must be
+// capped at integral_limit, and the fans must come back down at the slew rate
+// instead of staying pinned while a wound-up integral unwinds.
+func TestPIDModeIntegralAntiWindup(t *testing.T) {
+	rec := &cmdRecorder{}
+	cfg := pidConfig()
+	cpu := &settableCPU{max: 84}
+	fc := NewFanController(cfg, cpu, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = rec.run
+
+	for i := 0; i < 200; i++ {
+		fc.controlLoop()
+	}
+	if got := cfg.FanControl.PID.Ki * fc.cpuPID.integral; got > cfg.FanControl.PID.IntegralLimit+1e-9 {
+		t.Fatalf("integral term wound up past its limit: %.2f%% > %.2f%%", got, cfg.FanControl.PID.IntegralLimit)
+	}
+	peak := fc.currentSpeed
+
+	cpu.max = 50
+	fc.controlLoop()
+	if fc.currentSpeed != peak-cfg.FanControl.PID.MaxStepDown {
+		t.Fatalf("fans did not start ramping down at the slew rate once below setpoint: %d -> %d", peak, fc.currentSpeed)
+	}
+	for i := 0; i < 40; i++ {
+		fc.controlLoop()
+	}
+	if fc.currentSpeed != 20 {
+		t.Fatalf("fans did not return to idle after cooling: got %d, want 20", fc.currentSpeed)
+	}
+}
+
+// TestPIDUsesMeasuredTimeBetweenSamples: after ticks lost to a failing sensor,
+// the derivative must spread the change over the real gap, not one interval.
+func TestPIDUsesMeasuredTimeBetweenSamples(t *testing.T) {
+	gains := config.PIDConfig{Kd: 10}
+	var p pidState
+	t0 := time.Now()
+	p.update(60, 65, t0, 10, gains)
+	got := p.update(66, 65, t0.Add(30*time.Second), 10, gains) // two ticks skipped
+	if math.Abs(got-2) > 1e-9 {
+		t.Fatalf("derivative demand over a 30s gap = %.2f, want 10*6/30 = 2", got)
+	}
+}
+
+func TestPIDResetOnFailsafe(t *testing.T) {
+	rec := &cmdRecorder{}
+	cfg := pidConfig()
+	cfg.FanControl.SensorFailureLimit = 1
+	cpu := &flakyCPU{max: 80}
+	fc := NewFanController(cfg, cpu, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = rec.run
+
+	fc.controlLoop() // healthy tick primes the loop
+	if !fc.cpuPID.primed || fc.cpuPID.integral == 0 {
+		t.Fatal("expected PID state after a hot tick")
+	}
+	cpu.failFor = cpu.calls + 1
+	fc.controlLoop() // sensor loss -> fail-safe
+	if fc.cpuPID.primed || fc.cpuPID.integral != 0 {
+		t.Fatalf("PID state survived fail-safe entry: %+v", fc.cpuPID)
+	}
+}
+
+func TestPIDModeRespectsCriticalAndOverride(t *testing.T) {
+	rec := &cmdRecorder{}
+	cpu := &settableCPU{max: 70}
+	fc := NewFanController(pidConfig(), cpu, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = rec.run
+
+	fc.controlLoop()
+	fc.SetOverride(30, time.Hour, "manual")
+	fc.controlLoop()
+	if fc.currentSpeed != 30 {
+		t.Fatalf("override not honored in PID mode: got %d, want 30", fc.currentSpeed)
+	}
+	if fc.cpuPID.primed {
+		t.Fatal("PID state should be reset while the override bypasses it")
+	}
+
+	cpu.max = 95 // past critical_cpu_temp
+	fc.controlLoop()
+	if fc.currentSpeed != 100 {
+		t.Fatalf("critical temp did not bypass PID + override: got %d, want 100", fc.currentSpeed)
+	}
+}
+
+// --- curve control mode ---
+
+func TestCurveModeInterpolatesZones(t *testing.T) {
+	// Default zones: idle 45/40->10, normal 60/70->25, warm 70/80->45,
+	// hot 80/85->70, critical 999/999->100.
+	tests := []struct {
+		name     string
+		cpu, gpu int
+		want     int
+		wantZone string
+	}{
+		{name: "below first point uses first zone speed", cpu: 30, gpu: 30, want: 10, wantZone: "idle"},
+		{name: "cpu midway between idle and normal", cpu: 52, gpu: 30, want: 17, wantZone: "idle"}, // 10 + 7/15*15
+		{name: "cpu exactly on a zone point", cpu: 70, gpu: 30, want: 45, wantZone: "warm"},
+		{name: "gpu demand wins when hotter", cpu: 40, gpu: 75, want: 35, wantZone: "normal"}, // 25 + 5/10*20
+		{name: "cpu above hot reports hot, not critical", cpu: 82, gpu: 30, want: 70, wantZone: "hot"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.FanControl.Mode = config.ModeCurve
+			cfg.FanControl.MinSpeed = 0
+			fc := NewFanController(cfg, nil, nil, nil)
+			cpuR, gpuR := cpuGpu(tt.cpu, tt.gpu)
+			if got := fc.calculateTarget(cpuR, gpuR); got != tt.want {
+				t.Fatalf("calculateTarget(cpu=%d, gpu=%d) = %d, want %d", tt.cpu, tt.gpu, got, tt.want)
+			}
+			if fc.currentZone != tt.wantZone {
+				t.Fatalf("zone = %q, want %q", fc.currentZone, tt.wantZone)
+			}
+		})
+	}
+}
+
+// TestCurveModeEmergencyOnlyFromIsCritical proves the curve never decides the
+// emergency ramp: a curve whose top zone asks for a low speed still yields
+// MaxSpeed once IsCritical trips, and a temperature inside the curve's "critical"
+// zone but below critical_cpu_temp does not.
+func TestCurveModeEmergencyOnlyFromIsCritical(t *testing.T) {
+	cfg := testConfig()
+	cfg.FanControl.Mode = config.ModeCurve
+	cfg.Zones = []config.Zone{
+		{Name: "idle", CPUMax: 45, GPUMax: 40, FanSpeed: 10},
+		{Name: "critical", CPUMax: 60, GPUMax: 60, FanSpeed: 30},
+	}
+	fc := NewFanController(cfg, nil, nil, nil)
+
+	cpuR, gpuR := cpuGpu(70, 30) // past the curve's top point, below critical_cpu_temp
+	if got := fc.calculateTarget(cpuR, gpuR); got != 30 {
+		t.Fatalf("curve above its last point should hold the last zone speed: got %d, want 30", got)
+	}
+
+	cpuR, gpuR = cpuGpu(95, 30) // IsCritical
+	if got := fc.calculateTarget(cpuR, gpuR); got != 100 {
+		t.Fatalf("critical temp did not force MaxSpeed in curve mode: got %d, want 100", got)
+	}
+}
+
+// --- predictive (trend feed-forward) ---
+
+// risingHistory seeds a CPU history rising linearly from `from` to `to` over the
+// last 48s, i.e. (to-from)*1.25 °C/min.
+func risingHistory(fc *FanController, from, to int) {
+	now := time.Now()
+	fc.cpuHistory = []tempPoint{
+		{temp: from, timestamp: now.Add(-48 * time.Second)},
+		{temp: to, timestamp: now},
+	}
+}
+
+// The curve's lookahead must treat the same rises as noise as predict does,
+// including with min_trend left at 0 (a default of 1°C/min).
+func TestLookaheadUsesEffectiveMinTrend(t *testing.T) {
+	cfg := testConfig()
+	cfg.FanControl.Predictive = config.PredictiveConfig{Horizon: 60}
+	fc := NewFanController(cfg, staticCPU{max: 40}, staticGPU{max: 40}, newTestStore(t))
+
+	if got := fc.lookahead(60, 0.5); got != 60 {
+		t.Fatalf("lookahead at 0.5°C/min = %d, want 60 (below the default min_trend of 1)", got)
+	}
+	if got := fc.lookahead(60, 2); got != 62 {
+		t.Fatalf("lookahead at 2°C/min = %d, want 62", got)
+	}
+}
+
+func predictiveConfig() *config.Config {
+	cfg := testConfig()
+	cfg.FanControl.Predictive = config.PredictiveConfig{Enabled: true, Horizon: 60, MinTrend: 1.0}
+	return cfg
+}
+
+func TestPredictivePreRampsBeforeThreshold(t *testing.T) {
+	fc := NewFanController(predictiveConfig(), nil, nil, nil)
+	fc.currentSpeed = 20
+	risingHistory(fc, 58, 62) // 5°C/min, cpu_threshold 65 predicted in ~36s
+
+	cpuR, gpuR := cpuGpu(62, 35)
+	if got := fc.calculateTarget(cpuR, gpuR); got != 30 {
+		t.Fatalf("predicted crossing did not pre-ramp one step: got %d, want 30", got)
+	}
+	status := fc.GetStatus()
+	if !status.PreRamp {
+		t.Fatal("status should report pre_ramp while a crossing is predicted")
+	}
+	if status.CPUCrossingIn == nil || *status.CPUCrossingIn < 30 || *status.CPUCrossingIn > 40 {
+		t.Fatalf("cpu_crossing_in = %v, want ~36s", status.CPUCrossingIn)
+	}
+}
+
+func TestPredictiveDisabledOnlyReportsCrossing(t *testing.T) {
+	fc := NewFanController(testConfig(), nil, nil, nil)
+	fc.currentSpeed = 20
+	risingHistory(fc, 58, 62)
+
+	cpuR, gpuR := cpuGpu(62, 35)
+	if got := fc.calculateTarget(cpuR, gpuR); got != 20 {
+		t.Fatalf("feed-forward acted while disabled: got %d, want 20", got)
+	}
+	status := fc.GetStatus()
+	if status.PreRamp {
+		t.Fatal("pre_ramp must stay false while predictive mode is disabled")
+	}
+	if status.CPUCrossingIn == nil {
+		t.Fatal("cpu_crossing_in should still be reported as telemetry")
+	}
+}
+
+func TestPredictivePreRampClearedWhenOverridden(t *testing.T) {
+	fc := NewFanController(predictiveConfig(), nil, nil, nil)
+	fc.currentSpeed = 20
+	fc.override = &Override{Speed: 50}
+	risingHistory(fc, 58, 62)
+
+	cpuR, gpuR := cpuGpu(62, 35)
+	if got := fc.calculateTarget(cpuR, gpuR); got != 50 {
+		t.Fatalf("override should decide the speed: got %d, want 50", got)
+	}
+	status := fc.GetStatus()
+	if status.PreRamp {
+		t.Fatal("pre_ramp reported while the manual override decided the speed")
+	}
+	if status.CPUCrossingIn == nil {
+		t.Fatal("cpu_crossing_in should still be reported as telemetry under an override")
+	}
+}
+
+func TestPredictiveHoldsRampDownWhileRising(t *testing.T) {
+	fc := NewFanController(predictiveConfig(), nil, nil, nil)
+	fc.currentSpeed = 40
+	fc.lastOverThreshold = time.Now().Add(-2 * time.Minute) // cooldown elapsed
+	risingHistory(fc, 46, 50)                               // 5°C/min, crossing ~180s out (beyond horizon)
+
+	cpuR, gpuR := cpuGpu(50, 35)
+	if got := fc.calculateTarget(cpuR, gpuR); got != 40 {
+		t.Fatalf("ramp-down not held while trend still rising: got %d, want 40", got)
+	}
+}
+
+func TestPredictiveCurveLeadsOnRisingTrend(t *testing.T) {
+	cfg := predictiveConfig()
+	cfg.FanControl.Mode = config.ModeCurve
+	fc := NewFanController(cfg, nil, nil, nil)
+	risingHistory(fc, 52, 60) // 10°C/min -> heading for 70 within the 60s horizon
+
+	cpuR, gpuR := cpuGpu(60, 35)
+	if got := fc.calculateTarget(cpuR, gpuR); got != 45 {
+		t.Fatalf("curve did not lead on the predicted temperature: got %d, want 45 (warm @70)", got)
+	}
+	if fc.currentZone != "normal" {
+		t.Fatalf("zone should reflect the actual temperature: got %q, want normal", fc.currentZone)
+	}
+}
+
+func TestTrendFitIgnoresSingleSpike(t *testing.T) {
+	fc := NewFanController(testConfig(), nil, nil, nil)
+	now := time.Now()
+	// Flat at 50°C for a minute, with one noisy reading as the newest sample.
+	// First/last would report +40°C/min; the fit should stay close to flat.
+	for i := 0; i < 6; i++ {
+		fc.cpuHistory = append(fc.cpuHistory, tempPoint{temp: 50, timestamp: now.Add(time.Duration(i*10-55) * time.Second)})
+	}
+	fc.cpuHistory = append(fc.cpuHistory, tempPoint{temp: 58, timestamp: now})
+
+	fit := fc.calculateTrend(fc.cpuHistory)
+	if fit.Samples != 7 {
+		t.Fatalf("samples = %d, want 7", fit.Samples)
+	}
+	if fit.Slope <= 0 || fit.Slope > 10 {
+		t.Fatalf("slope = %.2f°C/min, want a small positive trend", fit.Slope)
+	}
+	if fit.R2 >= 0.6 {
+		t.Fatalf("R² = %.2f, want a poor fit for a lone spike", fit.R2)
+	}
+}
+
+func TestTrendFitWindowAndSmoothing(t *testing.T) {
+	cfg := testConfig()
+	cfg.Monitoring.TrendWindow = 30
+	fc := NewFanController(cfg, nil, nil, nil)
+	now := time.Now()
+	// A steady 6°C/min rise, plus an older point outside the window that would
+	// flatten the fit if it were included.
+	fc.cpuHistory = []tempPoint{
+		{temp: 80, timestamp: now.Add(-50 * time.Second)},
+		{temp: 50, timestamp: now.Add(-20 * time.Second)},
+		{temp: 51, timestamp: now.Add(-10 * time.Second)},
+		{temp: 52, timestamp: now},
+	}
+
+	fit := fc.calculateTrend(fc.cpuHistory)
+	if fit.Samples != 3 {
+		t.Fatalf("samples = %d, want 3 inside the 30s window", fit.Samples)
+	}
+	if math.Abs(fit.Slope-6) > 0.01 || math.Abs(fit.R2-1) > 1e-9 {
+		t.Fatalf("fit = %+v, want slope 6 with R² 1", fit)
+	}
+
+	fc.cfg.Monitoring.TrendSmoothing = 0.5
+	smoothed := fc.calculateTrend(fc.cpuHistory)
+	if smoothed.Slope >= fit.Slope || smoothed.Slope <= 0 {
+		t.Fatalf("smoothed slope = %.2f, want damped below %.2f", smoothed.Slope, fit.Slope)
+	}
+
+	fc.cfg.Monitoring.TrendSmoothing = 0.8
+	if heavier := fc.calculateTrend(fc.cpuHistory); heavier.Slope >= smoothed.Slope {
+		t.Fatalf("trend_smoothing 0.8 slope = %.2f, want smoother than 0.5's %.2f", heavier.Slope, smoothed.Slope)
+	}
+	fc.cfg.Monitoring.TrendSmoothing = 0.5
+
+	status := fc.GetStatus()
+	if status.CPUTrendSamples != 3 || status.CPUTrend != smoothed.Slope {
+		t.Fatalf("status trend = %.2f (%d samples), want %.2f (3)", status.CPUTrend, status.CPUTrendSamples, smoothed.Slope)
+	}
+}
+
+// --- fan groups ---
+
+// fanSpeeds maps each fan index written through the per-fan command to the last
+// speed it was set to, and reports whether the all-fans (0xff) form was used.
+func (r *cmdRecorder) fanSpeeds() (map[string]string, bool) {
+	speeds := make(map[string]string)
+	broadcast := false
+	for _, c := range r.cmds {
+		if !containsArg(c, "0x02") || len(c) < 2 {
+			continue
+		}
+		fan, speed := c[len(c)-2], c[len(c)-1]
+		if fan == "0xff" {
+			broadcast = true
+			continue
+		}
+		speeds[fan] = speed
+	}
+	return speeds, broadcast
+}
+
+// groupsConfig splits a 7-fan chassis into a CPU bank (fans 0-2) on the default
+// zones and a PCIe bank (fans 3-5) on its own, steeper GPU curve. Fan 6 is in
+// no group and follows the main strategy.
+func groupsConfig() *config.Config {
+	cfg := testConfig()
+	cfg.FanControl.FanCount = 7
+	cfg.FanControl.Groups = []config.FanGroup{
+		{Name: "cpu", Fans: []int{0, 1, 2}, Sources: []string{config.SourceCPU}},
+		{Name: "pcie", Fans: []int{3, 4, 5}, Sources: []string{config.SourceGPU}, Zones: []config.Zone{
+			{Name: "cool", CPUMax: 0, GPUMax: 40, FanSpeed: 30},
+			{Name: "hot", CPUMax: 0, GPUMax: 80, FanSpeed: 70},
+		}},
+	}
+	return cfg
+}
+
+func TestFanGroupsDriveEachBankFromItsOwnCurve(t *testing.T) {
+	rec := &cmdRecorder{}
+	fc := NewFanController(groupsConfig(), nil, nil, nil)
+	fc.runCommand = rec.run
+
+	cpuR, gpuR := cpuGpu(52, 75)
+	target := fc.calculateTarget(cpuR, gpuR)
+	if err := fc.setFanSpeed(target); err != nil {
+		t.Fatalf("setFanSpeed: %v", err)
+	}
+
+	speeds, broadcast := rec.fanSpeeds()
+	if broadcast {
+		t.Fatal("the all-fans command must not be used with fan groups; it drags every bank to the main speed")
+	}
+	want := map[string]string{
+		"0x00": "0x11", "0x01": "0x11", "0x02": "0x11", // cpu bank: 10 + 7/15*15 = 17
+		"0x03": "0x41", "0x04": "0x41", "0x05": "0x41", // pcie bank: 30 + 35/40*40 = 65
+		"0x06": fmt.Sprintf("0x%02x", target), // ungrouped: main step target
+	}
+	for fan, speed := range want {
+		if speeds[fan] != speed {
+			t.Errorf("fan %s set to %q, want %q", fan, speeds[fan], speed)
+		}
+	}
+
+	status := fc.GetStatus()
+	if len(status.FanGroups) != 2 {
+		t.Fatalf("status reports %d fan groups, want 2", len(status.FanGroups))
+	}
+	pcie := status.FanGroups[1]
+	if pcie.Name != "pcie" || pcie.TargetSpeed != 65 || pcie.CurrentSpeed == nil || *pcie.CurrentSpeed != 65 || pcie.LastWriteFailed {
+		t.Fatalf("pcie group status = %+v, want target/current 65 with no failure", pcie)
+	}
+}
+
+func TestFanGroupsPinnedByCriticalAndOverride(t *testing.T) {
+	fc := NewFanController(groupsConfig(), nil, nil, nil)
+
+	fc.override = &Override{Speed: 40}
+	cpuR, gpuR := cpuGpu(52, 75)
+	fc.calculateTarget(cpuR, gpuR)
+	for i, g := range fc.groups {
+		if g.targetSpeed != 40 {
+			t.Fatalf("group %d target = %d under override, want 40", i, g.targetSpeed)
+		}
+	}
+
+	cpuR, gpuR = cpuGpu(95, 40)
+	fc.calculateTarget(cpuR, gpuR)
+	for i, g := range fc.groups {
+		if g.targetSpeed != 100 {
+			t.Fatalf("group %d target = %d on critical temp, want 100", i, g.targetSpeed)
+		}
+	}
+}
+
+func TestFanGroupPartialWriteFailure(t *testing.T) {
+	rec := &cmdRecorder{failFan: "0x04"}
+	cfg := groupsConfig()
+	cfg.FanControl.WriteFailureLimit = 2
+	fc := NewFanController(cfg, staticCPU{max: 52}, staticGPU{max: 75}, newTestStore(t))
+	fc.runCommand = rec.run
+
+	fc.controlLoop()
+
+	status := fc.GetStatus()
+	pcie, cpu := status.FanGroups[1], status.FanGroups[0]
+	if !pcie.LastWriteFailed || pcie.CurrentSpeed != nil {
+		t.Fatalf("pcie group after fan 4 failed = %+v, want last_write_failed and unknown current speed", pcie)
+	}
+	if cpu.LastWriteFailed || cpu.CurrentSpeed == nil {
+		t.Fatalf("cpu group should be unaffected: %+v", cpu)
+	}
+	if !status.LastWriteFailed {
+		t.Fatal("a failed group write must surface as last_write_failed")
+	}
+
+	fc.controlLoop() // second failed tick reaches write_failure_limit
+	if fc.currentFailsafeCause() != failsafeWrite {
+		t.Fatalf("group write failures did not feed the write fail-safe: cause %v", fc.currentFailsafeCause())
+	}
+}
+
+func TestFanGroupHoldFollowsOwnSources(t *testing.T) {
+	cfg := groupsConfig()
+	cfg.FanControl.Predictive = config.PredictiveConfig{Enabled: true, Horizon: 60, MinTrend: 1.0}
+	fc := NewFanController(cfg, nil, nil, nil)
+	fc.groups = []groupState{
+		{currentSpeed: 60, currentKnown: true},
+		{currentSpeed: 60, currentKnown: true},
+	}
+	risingHistory(fc, 40, 44) // only the CPU is rising
+
+	cpuR, gpuR := cpuGpu(44, 40)
+	fc.calculateTarget(cpuR, gpuR)
+	if got := fc.groups[0].targetSpeed; got != 60 {
+		t.Fatalf("cpu group should hold while the CPU is rising: got %d, want 60", got)
+	}
+	if got := fc.groups[1].targetSpeed; got != 30 {
+		t.Fatalf("pcie group must not be held by a CPU-only rise: got %d, want 30", got)
+	}
+}
+
+// TestTrendFitR2IgnoresSmoothing checks R² is measured on the raw samples:
+// smoothing a zig-zag makes it look like a clean line, but the readings are
+// still just as noisy.
+func TestTrendFitR2IgnoresSmoothing(t *testing.T) {
+	fc := NewFanController(testConfig(), nil, nil, nil)
+	now := time.Now()
+	for i, temp := range []int{50, 56, 49, 57, 50, 58} {
+		fc.cpuHistory = append(fc.cpuHistory, tempPoint{temp: temp, timestamp: now.Add(time.Duration(i*10-50) * time.Second)})
+	}
+
+	raw := fc.calculateTrend(fc.cpuHistory)
+	fc.cfg.Monitoring.TrendSmoothing = 0.7
+	smoothed := fc.calculateTrend(fc.cpuHistory)
+	if smoothed.R2 > raw.R2 {
+		t.Fatalf("smoothing raised R² from %.2f to %.2f; it must be measured on the raw samples", raw.R2, smoothed.R2)
+	}
+	if raw.R2 >= 0.5 {
+		t.Fatalf("zig-zag samples fitted with R² %.2f, want a poor fit", raw.R2)
+	}
+}
+
+func TestThermalThrottleCountsAsOverThreshold(t *testing.T) {
+	fc := NewFanController(testConfig(), staticCPU{max: 40}, staticGPU{max: 50}, nil)
+	fc.currentSpeed = 20
+
+	// 50°C is well under gpu_threshold (60), but the card is holding its
+	// clocks down at its thermal limit.
+	cpuR, gpuR := cpuGpu(40, 50)
+	gpuR.Devices = []monitor.GPUDevice{{Index: 0, Temp: 50, ThrottleReasons: []string{"sw_thermal_slowdown"}}}
+	fc.lastGPUReading = gpuR
+	if got := fc.calculateTarget(cpuR, gpuR); got != 30 {
+		t.Fatalf("calculateTarget with a throttling GPU = %d, want one step over idle (30)", got)
+	}
+	if status := fc.GetStatus(); status.Zone != "active" || !status.GPUThrottled {
+		t.Fatalf("zone=%q gpu_throttled=%v, want active and true", status.Zone, status.GPUThrottled)
+	}
+
+	// A power cap is not thermal: no ramp.
+	fc.currentSpeed = 20
+	gpuR.Devices[0].ThrottleReasons = []string{"sw_power_cap"}
+	if got := fc.calculateTarget(cpuR, gpuR); got != 20 {
+		t.Fatalf("calculateTarget with a power-capped GPU = %d, want idle (20)", got)
+	}
+}
@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/monitor"
+)
+
+// fanReader reads the fan tachometers (the real implementation is
+// monitor.FanMonitor).
+type fanReader interface {
+	Read() ([]monitor.FanSpeed, error)
+}
+
+// FanStatus reports one fan tachometer (fan_control.verify).
+type FanStatus struct {
+	Name     string `json:"name"`
+	RPM      int    `json:"rpm"`
+	Stalled  bool   `json:"stalled"`  // below stall_rpm while commanded to spin
+	Mismatch bool   `json:"mismatch"` // further from the commanded speed than the tolerance allows
+	Failed   bool   `json:"failed"`   // stalled, or below failed_ratio of the RPM expected at the commanded speed
+}
+
+// fanVerify is the fan readback state. Guarded by mu.
+type fanVerify struct {
+	fans     []FanStatus
+	lo, hi   int             // the commanded speed range the fans are settling on
+	settled  int             // ticks that range has held
+	failures int             // consecutive failed checks
+	fault    string          // why the latest check failed; empty when it passed
+	failed   []string        // fans that have failed, which raises the others by the compensation
+	modeRead bool            // the fan mode was checked in the current run of failed checks
+	seen     map[string]bool // fans that have reported an RPM
+}
+
+// UseFans reads the fan tachometers through m every tick. Call it before Run.
+func (fc *FanController) UseFans(m *monitor.FanMonitor) {
+	fc.fanMon = m
+}
+
+// verifyFans reads the tachometers after a successful write and checks them
+// against the speed the fans were commanded to, once it has held for
+// settle_ticks. It returns each fan's RPM for the history. failure_limit
+// failed checks in a row hand cooling back to the BMC, and a fan that has
+// failed raises the others by the compensation until a check finds it
+// turning again. A failed tachometer read is logged and proves nothing either
+// way.
+func (fc *FanController) verifyFans() map[string]int {
+	cfg := fc.cfg.FanControl.Verify
+	if fc.fanMon == nil || !cfg.Enabled {
+		return nil
+	}
+	speeds, err := fc.fanMon.Read()
+	if err != nil {
+		log.Printf("Fan tachometer read failed: %v", err)
+		fc.mu.Lock()
+		fc.fanCheck.fans = nil
+		fc.mu.Unlock()
+		return nil
+	}
+
+	fc.mu.Lock()
+	v := &fc.fanCheck
+	speeds = v.present(speeds)
+	lo, hi := fc.commandedRange()
+	if lo != v.lo || hi != v.hi {
+		v.lo, v.hi, v.settled = lo, hi, 0
+	}
+	v.settled++
+	checked := v.settled > cfg.EffectiveSettleTicks()
+	v.fans, v.fault = fc.checkFans(speeds, lo, hi, checked)
+	if v.fault != "" {
+		v.failures++
+	} else if checked {
+		v.failures = 0
+	}
+	fault, failures := v.fault, v.failures
+	var failed, recovered []string
+	if checked {
+		failed, recovered = v.updateFailed()
+	}
+	// The fans ignoring the speed is also what a BMC that went back to auto
+	// looks like; with mode_watch that is checked, once per run of failures,
+	// before the failure counts.
+	readMode := fault != "" && !v.modeRead && fc.cfg.FanControl.ModeWatch.Enabled
+	if readMode {
+		v.modeRead = true
+	} else if fault == "" && checked {
+		v.modeRead = false
+	}
+	fc.mu.Unlock()
+
+	fc.reportFanFailures(failed, recovered)
+	if readMode && fc.revertedToAuto() {
+		fc.reassertManual("fans ignore the commanded speed: " + fault)
+		fc.mu.Lock()
+		fc.fanCheck.settled = 0
+		fc.mu.Unlock()
+	} else if fault != "" {
+		limit := cfg.EffectiveFailureLimit()
+		log.Printf("FAN VERIFY FAILURE %d/%d: %s", failures, limit, fault)
+		if failures >= limit {
+			fc.enterFailsafe(failsafeVerify)
+		}
+	}
+	rpms := make(map[string]int, len(speeds))
+	for _, s := range speeds {
+		rpms[s.Name] = s.RPM
+	}
+	return rpms
+}
+
+// present records the fans that report an RPM and drops the unreadable ones
+// that never have, a header without a fan. An unreadable fan that has read
+// before has stopped and stays in at 0 RPM. Callers hold fc.mu.
+func (v *fanVerify) present(speeds []monitor.FanSpeed) []monitor.FanSpeed {
+	if v.seen == nil {
+		v.seen = make(map[string]bool)
+	}
+	var out []monitor.FanSpeed
+	for _, s := range speeds {
+		if !s.Unreadable {
+			v.seen[s.Name] = true
+		} else if !v.seen[s.Name] {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// checkFans compares the tachometers with the commanded speed range lo..hi.
+// Until checked, each fan keeps the flags of the last check. A fan is stalled
+// below stall_rpm while commanded to spin, and (with max_rpm) mismatched when
+// its RPM is further than the tolerance from the range's share of max_rpm. A
+// stalled fan, or one below failed_ratio of the RPM expected at lo, has
+// failed. One failed fan is a hardware fault, not the BMC ignoring the
+// writes, so the check only fails when most fans are mismatched or every fan
+// is stalled. Callers hold fc.mu.
+func (fc *FanController) checkFans(speeds []monitor.FanSpeed, lo, hi int, checked bool) ([]FanStatus, string) {
+	cfg := fc.cfg.FanControl.Verify
+	stall := cfg.EffectiveStallRPM()
+	slack := int(cfg.EffectiveTolerance() * float64(cfg.MaxRPM))
+	minRPM, maxRPM := lo*cfg.MaxRPM/100-slack, hi*cfg.MaxRPM/100+slack
+	failRPM := int(cfg.EffectiveFailedRatio() * float64(lo*cfg.MaxRPM/100))
+
+	last := make(map[string]FanStatus)
+	for _, f := range fc.fanCheck.fans {
+		last[f.Name] = f
+	}
+	fans := make([]FanStatus, len(speeds))
+	var stalled, mismatched []string
+	for i, s := range speeds {
+		if !checked {
+			fans[i] = last[s.Name]
+			fans[i].Name, fans[i].RPM = s.Name, s.RPM
+			continue
+		}
+		fans[i] = FanStatus{Name: s.Name, RPM: s.RPM}
+		desc := fmt.Sprintf("%s %d RPM", s.Name, s.RPM)
+		switch {
+		case lo > 0 && s.RPM < stall:
+			fans[i].Stalled = true
+			stalled = append(stalled, desc)
+		case cfg.MaxRPM > 0 && (s.RPM < minRPM || s.RPM > maxRPM):
+			fans[i].Mismatch = true
+			mismatched = append(mismatched, desc)
+		}
+		fans[i].Failed = fans[i].Stalled || s.RPM < failRPM
+	}
+
+	switch {
+	case len(speeds) > 0 && len(stalled) == len(speeds):
+		return fans, fmt.Sprintf("every fan stalled while commanded to %s", speedRange(lo, hi))
+	case 2*len(mismatched) > len(speeds):
+		return fans, fmt.Sprintf("%d of %d fans do not follow the commanded %s (expected %d..%d RPM): %s",
+			len(mismatched), len(speeds), speedRange(lo, hi), max(0, minRPM), maxRPM, strings.Join(mismatched, ", "))
+	}
+	return fans, ""
+}
+
+// updateFailed records the fans the latest check found failed and returns
+// those that failed and recovered since the one before, as "<name> <rpm> RPM".
+// A failed fan missing from the readings has not recovered and stays failed.
+// Callers hold fc.mu.
+func (v *fanVerify) updateFailed() (failed, recovered []string) {
+	was := make(map[string]bool, len(v.failed))
+	for _, name := range v.failed {
+		was[name] = true
+	}
+	v.failed = nil
+	for _, f := range v.fans {
+		desc := fmt.Sprintf("%s %d RPM", f.Name, f.RPM)
+		switch {
+		case f.Failed:
+			v.failed = append(v.failed, f.Name)
+			if !was[f.Name] {
+				failed = append(failed, desc)
+			}
+		case was[f.Name]:
+			recovered = append(recovered, desc)
+		}
+		delete(was, f.Name)
+	}
+	for _, name := range slices.Sorted(maps.Keys(was)) {
+		v.failed = append(v.failed, name)
+	}
+	return failed, recovered
+}
+
+// reportFanFailures logs and records an event for the fans that failed and
+// recovered on this tick.
+func (fc *FanController) reportFanFailures(failed, recovered []string) {
+	fc.mu.RLock()
+	lo, hi := fc.fanCheck.lo, fc.fanCheck.hi
+	fc.mu.RUnlock()
+	comp := fc.cfg.FanControl.Verify.EffectiveCompensation()
+
+	if len(failed) > 0 {
+		msg := fmt.Sprintf("Fan failed while commanded to %s: %s; raising the other fans by %d%%",
+			speedRange(lo, hi), strings.Join(failed, ", "), comp)
+		log.Printf("FAN FAILURE: %s", msg)
+		fc.recordEvent("fan_failure", msg)
+	}
+	if len(recovered) > 0 {
+		msg := fmt.Sprintf("Fan recovered: %s", strings.Join(recovered, ", "))
+		log.Print(msg)
+		fc.recordEvent("fan_recovered", msg)
+	}
+}
+
+// recordEvent stores an event with the history; the event is only logged
+// when the store fails.
+func (fc *FanController) recordEvent(kind, message string) {
+	if err := fc.store.RecordEvent(kind, message); err != nil {
+		log.Printf("Failed to record %s event: %v", kind, err)
+	}
+}
+
+// fanCompensation is the speed added to the fans while one has failed, 0
+// when none has. Callers hold fc.mu.
+func (fc *FanController) fanCompensation() int {
+	if len(fc.fanCheck.failed) == 0 {
+		return 0
+	}
+	return fc.cfg.FanControl.Verify.EffectiveCompensation()
+}
+
+// commandedRange is the lowest and highest speed the fans were last
+// confirmed at: the one speed without groups, otherwise the ungrouped fans'
+// and each group's. Callers hold fc.mu.
+func (fc *FanController) commandedRange() (lo, hi int) {
+	lo, hi = fc.currentSpeed, fc.currentSpeed
+	if len(fc.cfg.FanControl.Groups) == 0 {
+		return lo, hi
+	}
+	if len(fc.ungroupedFans()) == 0 {
+		lo, hi = 100, 0
+	}
+	for _, g := range fc.groups {
+		if g.currentKnown {
+			lo, hi = min(lo, g.currentSpeed), max(hi, g.currentSpeed)
+		}
+	}
+	return lo, hi
+}
+
+func speedRange(lo, hi int) string {
+	if lo == hi {
+		return fmt.Sprintf("%d%%", lo)
+	}
+	return fmt.Sprintf("%d..%d%%", lo, hi)
+}
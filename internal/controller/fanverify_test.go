@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+	"github.com/sethpjohnson/only-fan-controller/internal/monitor"
+)
+
+// tachs is a fan tachometer reader whose fans come from read.
+type tachs struct {
+	read func() []monitor.FanSpeed
+}
+
+func (f tachs) Read() ([]monitor.FanSpeed, error) { return f.read(), nil }
+
+// obedientFans reports n fans spinning at the controller's current speed's
+// share of maxRPM, with the fans in stalled at 0 RPM.
+func obedientFans(fc *FanController, n, maxRPM int, stalled ...int) tachs {
+	return tachs{read: func() []monitor.FanSpeed {
+		fc.mu.RLock()
+		rpm := fc.currentSpeed * maxRPM / 100
+		fc.mu.RUnlock()
+		fans := make([]monitor.FanSpeed, n)
+		for i := range fans {
+			fans[i] = monitor.FanSpeed{Name: fmt.Sprintf("Fan%d", i+1), RPM: rpm}
+		}
+		for _, i := range stalled {
+			fans[i].RPM = 0
+		}
+		return fans
+	}}
+}
+
+func verifyConfig() *config.Config {
+	cfg := testConfig()
+	cfg.FanControl.Verify = config.FanVerifyConfig{Enabled: true, MaxRPM: 12000, SettleTicks: 1, FailureLimit: 2}
+	return cfg
+}
+
+func TestFanVerifyIgnoredWritesTripFailsafe(t *testing.T) {
+	rec := &cmdRecorder{}
+	fc := NewFanController(verifyConfig(), staticCPU{max: 40}, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = rec.run
+	// The BMC accepts every write and keeps the fans at its own 9000 RPM.
+	fc.fanMon = tachs{read: func() []monitor.FanSpeed {
+		return []monitor.FanSpeed{{Name: "Fan1", RPM: 9000}, {Name: "Fan2", RPM: 9100}, {Name: "Fan3", RPM: 8900}}
+	}}
+
+	fc.controlLoop() // settling
+	fc.controlLoop()
+	st := fc.GetStatus()
+	if fc.currentFailsafeCause() != failsafeNone || !strings.Contains(st.FanVerifyFault, "3 of 3 fans") {
+		t.Fatalf("fail-safe %s, fault %q after one failed check; want a reported fault only", fc.currentFailsafeCause(), st.FanVerifyFault)
+	}
+	if len(st.Fans) != 3 || !st.Fans[0].Mismatch || st.Fans[0].RPM != 9000 {
+		t.Fatalf("fans = %+v, want each reported as a mismatch", st.Fans)
+	}
+
+	fc.controlLoop()
+	if st := fc.GetStatus(); st.FailsafeReason != "verify-failure" || rec.restoreCount() != 1 {
+		t.Fatalf("reason %q with %d restores, want verify-failure handed back", st.FailsafeReason, rec.restoreCount())
+	}
+	// Healthy sensors do not end it: the writes cannot be trusted.
+	fc.controlLoop()
+	if fc.currentFailsafeCause() != failsafeVerify || rec.manualModeCount() != 0 {
+		t.Fatalf("fail-safe %s after %d reclaims, want verify-failure to stick", fc.currentFailsafeCause(), rec.manualModeCount())
+	}
+}
+
+func TestFanVerifyObedientFansPass(t *testing.T) {
+	rec := &cmdRecorder{}
+	store := newTestStore(t)
+	fc := NewFanController(verifyConfig(), staticCPU{max: 40}, staticGPU{max: 40}, store)
+	fc.runCommand = rec.run
+	fc.fanMon = obedientFans(fc, 4, 12000, 2)
+
+	for range 4 {
+		fc.controlLoop()
+	}
+	st := fc.GetStatus()
+	if fc.currentFailsafeCause() != failsafeNone || st.FanVerifyFault != "" {
+		t.Fatalf("fail-safe %s, fault %q; want one stalled fan to pass the check", fc.currentFailsafeCause(), st.FanVerifyFault)
+	}
+	if !st.Fans[2].Stalled || st.Fans[0].Stalled || st.Fans[0].Mismatch {
+		t.Fatalf("fans = %+v, want only Fan3 stalled", st.Fans)
+	}
+	history, err := store.GetHistory(time.Hour)
+	if err != nil || len(history) == 0 || history[len(history)-1].Fans["Fan3"] != 0 || history[len(history)-1].Fans["Fan1"] == 0 {
+		t.Fatalf("history = %+v, %v; want the fan RPMs recorded", history, err)
+	}
+}
+
+func TestFanVerifyAllStalledFails(t *testing.T) {
+	cfg := verifyConfig()
+	cfg.FanControl.Verify.MaxRPM = 0 // stall detection only
+	fc := NewFanController(cfg, staticCPU{max: 40}, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = (&cmdRecorder{}).run
+	fc.fanMon = obedientFans(fc, 2, 12000, 0, 1)
+
+	fc.controlLoop()
+	fc.controlLoop()
+	if st := fc.GetStatus(); !strings.Contains(st.FanVerifyFault, "every fan stalled") {
+		t.Fatalf("fault = %q, want every fan stalled", st.FanVerifyFault)
+	}
+}
+
+// A fan that loses its reading ("No Reading") has stopped, while a header
+// that never had a reading is no fan at all.
+func TestUnreadableFanCountsAsStalled(t *testing.T) {
+	fc := NewFanController(verifyConfig(), staticCPU{max: 40}, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = (&cmdRecorder{}).run
+	dead := false
+	fans := obedientFans(fc, 3, 12000)
+	fc.fanMon = tachs{read: func() []monitor.FanSpeed {
+		speeds := append(fans.read(), monitor.FanSpeed{Name: "Fan4", Unreadable: true})
+		if dead {
+			speeds[1] = monitor.FanSpeed{Name: "Fan2", Unreadable: true}
+		}
+		return speeds
+	}}
+
+	for range 3 {
+		fc.controlLoop()
+	}
+	if st := fc.GetStatus(); st.FanFailure || len(st.Fans) != 3 {
+		t.Fatalf("fan_failure %v, fans %+v; want Fan1..3 without the empty Fan4 header", st.FanFailure, st.Fans)
+	}
+	dead = true
+	for range 3 {
+		fc.controlLoop()
+	}
+	st := fc.GetStatus()
+	if !st.FanFailure || len(st.FailedFans) != 1 || st.FailedFans[0] != "Fan2" || !st.Fans[1].Stalled {
+		t.Fatalf("fan_failure %v, failed %v, fans %+v; want Fan2 stalled and failed", st.FanFailure, st.FailedFans, st.Fans)
+	}
+}
+
+// A failed fan that drops out of the readings has not recovered: it stays
+// failed, with no recovery event, until it reads as turning again.
+func TestFailedFanMissingFromReadingsStaysFailed(t *testing.T) {
+	store := newTestStore(t)
+	fc := NewFanController(verifyConfig(), staticCPU{max: 40}, staticGPU{max: 40}, store)
+	fc.runCommand = (&cmdRecorder{}).run
+	state := "dead"
+	fans := obedientFans(fc, 4, 12000)
+	fc.fanMon = tachs{read: func() []monitor.FanSpeed {
+		speeds := fans.read()
+		switch state {
+		case "dead":
+			speeds[2].RPM = 0
+		case "gone":
+			speeds = slices.Delete(speeds, 2, 3)
+		}
+		return speeds
+	}}
+
+	for range 3 {
+		fc.controlLoop()
+	}
+	state = "gone"
+	for range 3 {
+		fc.controlLoop()
+	}
+	st := fc.GetStatus()
+	if !st.FanFailure || len(st.FailedFans) != 1 || st.FailedFans[0] != "Fan3" || st.FanCompensation != 20 {
+		t.Fatalf("fan_failure %v, failed %v, compensation %d; want Fan3 still failed", st.FanFailure, st.FailedFans, st.FanCompensation)
+	}
+
+	state = "ok"
+	for range 3 {
+		fc.controlLoop()
+	}
+	if st := fc.GetStatus(); st.FanFailure {
+		t.Fatalf("failed %v after Fan3 turned again, want none", st.FailedFans)
+	}
+	events, err := store.GetEvents(time.Hour)
+	if err != nil || len(events) != 2 || events[0].Kind != "fan_failure" || events[1].Kind != "fan_recovered" {
+		t.Fatalf("events = %+v, %v; want one failure and one recovery", events, err)
+	}
+}
+
+func TestFanFailureRaisesOtherFans(t *testing.T) {
+	store := newTestStore(t)
+	fc := NewFanController(verifyConfig(), staticCPU{max: 40}, staticGPU{max: 40}, store)
+	fc.runCommand = (&cmdRecorder{}).run
+	dead := true
+	fans := obedientFans(fc, 4, 12000)
+	fc.fanMon = tachs{read: func() []monitor.FanSpeed {
+		speeds := fans.read()
+		if dead {
+			speeds[2].RPM = 0
+		}
+		return speeds
+	}}
+
+	fc.controlLoop() // settling
+	idle := fc.GetStatus().TargetSpeed
+	fc.controlLoop()
+	fc.controlLoop()
+	st := fc.GetStatus()
+	if !st.FanFailure || len(st.FailedFans) != 1 || st.FailedFans[0] != "Fan3" || !st.Fans[2].Failed {
+		t.Fatalf("fan_failure %v, failed %v, fans %+v; want Fan3 failed", st.FanFailure, st.FailedFans, st.Fans)
+	}
+	if st.TargetSpeed != idle+20 || st.FanCompensation != 20 || fc.currentFailsafeCause() != failsafeNone {
+		t.Fatalf("target %d%% (compensation %d, fail-safe %s), want %d%% without a fail-safe",
+			st.TargetSpeed, st.FanCompensation, fc.currentFailsafeCause(), idle+20)
+	}
+	// The raised speed settles without the failure flickering off.
+	fc.controlLoop()
+	if st := fc.GetStatus(); !st.FanFailure || st.TargetSpeed != idle+20 {
+		t.Fatalf("fan_failure %v at %d%% while settling, want it held at %d%%", st.FanFailure, st.TargetSpeed, idle+20)
+	}
+
+	dead = false
+	for range 3 {
+		fc.controlLoop()
+	}
+	if st := fc.GetStatus(); st.FanFailure || st.TargetSpeed != idle {
+		t.Fatalf("fan_failure %v at %d%% after Fan3 recovered, want cleared at %d%%", st.FanFailure, st.TargetSpeed, idle)
+	}
+	events, err := store.GetEvents(time.Hour)
+	if err != nil || len(events) != 2 || events[0].Kind != "fan_failure" || !strings.Contains(events[0].Message, "Fan3 0 RPM") ||
+		events[1].Kind != "fan_recovered" {
+		t.Fatalf("events = %+v, %v; want Fan3's failure and recovery", events, err)
+	}
+}
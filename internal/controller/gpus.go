@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"math"
+	"path"
+	"strings"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+	"github.com/sethpjohnson/only-fan-controller/internal/monitor"
+)
+
+// gpuView is the GPU side of the latest calculateTarget: the cards folded into
+// one control temperature on the fan_control.gpu_threshold scale. Guarded by
+// mu.
+type gpuView struct {
+	temp int
+	// critTemp is the card closest to its own critical temperature, excluded
+	// or not, on the critical_gpu_temp scale, for Config.IsCritical.
+	critTemp  int
+	throttled bool // some card taking part is thermally throttling
+	driver    *GPUDriverStatus
+}
+
+// GPUDriverStatus is the GPU that set the GPU control temperature: the card
+// furthest over (or least under) its own threshold, after weighting.
+type GPUDriverStatus struct {
+	Index     int     `json:"index"`
+	UUID      string  `json:"uuid,omitempty"`
+	Name      string  `json:"name"`
+	Temp      int     `json:"temp"`
+	Threshold int     `json:"threshold"`
+	Weight    float64 `json:"weight"`
+}
+
+// gpuDeviceConfig returns the first gpu.devices entry matching d, or the
+// zero entry (global threshold and critical, weight 1) when none does.
+func (fc *FanController) gpuDeviceConfig(d monitor.GPUDevice) config.GPUDeviceConfig {
+	for _, c := range fc.cfg.GPU.Devices {
+		if c.Index != nil && *c.Index != d.Index {
+			continue
+		}
+		if c.UUID != "" && !strings.EqualFold(c.UUID, d.UUID) {
+			continue
+		}
+		if c.Name != "" {
+			if ok, _ := path.Match(c.Name, d.Name); !ok {
+				continue
+			}
+		}
+		return c
+	}
+	return config.GPUDeviceConfig{}
+}
+
+// gpuViewFor folds the GPUs into one temperature. Each card counts as the
+// global threshold plus its distance from its own threshold, the part above it
+// scaled by its weight, and the highest wins: a 90°C-rated card at 85°C with a
+// threshold of 80 reads like a default card at gpu_threshold+5. The critical
+// check maps each card onto critical_gpu_temp the same way; excluded cards only
+// take part in that. Without per-card detail the reading's max stands for all
+// of them.
+func (fc *FanController) gpuViewFor(r *monitor.GPUReading) gpuView {
+	fcfg := fc.cfg.FanControl
+	if len(r.Devices) == 0 {
+		return gpuView{temp: r.Max, critTemp: r.Max}
+	}
+
+	v := gpuView{critTemp: math.MinInt}
+	best := math.Inf(-1)
+	for _, d := range r.Devices {
+		c := fc.gpuDeviceConfig(d)
+		v.critTemp = max(v.critTemp, fcfg.CriticalGPUTemp+d.Temp-c.EffectiveCritical(fcfg))
+		if c.Exclude {
+			continue
+		}
+		v.throttled = v.throttled || d.ThermalThrottled()
+		threshold := c.EffectiveThreshold(fcfg)
+		over := float64(d.Temp - threshold)
+		if over > 0 {
+			over *= c.EffectiveWeight()
+		}
+		if over > best {
+			best = over
+			v.driver = &GPUDriverStatus{
+				Index:     d.Index,
+				UUID:      d.UUID,
+				Name:      d.Name,
+				Temp:      d.Temp,
+				Threshold: threshold,
+				Weight:    c.EffectiveWeight(),
+			}
+		}
+	}
+	if v.driver != nil {
+		v.temp = fcfg.EffectiveGPUThreshold() + int(math.Round(best))
+	}
+	return v
+}
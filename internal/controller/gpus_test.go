@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+	"github.com/sethpjohnson/only-fan-controller/internal/monitor"
+)
+
+// mixedGPUs is an H100 next to two consumer cards, the last of them passively
+// cooled by chassis airflow.
+func mixedGPUs(h100, rtx, passive int) (*monitor.CPUReading, *monitor.GPUReading) {
+	devices := []monitor.GPUDevice{
+		{Index: 0, UUID: "GPU-4f7c1d1e-52b3-7a8e-3c1f-9d0e6b2a8c44", Name: "NVIDIA H100 PCIe", Temp: h100},
+		{Index: 1, Name: "NVIDIA GeForce RTX 4090", Temp: rtx},
+		{Index: 2, Name: "NVIDIA GeForce RTX 3060", Temp: passive},
+	}
+	cpuR, _ := cpuGpu(40, 0)
+	return cpuR, &monitor.GPUReading{Devices: devices, Max: max(h100, max(rtx, passive))}
+}
+
+// perGPUConfig rates the H100 by UUID at 80/95 and excludes the passive card.
+func perGPUConfig() *config.Config {
+	cfg := testConfig()
+	passive := 2
+	cfg.GPU.Devices = []config.GPUDeviceConfig{
+		{UUID: "gpu-4F7C1D1E-52B3-7A8E-3C1F-9D0E6B2A8C44", Threshold: 80, Critical: 95},
+		{Index: &passive, Exclude: true},
+	}
+	return cfg
+}
+
+func TestPerGPUThresholds(t *testing.T) {
+	tests := []struct {
+		name       string
+		h100, rtx  int
+		passive    int
+		want       int
+		wantDriver int
+	}{
+		// 75°C is over gpu_threshold (60) but under the H100's own 80.
+		{name: "datacenter card below its own threshold", h100: 75, rtx: 50, passive: 40, want: 20, wantDriver: 0},
+		// 5 over its threshold counts like 65 on a default card: two steps
+		// needed, one taken per tick.
+		{name: "datacenter card over its own threshold", h100: 85, rtx: 50, passive: 40, want: 30, wantDriver: 0},
+		{name: "consumer card at the global threshold", h100: 70, rtx: 62, passive: 40, want: 30, wantDriver: 1},
+		{name: "excluded card does not drive the fans", h100: 70, rtx: 50, passive: 80, want: 20, wantDriver: 0},
+		// 92°C is past critical_gpu_temp (90) but short of the H100's 95.
+		{name: "per-card critical", h100: 92, rtx: 50, passive: 40, want: 30, wantDriver: 0},
+		{name: "per-card critical reached", h100: 95, rtx: 50, passive: 40, want: 100, wantDriver: 0},
+		{name: "excluded card still trips critical", h100: 70, rtx: 50, passive: 90, want: 100, wantDriver: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc := NewFanController(perGPUConfig(), staticCPU{max: 40}, nil, nil)
+			fc.currentSpeed = 20
+			cpuR, gpuR := mixedGPUs(tt.h100, tt.rtx, tt.passive)
+			if got := fc.calculateTarget(cpuR, gpuR); got != tt.want {
+				t.Fatalf("calculateTarget = %d, want %d", got, tt.want)
+			}
+			driver := fc.GetStatus().GPUDriver
+			if driver == nil || driver.Index != tt.wantDriver {
+				t.Fatalf("gpu_driver = %+v, want GPU %d", driver, tt.wantDriver)
+			}
+		})
+	}
+}
+
+func TestPerGPUWeightScalesExcess(t *testing.T) {
+	cfg := testConfig()
+	cfg.GPU.Devices = []config.GPUDeviceConfig{{Name: "*RTX 4090", Weight: 0.5}}
+	fc := NewFanController(cfg, staticCPU{max: 40}, nil, nil)
+
+	_, gpuR := mixedGPUs(50, 70, 40)
+	v := fc.gpuViewFor(gpuR)
+	if v.temp != 65 || v.driver.Index != 1 || v.driver.Weight != 0.5 {
+		t.Fatalf("gpu view = %d driven by %+v, want 65 from GPU 1 at weight 0.5", v.temp, v.driver)
+	}
+}
+
+func TestGPUViewWithoutDevicesUsesMax(t *testing.T) {
+	fc := NewFanController(perGPUConfig(), nil, nil, nil)
+	_, gpuR := cpuGpu(40, 72)
+	if v := fc.gpuViewFor(gpuR); v.temp != 72 || v.critTemp != 72 || v.driver != nil {
+		t.Fatalf("gpu view = %+v, want the max with no driver", v)
+	}
+}
+
+func TestAllGPUsExcluded(t *testing.T) {
+	cfg := testConfig()
+	cfg.GPU.Devices = []config.GPUDeviceConfig{{Name: "*", Exclude: true}}
+	fc := NewFanController(cfg, staticCPU{max: 40}, nil, nil)
+	fc.currentSpeed = 20
+
+	cpuR, gpuR := mixedGPUs(80, 80, 80)
+	if got := fc.calculateTarget(cpuR, gpuR); got != 20 {
+		t.Fatalf("calculateTarget with every GPU excluded = %d, want idle (20)", got)
+	}
+	if fc.GetStatus().GPUDriver != nil {
+		t.Fatal("gpu_driver should be null with every GPU excluded")
+	}
+}
@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+)
+
+// groupState is the controller's view of one fan_control.groups entry, indexed
+// like the config slice. Guarded by mu.
+type groupState struct {
+	targetSpeed int
+	// currentSpeed is what every fan in the group was last confirmed at. It is
+	// only meaningful while currentKnown is true: a write that failed part-way
+	// through the group leaves its fans at mixed speeds.
+	currentSpeed    int
+	currentKnown    bool
+	lastWriteFailed bool // the latest write to at least one fan in the group failed
+}
+
+// FanGroupStatus reports one fan group in Status.
+type FanGroupStatus struct {
+	Name            string `json:"name"`
+	Fans            []int  `json:"fans"`
+	TargetSpeed     int    `json:"target_speed"`
+	CurrentSpeed    *int   `json:"current_speed"` // null until a write succeeds, and after one fails part-way
+	LastWriteFailed bool   `json:"last_write_failed"`
+}
+
+// syncGroups sizes the group state to the configured groups. Callers hold
+// fc.mu.
+func (fc *FanController) syncGroups() {
+	if len(fc.groups) != len(fc.cfg.FanControl.Groups) {
+		fc.groups = make([]groupState, len(fc.cfg.FanControl.Groups))
+	}
+}
+
+// pinGroups drives every group at the same speed: the critical ramp and a
+// manual override apply to all fans, whatever group they are in. Callers hold
+// fc.mu.
+func (fc *FanController) pinGroups(speed int) {
+	fc.syncGroups()
+	for i := range fc.groups {
+		fc.groups[i].targetSpeed = speed
+	}
+}
+
+// planGroups computes each group's own target: the group's curve read at the
+// hottest of its sources (at the predicted temperature when predictive
+// feed-forward is enabled), floored by the workload hints and named sensors,
+// raised while a fan has failed and clamped to the MinSpeed/MaxSpeed band. Groups always follow their curve; fan_control.mode
+// only decides the ungrouped fans. A group is held rather than ramped down
+// while one of its own sources is still rising. Callers hold fc.mu.
+func (fc *FanController) planGroups(cpuMax, gpuMax int, pred prediction, floor int) {
+	fc.syncGroups()
+	if len(fc.groups) == 0 {
+		return
+	}
+
+	cpuLead, gpuLead := cpuMax, gpuMax
+	if fc.cfg.FanControl.Predictive.Enabled {
+		cpuLead = fc.lookahead(cpuMax, pred.cpuTrend)
+		gpuLead = fc.lookahead(gpuMax, pred.gpuTrend)
+	}
+
+	for i, g := range fc.cfg.FanControl.Groups {
+		zones := fc.cfg.GroupZones(g)
+		speed := 0.0
+		rising := false
+		for _, src := range g.Sources {
+			switch src {
+			case config.SourceCPU:
+				speed = math.Max(speed, curveSpeed(zones, cpuLead, cpuAxis))
+				rising = rising || pred.cpuRising
+			case config.SourceGPU:
+				if fc.cfg.GPU.Enabled {
+					speed = math.Max(speed, curveSpeed(zones, gpuLead, gpuAxis))
+					rising = rising || pred.gpuRising
+				}
+			}
+		}
+
+		target := int(math.Round(speed))
+		st := &fc.groups[i]
+		if rising && st.currentKnown && target < st.currentSpeed {
+			target = st.currentSpeed
+		}
+		st.targetSpeed = fc.clampSpeed(max(target, floor) + fc.fanCompensation())
+	}
+}
+
+// ungroupedFans lists the chassis fans that belong to no group.
+func (fc *FanController) ungroupedFans() []int {
+	grouped := make(map[int]bool)
+	for _, g := range fc.cfg.FanControl.Groups {
+		for _, fan := range g.Fans {
+			grouped[fan] = true
+		}
+	}
+	var fans []int
+	for fan := 0; fan < fc.cfg.FanControl.FanCount; fan++ {
+		if !grouped[fan] {
+			fans = append(fans, fan)
+		}
+	}
+	return fans
+}
+
+// setGroupedFanSpeed is setFanSpeed with fan groups configured. It never uses
+// the all-fans command, which would briefly drag every group to the main
+// target each tick: the ungrouped fans are written one by one at speed and
+// each group's fans at the group's target. Every write is attempted even if an
+// earlier one fails; the returned error joins the failures so the write
+// fail-safe counts the tick as a failed write.
+func (fc *FanController) setGroupedFanSpeed(speed int) error {
+	groups := fc.cfg.FanControl.Groups
+	targets := make([]int, len(groups))
+	fc.mu.RLock()
+	for i := range targets {
+		// A group with no planned target yet follows the main speed.
+		targets[i] = speed
+		if i < len(fc.groups) {
+			targets[i] = fc.groups[i].targetSpeed
+		}
+	}
+	fc.mu.RUnlock()
+
+	var errs []error
+	if _, err := fc.writeFans(fc.ungroupedFans(), speed); err != nil {
+		errs = append(errs, err)
+	} else {
+		fc.mu.Lock()
+		fc.currentSpeed = speed
+		fc.mu.Unlock()
+	}
+
+	for i, g := range groups {
+		written, err := fc.writeFans(g.Fans, targets[i])
+
+		fc.mu.Lock()
+		if i < len(fc.groups) {
+			st := &fc.groups[i]
+			switch {
+			case err == nil:
+				st.currentSpeed, st.currentKnown = targets[i], true
+			case written > 0:
+				// Some fans took the new speed, the rest did not.
+				st.currentKnown = false
+			}
+			st.lastWriteFailed = err != nil
+		}
+		fc.mu.Unlock()
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("fan group %q: %w", g.Name, err))
+		}
+	}
+
+	err := errors.Join(errs...)
+	fc.mu.Lock()
+	fc.lastWriteFailed = err != nil
+	fc.mu.Unlock()
+	return err
+}
+
+// writeFans sets each of the given fans to speed through the actuator's
+// per-fan write, stopping at the first failure. It returns how many fans were
+// written before that failure.
+func (fc *FanController) writeFans(fans []int, speed int) (int, error) {
+	for i, fan := range fans {
+		if err := fc.act.setFan(fan, speed); err != nil {
+			return i, fmt.Errorf("fan %d: %w", fan, err)
+		}
+	}
+	return len(fans), nil
+}
+
+// groupStatus reports the configured groups. Callers hold fc.mu (read).
+func (fc *FanController) groupStatus() []FanGroupStatus {
+	groups := fc.cfg.FanControl.Groups
+	if len(groups) == 0 {
+		return nil
+	}
+	out := make([]FanGroupStatus, len(groups))
+	for i, g := range groups {
+		out[i] = FanGroupStatus{Name: g.Name, Fans: g.Fans}
+		if i < len(fc.groups) {
+			st := fc.groups[i]
+			out[i].TargetSpeed = st.targetSpeed
+			if st.currentKnown {
+				speed := st.currentSpeed
+				out[i].CurrentSpeed = &speed
+			}
+			out[i].LastWriteFailed = st.lastWriteFailed
+		}
+	}
+	return out
+}
@@ -26,10 +26,15 @@ func NewMockFanController(cfg *config.Config, store *storage.Store) *MockFanCont
 		runCommand: realRunCommand,
 		hints:      make(map[string]*WorkloadHint),
 		stopChan:   make(chan struct{}),
+		reloadCh:   make(chan *config.Config, 1),
+		applyCh:    make(chan applyRequest),
+		writeRec:   writeRecovery{now: time.Now},
 		cpuHistory: make([]tempPoint, 0),
 		gpuHistory: make([]tempPoint, 0),
 	}
 
+	fc.act = newActuator(fc)
+
 	return &MockFanController{
 		FanController: fc,
 	}
@@ -53,6 +58,10 @@ func (mfc *MockFanController) Run() {
 		select {
 		case <-ticker.C:
 			mfc.mockControlLoop()
+		case next := <-mfc.reloadCh:
+			mfc.applyReload(next)
+		case req := <-mfc.applyCh:
+			req.done <- mfc.applyUpdate(req)
 		case <-mfc.stopChan:
 			return
 		}
@@ -85,11 +94,15 @@ func (mfc *MockFanController) mockControlLoop() {
 	// Just update internal state (no actual IPMI commands)
 	mfc.mu.Lock()
 	mfc.currentSpeed = target
+	for i := range mfc.groups {
+		mfc.groups[i].currentSpeed = mfc.groups[i].targetSpeed
+		mfc.groups[i].currentKnown = true
+	}
 	zone := mfc.currentZone
 	mfc.mu.Unlock()
 
 	// Store reading
-	mfc.store.RecordReading(cpuReading.Max, gpuReading.Max, target)
+	mfc.store.RecordReading(cpuReading.Max, gpuReading.Max, target, gpuTelemetry(gpuReading), nil, nil)
 
 	log.Printf("[MOCK] CPU: %d°C | GPU: %d°C | Zone: %s | Fan: %d%%",
 		cpuReading.Max, gpuReading.Max, zone, target)
@@ -151,19 +164,23 @@ func (mfc *MockFanController) generateMockGPU() *monitor.GPUReading {
 
 		util := 0
 		power := 45
+		clock := 544
 		if hasHints {
 			util = 85 + (time.Now().Second() % 15)
 			power = 220 + (time.Now().Second() % 30)
+			clock = 1531
 		}
 
 		devices[i] = monitor.GPUDevice{
-			Index:       i,
-			Name:        "Tesla P40 (Mock)",
-			Temp:        temp,
-			Utilization: util,
-			MemoryUsed:  2048 + (time.Now().Second() * 10),
-			MemoryTotal: 24576,
-			PowerDraw:   power,
+			Index:         i,
+			Name:          "Tesla P40 (Mock)",
+			Temp:          temp,
+			Utilization:   util,
+			MemoryUsed:    2048 + (time.Now().Second() * 10),
+			MemoryTotal:   24576,
+			PowerDraw:     power,
+			GraphicsClock: clock,
+			MemoryClock:   3615,
 		}
 	}
 
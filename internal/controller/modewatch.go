@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// modeWatch is the state of fan_control.mode_watch. Guarded by mu; the mode
+// reads and the reassertion run outside the lock, on the control loop
+// goroutine.
+type modeWatch struct {
+	now func() time.Time // a field so tests can drive the read schedule
+
+	nextRead   time.Time
+	reverts    int // times the BMC was caught back in auto mode
+	lastRevert time.Time
+}
+
+// watchMode reads the fan mode back every mode_watch.interval seconds and
+// reasserts manual control when the BMC has taken the fans back. On hardware
+// that cannot report its mode this does nothing: verifyFans infers it from
+// the fan RPMs instead.
+func (fc *FanController) watchMode() {
+	cfg := fc.cfg.FanControl.ModeWatch
+	if !cfg.Enabled {
+		return
+	}
+	fc.mu.Lock()
+	now := fc.modeCheck.now()
+	if now.Before(fc.modeCheck.nextRead) {
+		fc.mu.Unlock()
+		return
+	}
+	fc.modeCheck.nextRead = now.Add(time.Duration(cfg.EffectiveInterval()) * time.Second)
+	fc.mu.Unlock()
+
+	manual, err := fc.act.manualMode()
+	switch {
+	case errors.Is(err, errModeUnreadable):
+	case err != nil:
+		log.Printf("Fan mode read failed: %v", err)
+	case !manual:
+		fc.reassertManual("the BMC reports automatic fan control")
+	}
+}
+
+// revertedToAuto reports whether the BMC has gone back to auto mode, as far
+// as the backend can tell. Without a way to read the mode the caller's
+// evidence (fans ignoring the speed) has to do, so it reports true.
+func (fc *FanController) revertedToAuto() bool {
+	manual, err := fc.act.manualMode()
+	switch {
+	case errors.Is(err, errModeUnreadable):
+		return true
+	case err != nil:
+		log.Printf("Fan mode read failed: %v", err)
+		return false
+	}
+	return !manual
+}
+
+// reassertManual takes fan control back from a BMC that reverted to auto
+// mode, counting the revert and recording it as an event. A failed reassert
+// leaves it to the next speed write to fail and count toward the write
+// fail-safe.
+func (fc *FanController) reassertManual(why string) {
+	fc.mu.Lock()
+	fc.modeCheck.reverts++
+	fc.modeCheck.lastRevert = fc.modeCheck.now()
+	fc.mu.Unlock()
+
+	msg := fmt.Sprintf("BMC reverted to automatic fan control (%s); manual control reasserted", why)
+	if err := fc.enableManualMode(); err != nil {
+		msg = fmt.Sprintf("BMC reverted to automatic fan control (%s); reasserting manual control failed: %v", why, err)
+	}
+	log.Printf("MODE REVERT: %s", msg)
+	fc.recordEvent("bmc_auto_revert", msg)
+}
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+	"github.com/sethpjohnson/only-fan-controller/internal/monitor"
+)
+
+func TestModeWatchReassertsSupermicroFullMode(t *testing.T) {
+	cfg := testConfig()
+	cfg.Actuator.Backend = config.BackendSupermicro
+	cfg.FanControl.ModeWatch = config.ModeWatchConfig{Enabled: true, Interval: 60}
+	store := newTestStore(t)
+	fc := NewFanController(cfg, staticCPU{max: 40}, staticGPU{max: 40}, store)
+	rec := &cmdRecorder{}
+	fc.runCommand = rec.run
+	now := time.Unix(1_800_000_000, 0)
+	fc.modeCheck.now = func() time.Time { return now }
+	mode := " 02\n" // optimal: the BMC took the fans back
+	var reads []string
+	fc.runOutput = func(ctx context.Context, env []string, name string, args ...string) (string, error) {
+		reads = append(reads, strings.Join(args, " "))
+		return mode, nil
+	}
+
+	fc.controlLoop()
+	if len(reads) != 1 || !strings.HasSuffix(reads[0], "raw 0x30 0x45 0x00") {
+		t.Fatalf("mode reads = %q, want one raw 0x30 0x45 0x00", reads)
+	}
+	if len(rec.cmds) < 2 || !endsWith(rec.cmds[0], "0x01", "0x01") || !containsArg(rec.cmds[1], "0x70") {
+		t.Fatalf("commands = %v, want full mode reasserted before the duty write", rec.cmds)
+	}
+	st := fc.GetStatus()
+	if st.ModeReverts != 1 || st.LastModeRevert == nil || !st.LastModeRevert.Equal(now) {
+		t.Fatalf("mode_reverts %d at %v, want 1 at %v", st.ModeReverts, st.LastModeRevert, now)
+	}
+	events, err := store.GetEvents(time.Hour)
+	if err != nil || len(events) != 1 || events[0].Kind != "bmc_auto_revert" {
+		t.Fatalf("events = %+v, %v; want one bmc_auto_revert", events, err)
+	}
+
+	// Not read again before the interval, and a full-mode answer is left alone.
+	mode = " 01\n"
+	fc.controlLoop()
+	now = now.Add(time.Minute)
+	fc.controlLoop()
+	if len(reads) != 2 || fc.GetStatus().ModeReverts != 1 {
+		t.Fatalf("%d mode reads with %d reverts, want 2 reads and still 1 revert", len(reads), fc.GetStatus().ModeReverts)
+	}
+}
+
+func TestModeWatchInfersDellRevertFromFans(t *testing.T) {
+	cfg := verifyConfig()
+	cfg.FanControl.ModeWatch.Enabled = true
+	rec := &cmdRecorder{}
+	store := newTestStore(t)
+	fc := NewFanController(cfg, staticCPU{max: 40}, staticGPU{max: 40}, store)
+	fc.runCommand = rec.run
+	// The BMC runs the fans at its own 9000 RPM until manual mode is
+	// reasserted, then obeys.
+	obedient := obedientFans(fc, 3, 12000)
+	fc.fanMon = tachs{read: func() []monitor.FanSpeed {
+		if rec.manualModeCount() > 0 {
+			return obedient.read()
+		}
+		return []monitor.FanSpeed{{Name: "Fan1", RPM: 9000}, {Name: "Fan2", RPM: 9100}, {Name: "Fan3", RPM: 8900}}
+	}}
+
+	for range 6 {
+		fc.controlLoop()
+	}
+	st := fc.GetStatus()
+	if rec.manualModeCount() != 1 || st.ModeReverts != 1 {
+		t.Fatalf("%d manual-mode commands, %d reverts; want manual control reasserted once", rec.manualModeCount(), st.ModeReverts)
+	}
+	if fc.currentFailsafeCause() != failsafeNone || st.FanVerifyFault != "" {
+		t.Fatalf("fail-safe %s, fault %q; want the reassert to fix the fans", fc.currentFailsafeCause(), st.FanVerifyFault)
+	}
+	events, err := store.GetEvents(time.Hour)
+	if err != nil || len(events) != 1 || !strings.Contains(events[0].Message, "fans ignore the commanded speed") {
+		t.Fatalf("events = %+v, %v; want the inferred revert", events, err)
+	}
+}
+
+func TestModeWatchInferenceStillTripsVerifyFailsafe(t *testing.T) {
+	cfg := verifyConfig()
+	cfg.FanControl.ModeWatch.Enabled = true
+	rec := &cmdRecorder{}
+	fc := NewFanController(cfg, staticCPU{max: 40}, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = rec.run
+	fc.fanMon = tachs{read: func() []monitor.FanSpeed {
+		return []monitor.FanSpeed{{Name: "Fan1", RPM: 9000}, {Name: "Fan2", RPM: 9100}}
+	}}
+
+	for range 8 {
+		fc.controlLoop()
+	}
+	if fc.currentFailsafeCause() != failsafeVerify || rec.manualModeCount() != 1 {
+		t.Fatalf("fail-safe %s after %d reasserts, want one reassert then verify-failure", fc.currentFailsafeCause(), rec.manualModeCount())
+	}
+}
+
+func TestHwmonManualModeReadsEnable(t *testing.T) {
+	dir := fakeHwmon(t, 1, 3)
+	fc := NewFanController(hwmonConfig(dir), nil, nil, nil)
+	if err := fc.act.enableManual(); err != nil {
+		t.Fatal(err)
+	}
+	if manual, err := fc.act.manualMode(); err != nil || !manual {
+		t.Fatalf("manualMode = %v, %v; want manual", manual, err)
+	}
+	// The driver reloaded and channel 3 went back to the chip.
+	if err := os.WriteFile(filepath.Join(dir, "pwm3_enable"), []byte("5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if manual, err := fc.act.manualMode(); err != nil || manual {
+		t.Fatalf("manualMode = %v, %v; want automatic", manual, err)
+	}
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"math"
+	"time"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+)
+
+// pidState is the per-source memory of the PID loop: the accumulated integral
+// and the previous measurement (for the derivative). One instance exists per
+// temperature source so CPU and GPU regulate independently toward their own
+// setpoints.
+type pidState struct {
+	integral float64
+	lastTemp int
+	lastAt   time.Time // when lastTemp was sampled, so dt is the real gap between samples
+	primed   bool      // false until the first sample, so the derivative is not computed against a zero lastTemp
+}
+
+// reset discards the loop memory. Called whenever the PID output is bypassed
+// (critical ramp, manual override, fail-safe) so it resumes without a stale
+// integral or a derivative spike against an old sample.
+func (p *pidState) reset() {
+	*p = pidState{}
+}
+
+// update advances the loop to a sample taken at now and returns the
+// controller's contribution in % fan (before the idle-speed offset, clamping
+// and slew limiting). dt is the measured time since the previous sample, so
+// ticks skipped while a sensor read was failing stretch it rather than being
+// folded into one interval. The ticker never fires early, so dt is floored at
+// nominalDt (which the first sample also uses).
+//
+// The derivative is taken on the measurement rather than the error, so a
+// setpoint change does not kick the fans. The integral is clamped to
+// [0, IntegralLimit/Ki]: its contribution never exceeds IntegralLimit, and it
+// never goes negative — the output is floored at idle speed anyway, so a
+// negative integral would only be windup that delays the response to the next
+// heat-up.
+func (p *pidState) update(temp, setpoint int, now time.Time, nominalDt float64, gains config.PIDConfig) float64 {
+	err := float64(temp - setpoint)
+	dt := nominalDt
+	if p.primed {
+		dt = math.Max(nominalDt, now.Sub(p.lastAt).Seconds())
+	}
+
+	if gains.Ki > 0 {
+		p.integral += err * dt
+		if gains.IntegralLimit > 0 {
+			bound := gains.IntegralLimit / gains.Ki
+			p.integral = math.Min(bound, p.integral)
+		}
+		p.integral = math.Max(0, p.integral)
+	}
+
+	derivative := 0.0
+	if p.primed && dt > 0 {
+		derivative = float64(temp-p.lastTemp) / dt
+	}
+	p.lastTemp = temp
+	p.lastAt = now
+	p.primed = true
+
+	return gains.Kp*err + gains.Ki*p.integral + gains.Kd*derivative
+}
+
+// pidTarget computes the PID-mode fan speed from the CPU and GPU maxima. Each
+// source runs its own loop; the hotter demand wins. The result never drops
+// below baseSpeed and is slew-limited relative to the current fan speed (except
+// before the first confirmed write, when there is no known speed to slew from).
+// Callers hold fc.mu.
+func (fc *FanController) pidTarget(cpuMax, gpuMax, baseSpeed int) int {
+	fcCfg := fc.cfg.FanControl
+	gains := fcCfg.PID
+	nominalDt := float64(fc.cfg.Monitoring.Interval)
+	if nominalDt <= 0 {
+		nominalDt = 10
+	}
+
+	now := time.Now()
+	demand := fc.cpuPID.update(cpuMax, fcCfg.EffectiveCPUSetpoint(), now, nominalDt, gains)
+	if fc.cfg.GPU.Enabled {
+		demand = math.Max(demand, fc.gpuPID.update(gpuMax, fcCfg.EffectiveGPUSetpoint(), now, nominalDt, gains))
+	}
+
+	target := baseSpeed + max(0, int(math.Round(demand)))
+
+	current := fc.currentSpeed
+	if current == 0 {
+		return target
+	}
+	if gains.MaxStepUp > 0 && target > current+gains.MaxStepUp {
+		target = current + gains.MaxStepUp
+	}
+	if gains.MaxStepDown > 0 && target < current-gains.MaxStepDown {
+		target = current - gains.MaxStepDown
+	}
+	return target
+}
@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+	"github.com/sethpjohnson/only-fan-controller/internal/monitor"
+)
+
+// PlausibilityStatus reports the monitoring.plausibility checks.
+type PlausibilityStatus struct {
+	Fault          string `json:"fault,omitempty"` // why the latest reading was rejected; empty when it was accepted
+	Rejected       int    `json:"rejected"`        // readings rejected as stuck or slewing too fast
+	SpikesFiltered int    `json:"spikes_filtered"` // samples the median-of-3 replaced
+}
+
+// plausibility screens the CPU and GPU readings before the control loop acts
+// on them. Each CPU temperature and each GPU is a channel of its own. Only the
+// control loop goroutine uses it.
+type plausibility struct {
+	cfg config.PlausibilityConfig
+	now func() time.Time // a field so tests can drive the slew check
+
+	samples  map[string][]int // up to the last three raw samples per channel
+	accepted map[string]acceptedTemp
+
+	cpuStuck stuckRun
+	gpuStuck stuckRun
+}
+
+type acceptedTemp struct {
+	temp int
+	at   time.Time
+}
+
+// stuckRun counts consecutive reads of one source that returned exactly the
+// same temperatures.
+type stuckRun struct {
+	last  []int
+	ticks int
+}
+
+func (r *stuckRun) observe(temps []int) int {
+	if r.ticks > 0 && slices.Equal(r.last, temps) {
+		r.ticks++
+	} else {
+		r.last, r.ticks = slices.Clone(temps), 1
+	}
+	return r.ticks
+}
+
+func newPlausibility(cfg config.PlausibilityConfig) *plausibility {
+	return &plausibility{
+		cfg:      cfg,
+		now:      time.Now,
+		samples:  make(map[string][]int),
+		accepted: make(map[string]acceptedTemp),
+	}
+}
+
+func (p *plausibility) enabled() bool {
+	return p.cfg.StuckTicks > 0 || p.cfg.MaxSlew > 0 || p.cfg.Median
+}
+
+// screenCPU checks a CPU reading, each socket a channel. It returns the
+// reading to use (a copy, median-filtered when that is on) and how many
+// samples the median replaced. An error means the reading is implausible and
+// must not be acted on.
+func (p *plausibility) screenCPU(r *monitor.CPUReading) (*monitor.CPUReading, int, error) {
+	if !p.enabled() {
+		return r, 0, nil
+	}
+	keys := make([]string, len(r.Temps))
+	for i := range r.Temps {
+		keys[i] = "cpu" + strconv.Itoa(i)
+	}
+	temps, spikes, err := p.screenTemps("CPU", &p.cpuStuck, keys, r.Temps)
+	if err != nil {
+		return nil, spikes, err
+	}
+	out := *r
+	out.Temps = temps
+	if len(temps) > 0 {
+		out.Max = slices.Max(temps)
+	}
+	return &out, spikes, nil
+}
+
+// screenGPU is screenCPU for a GPU reading, each card a channel. A reading
+// without per-card detail (GPU monitoring disabled) passes unchecked.
+func (p *plausibility) screenGPU(r *monitor.GPUReading) (*monitor.GPUReading, int, error) {
+	if !p.enabled() || len(r.Devices) == 0 {
+		return r, 0, nil
+	}
+	keys := make([]string, len(r.Devices))
+	raw := make([]int, len(r.Devices))
+	for i, d := range r.Devices {
+		keys[i], raw[i] = "gpu"+strconv.Itoa(d.Index), d.Temp
+	}
+	temps, spikes, err := p.screenTemps("GPU", &p.gpuStuck, keys, raw)
+	if err != nil {
+		return nil, spikes, err
+	}
+	out := *r
+	out.Devices = slices.Clone(r.Devices)
+	for i := range out.Devices {
+		out.Devices[i].Temp = temps[i]
+	}
+	out.Max = slices.Max(temps)
+	return &out, spikes, nil
+}
+
+// screenTemps runs the checks over one source's channels and returns their
+// filtered temperatures.
+//
+// The median runs first, so a single spike never reaches the slew check; a
+// jump that persists for two reads passes the median and is then held to
+// max_slew, measured from the last accepted reading so that a real step is
+// accepted once enough time has passed. Stuck detection looks at the raw
+// readings.
+func (p *plausibility) screenTemps(source string, stuck *stuckRun, keys []string, raw []int) ([]int, int, error) {
+	now := p.now()
+	var errs []error
+	if n := p.cfg.StuckTicks; n > 0 && len(raw) > 0 && stuck.observe(raw) >= n {
+		errs = append(errs, fmt.Errorf("%s temperatures %v unchanged for %d reads", source, raw, n))
+	}
+
+	spikes := 0
+	temps := make([]int, len(raw))
+	for i, key := range keys {
+		temps[i] = p.median(key, raw[i])
+		if temps[i] != raw[i] {
+			spikes++
+		}
+		if err := p.slew(key, temps[i], now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, spikes, errors.Join(errs...)
+	}
+	for i, key := range keys {
+		p.accepted[key] = acceptedTemp{temp: temps[i], at: now}
+	}
+	return temps, spikes, nil
+}
+
+// median records raw as the channel's newest sample and returns the median
+// of the last three, or raw until there are three.
+func (p *plausibility) median(key string, raw int) int {
+	s := append(p.samples[key], raw)
+	if len(s) > 3 {
+		s = s[len(s)-3:]
+	}
+	p.samples[key] = s
+	if !p.cfg.Median || len(s) < 3 {
+		return raw
+	}
+	sorted := slices.Clone(s)
+	slices.Sort(sorted)
+	return sorted[1]
+}
+
+// slew rejects a move faster than max_slew since the channel's last accepted
+// temperature. A degree is always allowed, for the sensors' 1°C resolution.
+func (p *plausibility) slew(key string, temp int, now time.Time) error {
+	last, ok := p.accepted[key]
+	if p.cfg.MaxSlew <= 0 || !ok {
+		return nil
+	}
+	elapsed := now.Sub(last.at).Seconds()
+	allowed := math.Max(1, p.cfg.MaxSlew*elapsed)
+	if move := math.Abs(float64(temp - last.temp)); move > allowed {
+		return fmt.Errorf("%s moved %d°C -> %d°C in %.0fs (max_slew %g°C/s)", key, last.temp, temp, elapsed, p.cfg.MaxSlew)
+	}
+	return nil
+}
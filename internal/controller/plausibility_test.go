@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+	"github.com/sethpjohnson/only-fan-controller/internal/monitor"
+)
+
+// twoSockets is a CPU reading from a dual-socket host.
+func twoSockets(cpu0, cpu1 int) *monitor.CPUReading {
+	return &monitor.CPUReading{Temps: []int{cpu0, cpu1}, Max: max(cpu0, cpu1)}
+}
+
+func TestPlausibilityMedianDropsSpike(t *testing.T) {
+	p := newPlausibility(config.PlausibilityConfig{Median: true})
+	var got []int
+	spikes := 0
+	for _, temp := range []int{50, 51, 119, 52, 70, 71} {
+		cpuOut, n, err := p.screenCPU(twoSockets(temp, 40))
+		if err != nil {
+			t.Fatalf("screen(%d): %v", temp, err)
+		}
+		got = append(got, cpuOut.Temps[0])
+		spikes += n
+		if cpuOut.Max != max(cpuOut.Temps[0], 40) {
+			t.Fatalf("max = %d with temps %v", cpuOut.Max, cpuOut.Temps)
+		}
+	}
+	// The 119°C spike never comes through; the rise to 70 that stays does.
+	want := []int{50, 51, 51, 52, 70, 70}
+	if !slices.Equal(got, want) {
+		t.Fatalf("filtered = %v, want %v", got, want)
+	}
+	if spikes != 2 {
+		t.Fatalf("spikes = %d, want 2", spikes)
+	}
+}
+
+func TestPlausibilitySlew(t *testing.T) {
+	p := newPlausibility(config.PlausibilityConfig{MaxSlew: 2})
+	now := time.Unix(1700000000, 0)
+	p.now = func() time.Time { return now }
+
+	screen := func(cpu0 int) error {
+		_, _, err := p.screenCPU(twoSockets(cpu0, 40))
+		return err
+	}
+	if err := screen(50); err != nil {
+		t.Fatalf("first reading: %v", err)
+	}
+	now = now.Add(10 * time.Second)
+	if err := screen(80); err == nil || !strings.Contains(err.Error(), "cpu0") {
+		t.Fatalf("30°C in 10s at max_slew 2 = %v, want cpu0 rejected", err)
+	}
+	// Measured from the last accepted reading, a step that stays is taken
+	// once enough time has passed.
+	now = now.Add(5 * time.Second)
+	if err := screen(80); err != nil {
+		t.Fatalf("30°C in 15s: %v", err)
+	}
+	now = now.Add(time.Millisecond)
+	if err := screen(81); err != nil {
+		t.Fatalf("a degree back to back: %v", err)
+	}
+}
+
+func TestStuckReadingTripsSensorFailsafe(t *testing.T) {
+	rec := &cmdRecorder{}
+	cfg := testConfig()
+	cfg.Monitoring.Plausibility.StuckTicks = 3
+	cfg.FanControl.SensorFailureLimit = 2
+	cpu := &settableCPU{max: 45}
+	fc := NewFanController(cfg, cpu, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = rec.run
+
+	for range 3 {
+		fc.controlLoop()
+	}
+	if fc.sensorFailCount != 1 || fc.currentFailsafeCause() != failsafeNone {
+		t.Fatalf("after 3 identical reads: fail count %d, fail-safe %s; want 1, none", fc.sensorFailCount, fc.currentFailsafeCause())
+	}
+	fc.controlLoop()
+	if fc.currentFailsafeCause() != failsafeSensor {
+		t.Fatalf("fail-safe = %s after a frozen sensor, want sensor-loss", fc.currentFailsafeCause())
+	}
+	st := fc.GetStatus().Plausibility
+	if st.Rejected != 2 || !strings.Contains(st.Fault, "unchanged for 3 reads") {
+		t.Fatalf("plausibility = %+v, want 2 rejected, stuck fault", st)
+	}
+
+	// A moving reading is plausible again and recovers like any sensor loss.
+	cpu.max = 46
+	fc.controlLoop()
+	if fc.currentFailsafeCause() != failsafeNone || fc.GetStatus().Plausibility.Fault != "" {
+		t.Fatalf("fail-safe %s, status %+v after the sensor moved; want recovered", fc.currentFailsafeCause(), fc.GetStatus().Plausibility)
+	}
+}
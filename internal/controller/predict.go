@@ -0,0 +1,68 @@
+package controller
+
+import "math"
+
+// prediction is the trend feed-forward view of one control tick. The crossing
+// estimates are always computed (they are reported in Status as telemetry); the
+// crossing/rising flags only influence the fan decision when
+// fan_control.predictive is enabled.
+type prediction struct {
+	cpuCrossingIn *float64 // seconds until the CPU trend reaches cpu_threshold; nil if not heading there
+	gpuCrossingIn *float64
+	cpuTrend      float64 // °C/min
+	gpuTrend      float64
+	crossing      bool // a source is predicted to cross its threshold within the horizon
+	rising        bool // a source is rising by at least min_trend
+	cpuRising     bool // per-source view of rising, for fan groups that follow one source
+	gpuRising     bool
+}
+
+// crossingIn estimates the seconds until temp reaches threshold at trend °C/min.
+// It returns nil when the source is already at/over the threshold or is not
+// rising fast enough to be more than noise.
+func crossingIn(temp, threshold int, trend, minTrend float64) *float64 {
+	if temp >= threshold || trend < minTrend || trend <= 0 {
+		return nil
+	}
+	secs := float64(threshold-temp) / trend * 60
+	return &secs
+}
+
+// predict builds the feed-forward view from the current maxima and the
+// temperature history. Callers hold fc.mu.
+func (fc *FanController) predict(cpuMax, gpuMax, cpuThreshold, gpuThreshold int) prediction {
+	cfg := fc.cfg.FanControl.Predictive
+	p := prediction{
+		cpuTrend: fc.calculateTrend(fc.cpuHistory).Slope,
+		gpuTrend: fc.calculateTrend(fc.gpuHistory).Slope,
+	}
+	minTrend := cfg.EffectiveMinTrend()
+	p.cpuCrossingIn = crossingIn(cpuMax, cpuThreshold, p.cpuTrend, minTrend)
+	if fc.cfg.GPU.Enabled {
+		p.gpuCrossingIn = crossingIn(gpuMax, gpuThreshold, p.gpuTrend, minTrend)
+	}
+	if !cfg.Enabled {
+		return p
+	}
+
+	horizon := float64(cfg.Horizon)
+	for _, eta := range []*float64{p.cpuCrossingIn, p.gpuCrossingIn} {
+		if eta != nil && *eta <= horizon {
+			p.crossing = true
+		}
+	}
+	p.cpuRising = p.cpuTrend >= minTrend
+	p.gpuRising = fc.cfg.GPU.Enabled && p.gpuTrend >= minTrend
+	p.rising = p.cpuRising || p.gpuRising
+	return p
+}
+
+// lookahead projects temp forward by the configured horizon along trend, for
+// the curve mode's feed-forward. Falling, flat and sub-min_trend (noise) trends
+// are not projected: the curve only ever leads on a real rise.
+func (fc *FanController) lookahead(temp int, trend float64) int {
+	if trend <= 0 || trend < fc.cfg.FanControl.Predictive.EffectiveMinTrend() {
+		return temp
+	}
+	return temp + int(math.Round(trend*float64(fc.cfg.FanControl.Predictive.Horizon)/60))
+}
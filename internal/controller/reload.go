@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+)
+
+// Reload hands next, a freshly loaded and validated config, to the control
+// loop, which merges it in between ticks (see config.Config.Reload): the
+// loop reads fc.cfg without the lock, so the config is never swapped under a
+// running tick. A reload still waiting is replaced by the newer one.
+func (fc *FanController) Reload(next *config.Config) {
+	for {
+		select {
+		case fc.reloadCh <- next:
+			return
+		default:
+		}
+		select {
+		case <-fc.reloadCh:
+		default:
+		}
+	}
+}
+
+// ErrConfigChanged rejects an Apply whose base is no longer the running
+// config.
+var ErrConfigChanged = errors.New("the running config changed meanwhile")
+
+// applyRequest is an Apply waiting for the control loop.
+type applyRequest struct {
+	base, next *config.Config
+	done       chan applyResult
+}
+
+type applyResult struct {
+	restart []string
+	err     error
+}
+
+// Apply merges next, derived from base, into the running config like a
+// reload, but waits until the control loop has swapped it in and returns the
+// sections that need a restart, or the validation error that kept the running
+// config. When the running config is no longer base (a reload got there
+// first) it fails with ErrConfigChanged rather than undo that reload. Before
+// Run there is no tick to wait for and next is applied at once.
+func (fc *FanController) Apply(base, next *config.Config) ([]string, error) {
+	fc.mu.RLock()
+	running := fc.running
+	fc.mu.RUnlock()
+	req := applyRequest{base: base, next: next, done: make(chan applyResult, 1)}
+	if !running {
+		r := fc.applyUpdate(req)
+		return r.restart, r.err
+	}
+
+	select {
+	case fc.applyCh <- req:
+	case <-fc.stopChan:
+		return nil, errors.New("the controller has stopped")
+	}
+	r := <-req.done
+	return r.restart, r.err
+}
+
+// applyUpdate applies an Apply request. It runs on the control loop
+// goroutine.
+func (fc *FanController) applyUpdate(req applyRequest) applyResult {
+	if fc.Config() != req.base {
+		return applyResult{err: ErrConfigChanged}
+	}
+	restart, err := fc.applyReload(req.next)
+	return applyResult{restart: restart, err: err}
+}
+
+// Config returns the config the controller is running with. It is replaced,
+// never modified, by a reload, so the caller may read it without locking.
+func (fc *FanController) Config() *config.Config {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	return fc.cfg
+}
+
+// applyReload merges next into the running config and swaps it in, keeping
+// the running config when the merge does not validate. It returns the
+// sections that need a restart. It runs on the control loop goroutine.
+func (fc *FanController) applyReload(next *config.Config) ([]string, error) {
+	merged, restart := fc.cfg.Reload(next)
+	if err := merged.Validate(); err != nil {
+		log.Printf("Config reload rejected, keeping the current config: %v", err)
+		return nil, err
+	}
+
+	fc.mu.Lock()
+	prev := fc.cfg
+	fc.cfg = merged
+	fc.restartRequired = restart
+	// Controller state shaped by the old settings starts over.
+	if prev.FanControl.EffectiveMode() != merged.FanControl.EffectiveMode() || prev.FanControl.PID != merged.FanControl.PID {
+		fc.resetPID()
+	}
+	if !reflect.DeepEqual(prev.FanControl.Groups, merged.FanControl.Groups) {
+		fc.groups = nil
+	}
+	fc.mu.Unlock()
+
+	log.Printf("Config reloaded")
+	if len(restart) > 0 {
+		log.Printf("Config reload: changes to %s require a restart and are not applied", strings.Join(restart, ", "))
+	}
+	return restart, nil
+}
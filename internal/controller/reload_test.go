@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReloadAppliesBetweenTicks(t *testing.T) {
+	cfg := testConfig()
+	fc := NewFanController(cfg, staticCPU{max: 40}, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = (&cmdRecorder{}).run
+	fc.controlLoop()
+
+	stale := testConfig()
+	stale.FanControl.IdleSpeed = 25
+	next := testConfig()
+	next.FanControl.IdleSpeed = 35
+	next.API.Port = cfg.API.Port + 1
+	fc.Reload(stale)
+	fc.Reload(next) // replaces the one still waiting
+	if cfg.FanControl.IdleSpeed == 35 {
+		t.Fatal("Reload touched the running config before the control loop took it")
+	}
+	fc.applyReload(<-fc.reloadCh)
+	fc.controlLoop()
+
+	st := fc.GetStatus()
+	if st.TargetSpeed != 35 || st.IdleSpeed != 35 {
+		t.Fatalf("target %d%% idle %d%%, want the reloaded 35%%", st.TargetSpeed, st.IdleSpeed)
+	}
+	if len(st.RestartRequired) != 1 || st.RestartRequired[0] != "api" || fc.Config().API.Port != cfg.API.Port {
+		t.Fatalf("restart_required %v with api.port %d, want api kept at %d", st.RestartRequired, fc.Config().API.Port, cfg.API.Port)
+	}
+}
+
+func TestReloadKeepsConfigWhenMergeIsInvalid(t *testing.T) {
+	fc := NewFanController(testConfig(), staticCPU{max: 40}, staticGPU{max: 40}, newTestStore(t))
+	running := fc.Config()
+
+	// On its own this is valid, but fan readback is only set up at startup,
+	// so the merge keeps it off and mode_watch then has nothing to infer from.
+	next := testConfig()
+	next.FanControl.IdleSpeed = 35
+	next.FanControl.Verify.Enabled = true
+	next.FanControl.Verify.MaxRPM = 15000
+	next.FanControl.ModeWatch.Enabled = true
+	if err := next.Validate(); err != nil {
+		t.Fatalf("next config: %v", err)
+	}
+
+	fc.applyReload(next)
+	if fc.Config() != running || fc.GetStatus().RestartRequired != nil {
+		t.Fatal("an invalid merge replaced the running config")
+	}
+}
+
+func TestApplyRefusesStaleBase(t *testing.T) {
+	fc := NewFanController(testConfig(), staticCPU{max: 40}, staticGPU{max: 40}, newTestStore(t))
+	base := fc.Config()
+
+	reloaded := testConfig()
+	reloaded.FanControl.GPUThreshold = 72
+	fc.applyReload(reloaded)
+
+	next := *base
+	next.FanControl.CPUThreshold = 70
+	if _, err := fc.Apply(base, &next); !errors.Is(err, ErrConfigChanged) {
+		t.Fatalf("Apply on a replaced base = %v, want ErrConfigChanged", err)
+	}
+	if fc.Config().FanControl.GPUThreshold != 72 {
+		t.Fatal("a stale Apply undid the reload")
+	}
+
+	cur := fc.Config()
+	next = *cur
+	next.FanControl.CPUThreshold = 70
+	if _, err := fc.Apply(cur, &next); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got := fc.Config().FanControl; got.CPUThreshold != 70 || got.GPUThreshold != 72 {
+		t.Fatalf("thresholds %d/%d after Apply, want 70/72", got.CPUThreshold, got.GPUThreshold)
+	}
+}
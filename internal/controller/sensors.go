@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"log"
+	"math"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+	"github.com/sethpjohnson/only-fan-controller/internal/monitor"
+)
+
+// sensorReader reads the named sensors (the real implementation is
+// monitor.SensorMonitor). A read may return some temperatures along with an
+// error naming the sensors it could not read.
+type sensorReader interface {
+	Read() (map[string]int, error)
+}
+
+// SensorStatus reports one sensor in the registry: the built-in cpu and gpu
+// sensors first, then the configured named sensors in config order.
+type SensorStatus struct {
+	Name      string  `json:"name"`
+	Source    string  `json:"source"`
+	BuiltIn   bool    `json:"built_in"`
+	Temp      *int    `json:"temp"` // null when the latest read did not produce it
+	Threshold int     `json:"threshold"`
+	Critical  int     `json:"critical"`
+	Weight    float64 `json:"weight"`
+	// Demand is the fan speed a named sensor is asking for; 0 at or below its
+	// threshold. The built-in sensors are handled by fan_control.mode instead.
+	Demand int `json:"demand"`
+	// Healthy is false when the latest read did not produce a usable
+	// temperature; Error says why for the built-in sensors.
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// UseSensors reads the named sensors (cfg.Sensors) through m every tick. Call
+// it before Run.
+func (fc *FanController) UseSensors(m *monitor.SensorMonitor) {
+	fc.sensorMon = m
+}
+
+// readNamedSensors reads the named sensors and keeps the result for
+// calculateTarget and GetStatus. A sensor that cannot be read is logged and
+// simply has no temperature this tick: unlike the cpu and gpu sensors its loss
+// does not trip the sensor fail-safe, so a missing NVMe drive cannot hand the
+// whole chassis back to the BMC.
+func (fc *FanController) readNamedSensors() map[string]int {
+	if fc.sensorMon == nil {
+		return nil
+	}
+	temps, err := fc.sensorMon.Read()
+	if err != nil {
+		log.Printf("Named sensor read incomplete: %v", err)
+	}
+	fc.mu.Lock()
+	fc.lastSensors = temps
+	fc.mu.Unlock()
+	return temps
+}
+
+// sensorCritical reports whether any named sensor has reached its critical
+// temperature. Callers hold fc.mu.
+func (fc *FanController) sensorCritical() bool {
+	for _, s := range fc.cfg.Sensors {
+		if t, ok := fc.lastSensors[s.Name]; ok && s.Critical > 0 && t >= s.Critical {
+			return true
+		}
+	}
+	return false
+}
+
+// sensorDemand returns the highest fan speed the named sensors ask for, and
+// whether any of them is over its threshold. Callers hold fc.mu.
+func (fc *FanController) sensorDemand() (int, bool) {
+	demand, over := 0, false
+	for _, s := range fc.cfg.Sensors {
+		d := fc.namedSensorDemand(s)
+		if d > 0 {
+			over = true
+		}
+		demand = max(demand, d)
+	}
+	return demand, over
+}
+
+// namedSensorDemand is the fan speed s asks for: from the idle speed at its
+// threshold up to MaxSpeed at its critical temperature, scaled by its weight.
+// Callers hold fc.mu.
+func (fc *FanController) namedSensorDemand(s config.SensorConfig) int {
+	t, ok := fc.lastSensors[s.Name]
+	if !ok || s.Threshold <= 0 || t <= s.Threshold {
+		return 0
+	}
+	idle := fc.cfg.FanControl.IdleSpeed
+	if idle == 0 {
+		idle = 20
+	}
+	frac := math.Min(1, float64(t-s.Threshold)/float64(s.Critical-s.Threshold))
+	speed := float64(idle) + s.EffectiveWeight()*frac*float64(fc.cfg.FanControl.MaxSpeed-idle)
+	return min(fc.cfg.FanControl.MaxSpeed, int(math.Round(speed)))
+}
+
+// sensorStatus builds the registry view for Status. Callers hold fc.mu (read).
+func (fc *FanController) sensorStatus() []SensorStatus {
+	fcfg := fc.cfg.FanControl
+	out := []SensorStatus{{
+		Name:      config.SourceCPU,
+		Source:    fc.cfg.Monitoring.EffectiveCPUSource(),
+		BuiltIn:   true,
+		Threshold: fcfg.EffectiveCPUThreshold(),
+		Critical:  fcfg.CriticalCPUTemp,
+		Weight:    1,
+	}}
+	out[0].Healthy, out[0].Error = fc.cpuHealth.status()
+	if fc.lastCPUReading != nil {
+		t := fc.lastCPUReading.Max
+		out[0].Temp = &t
+	}
+	if fc.cfg.GPU.Enabled {
+		gpu := SensorStatus{
+			Name:      config.SourceGPU,
+			Source:    "nvidia-smi",
+			BuiltIn:   true,
+			Threshold: fcfg.EffectiveGPUThreshold(),
+			Critical:  fcfg.CriticalGPUTemp,
+			Weight:    1,
+		}
+		gpu.Healthy, gpu.Error = fc.gpuHealth.status()
+		if fc.lastGPUReading != nil {
+			t := fc.lastGPUReading.Max
+			gpu.Temp = &t
+		}
+		out = append(out, gpu)
+	}
+	for _, s := range fc.cfg.Sensors {
+		st := SensorStatus{
+			Name:      s.Name,
+			Source:    s.Source,
+			Threshold: s.Threshold,
+			Critical:  s.Critical,
+			Weight:    s.EffectiveWeight(),
+			Demand:    fc.namedSensorDemand(s),
+		}
+		if t, ok := fc.lastSensors[s.Name]; ok {
+			st.Temp = &t
+			st.Healthy = true
+		}
+		out = append(out, st)
+	}
+	return out
+}
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+)
+
+type fakeSensorReader struct {
+	temps map[string]int
+	err   error
+}
+
+func (f fakeSensorReader) Read() (map[string]int, error) { return f.temps, f.err }
+
+// sensorsConfig adds an inlet sensor ramping from 30°C to 44°C and a
+// monitor-only exhaust sensor.
+func sensorsConfig() *config.Config {
+	cfg := testConfig()
+	cfg.FanControl.IdleSpeed = 20
+	cfg.FanControl.MaxSpeed = 100
+	cfg.Sensors = []config.SensorConfig{
+		{Name: "inlet", Source: config.SensorSourceIPMI, IPMIName: "Inlet Temp", Threshold: 30, Critical: 44},
+		{Name: "exhaust", Source: config.SensorSourceIPMI, IPMIName: "Exhaust Temp"},
+	}
+	return cfg
+}
+
+func TestNamedSensorSetsFloor(t *testing.T) {
+	tests := []struct {
+		name   string
+		inlet  int
+		weight float64
+		want   int
+	}{
+		{name: "below threshold leaves idle", inlet: 25, want: 20},
+		{name: "halfway to critical", inlet: 37, want: 60},
+		{name: "weight scales the ramp", inlet: 37, weight: 0.5, want: 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := sensorsConfig()
+			cfg.Sensors[0].Weight = tt.weight
+			fc := NewFanController(cfg, staticCPU{max: 40}, staticGPU{max: 40}, nil)
+			fc.lastSensors = map[string]int{"inlet": tt.inlet, "exhaust": 60}
+
+			cpuR, gpuR := cpuGpu(40, 40)
+			if got := fc.calculateTarget(cpuR, gpuR); got != tt.want {
+				t.Fatalf("calculateTarget with inlet=%d = %d, want %d", tt.inlet, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamedSensorCriticalRampsToMax(t *testing.T) {
+	fc := NewFanController(sensorsConfig(), staticCPU{max: 40}, staticGPU{max: 40}, nil)
+	fc.lastSensors = map[string]int{"inlet": 44}
+
+	cpuR, gpuR := cpuGpu(40, 40)
+	if got := fc.calculateTarget(cpuR, gpuR); got != 100 {
+		t.Fatalf("calculateTarget at inlet critical = %d, want 100", got)
+	}
+	if fc.currentZone != "critical" {
+		t.Fatalf("zone = %q, want critical", fc.currentZone)
+	}
+}
+
+func TestNamedSensorFailureDoesNotTripFailsafe(t *testing.T) {
+	rec := &cmdRecorder{}
+	cfg := sensorsConfig()
+	cfg.FanControl.SensorFailureLimit = 1
+	fc := NewFanController(cfg, staticCPU{max: 40}, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = rec.run
+	fc.sensorMon = fakeSensorReader{
+		temps: map[string]int{"exhaust": 33},
+		err:   errors.New("sensor inlet: no BMC temperature sensor named \"Inlet Temp\""),
+	}
+
+	fc.controlLoop()
+
+	if got := rec.restoreCount(); got != 0 {
+		t.Fatalf("a missing named sensor restored BMC auto mode (%d restores)", got)
+	}
+	if writes := rec.fanSetCount(); writes != 1 {
+		t.Fatalf("fan writes = %d, want the tick to carry on with one write", writes)
+	}
+	status := fc.GetStatus()
+	byName := map[string]SensorStatus{}
+	for _, s := range status.Sensors {
+		byName[s.Name] = s
+	}
+	if s := byName["inlet"]; s.Temp != nil {
+		t.Fatalf("inlet temp = %d, want null after a failed read", *s.Temp)
+	}
+	if s := byName["exhaust"]; s.Temp == nil || *s.Temp != 33 {
+		t.Fatalf("exhaust status = %+v, want temp 33", s)
+	}
+}
+
+func TestStatusAndHistoryCarryNamedSensors(t *testing.T) {
+	rec := &cmdRecorder{}
+	store := newTestStore(t)
+	cfg := sensorsConfig()
+	cfg.GPU.Enabled = true
+	fc := NewFanController(cfg, staticCPU{max: 45}, staticGPU{max: 41}, store)
+	fc.runCommand = rec.run
+	fc.sensorMon = fakeSensorReader{temps: map[string]int{"inlet": 37, "exhaust": 33}}
+
+	fc.controlLoop()
+
+	status := fc.GetStatus()
+	var names []string
+	for _, s := range status.Sensors {
+		names = append(names, s.Name)
+	}
+	if len(names) != 4 || names[0] != "cpu" || names[1] != "gpu" || names[2] != "inlet" || names[3] != "exhaust" {
+		t.Fatalf("registry = %v, want built-ins cpu, gpu then inlet, exhaust", names)
+	}
+	if s := status.Sensors[0]; !s.BuiltIn || s.Temp == nil || *s.Temp != 45 {
+		t.Fatalf("cpu entry = %+v, want built-in at 45", s)
+	}
+	if s := status.Sensors[2]; s.Demand != 60 {
+		t.Fatalf("inlet demand = %d, want 60", s.Demand)
+	}
+	if status.Zone != "active" {
+		t.Fatalf("zone = %q, want active while a named sensor is over threshold", status.Zone)
+	}
+
+	history, err := store.GetHistory(time.Hour)
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].Sensors["inlet"] != 37 || history[0].Sensors["exhaust"] != 33 {
+		t.Fatalf("history = %+v, want one point with the named sensors", history)
+	}
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"math"
+	"time"
+)
+
+// trendFit is a least-squares line through the recent temperature history.
+// Slope is in °C/min. R2 is the coefficient of determination (1 = every sample
+// sits on the line, near 0 = the line explains none of the variation) and
+// Samples is how many points went into the fit, so consumers can tell a
+// trustworthy trend from one drawn through two noisy readings.
+type trendFit struct {
+	Slope   float64
+	R2      float64
+	Samples int
+}
+
+// calculateTrend fits a least-squares line through the history points inside
+// the configured trend window. With monitoring.trend_smoothing set, the line
+// is fitted to an EWMA of the samples so a single noisy ipmitool reading
+// cannot swing the slope; R2 is still measured against the raw samples, since
+// smoothing alone would make any series look like a clean line. Fewer than two
+// points, or a span under 6s, yields a zero fit: there is not enough signal to
+// call it a trend.
+func (fc *FanController) calculateTrend(history []tempPoint) trendFit {
+	if len(history) < 2 {
+		return trendFit{}
+	}
+
+	cutoff := time.Now().Add(-time.Duration(fc.cfg.Monitoring.EffectiveTrendWindow()) * time.Second)
+	var recent []tempPoint
+	for _, p := range history {
+		if p.timestamp.After(cutoff) {
+			recent = append(recent, p)
+		}
+	}
+
+	if len(recent) < 2 {
+		return trendFit{}
+	}
+	origin := recent[0].timestamp
+	if recent[len(recent)-1].timestamp.Sub(origin).Minutes() < 0.1 {
+		return trendFit{}
+	}
+
+	xs := make([]float64, len(recent))
+	raw := make([]float64, len(recent))
+	smoothed := make([]float64, len(recent))
+	s := fc.cfg.Monitoring.TrendSmoothing
+	for i, p := range recent {
+		xs[i] = p.timestamp.Sub(origin).Minutes()
+		raw[i] = float64(p.temp)
+		smoothed[i] = raw[i]
+		if i > 0 {
+			smoothed[i] = (1-s)*raw[i] + s*smoothed[i-1]
+		}
+	}
+
+	slope, intercept, ok := leastSquares(xs, smoothed)
+	if !ok {
+		return trendFit{}
+	}
+	return trendFit{
+		Slope:   slope,
+		R2:      rSquared(xs, raw, slope, intercept),
+		Samples: len(recent),
+	}
+}
+
+// leastSquares fits y = intercept + slope·x. ok is false when every x is the
+// same, which has no slope.
+func leastSquares(xs, ys []float64) (slope, intercept float64, ok bool) {
+	n := float64(len(xs))
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxx, sxy float64
+	for i := range xs {
+		dx := xs[i] - meanX
+		sxx += dx * dx
+		sxy += dx * (ys[i] - meanY)
+	}
+	if sxx == 0 {
+		return 0, 0, false
+	}
+	slope = sxy / sxx
+	return slope, meanY - slope*meanX, true
+}
+
+// rSquared is 1 - SSres/SStot of the line against ys, floored at 0 (a line
+// fitted to smoothed data can do worse on the raw samples than their mean).
+// Identical ys are explained perfectly by a flat line through them.
+func rSquared(xs, ys []float64, slope, intercept float64) float64 {
+	var mean float64
+	for _, y := range ys {
+		mean += y
+	}
+	mean /= float64(len(ys))
+
+	var ssRes, ssTot float64
+	for i := range xs {
+		res := ys[i] - (intercept + slope*xs[i])
+		ssRes += res * res
+		ssTot += (ys[i] - mean) * (ys[i] - mean)
+	}
+	if ssTot == 0 {
+		if ssRes < 1e-9 {
+			return 1
+		}
+		return 0
+	}
+	return math.Max(0, 1-ssRes/ssTot)
+}
@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"log"
+	"time"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+)
+
+// WriteRecoveryStatus reports fan_control.write_recovery.
+type WriteRecoveryStatus struct {
+	HealthyProbes int        `json:"healthy_probes"`       // consecutive healthy BMC probes in the current write fail-safe
+	NextProbe     *time.Time `json:"next_probe,omitempty"` // when the BMC is probed next; absent when not probing
+	Flaps         int        `json:"flaps"`                // relapses in the current run of incidents; max_flaps of them make the fail-safe sticky
+	Sticky        bool       `json:"sticky"`               // true once the write fail-safe no longer recovers before a restart
+}
+
+// writeRecovery is the state of fan_control.write_recovery. Guarded by mu; the
+// probes and the reclaim run outside the lock, on the control loop goroutine.
+//
+// It keeps the write fail-safe from flapping the BMC: a probe only reads, so
+// nothing changes hands while probing, and each reclaim (the single
+// manual<->auto transition) needs healthy_probes healthy probes in a row,
+// spaced at least probe_interval apart. A relapse backs the probes off and
+// counts as a flap, and after max_flaps the fail-safe is sticky again, so the
+// BMC sees at most max_flaps hand-backs before it keeps the fans for good.
+// Only tripping again within stable_window of a reclaim is a relapse; a
+// reclaim that held that long forgets the flaps.
+type writeRecovery struct {
+	now func() time.Time // a field so tests can drive the probe schedule
+
+	nextProbe   time.Time     // zero until the hand-back is confirmed
+	interval    time.Duration // wait after the latest probe; doubles after a failed one
+	healthy     int
+	reclaimed   bool      // manual control was reclaimed, so a new write fail-safe may be a relapse
+	reclaimedAt time.Time // when, for stable_window
+	flaps       int
+	sticky      bool
+}
+
+// reset starts a fresh write fail-safe. Callers hold fc.mu.
+func (w *writeRecovery) reset(cfg config.WriteRecoveryConfig) {
+	w.nextProbe = time.Time{}
+	w.interval = time.Duration(cfg.EffectiveProbeInterval()) * time.Second
+	w.healthy = 0
+}
+
+// trip starts a write fail-safe: a relapse when it comes within
+// stable_window of a reclaim, otherwise a fresh incident, which forgets the
+// flaps of a reclaim that held. Callers hold fc.mu.
+func (w *writeRecovery) trip(cfg config.WriteRecoveryConfig) {
+	if w.reclaimed && !w.held(cfg) {
+		w.relapse(cfg)
+		return
+	}
+	if w.reclaimed {
+		w.reclaimed, w.flaps = false, 0
+	}
+	w.reset(cfg)
+}
+
+// held reports whether the latest reclaim has held for stable_window.
+// Callers hold fc.mu.
+func (w *writeRecovery) held(cfg config.WriteRecoveryConfig) bool {
+	window := time.Duration(cfg.EffectiveStableWindow()) * time.Second
+	return w.reclaimed && w.now().Sub(w.reclaimedAt) >= window
+}
+
+// relapse counts a flap, backs off the probes and makes the fail-safe sticky
+// at max_flaps. Callers hold fc.mu.
+func (w *writeRecovery) relapse(cfg config.WriteRecoveryConfig) {
+	w.flaps++
+	w.reclaimed = false
+	w.healthy = 0
+	w.backOff(cfg)
+	w.nextProbe = time.Time{}
+	if w.flaps >= cfg.EffectiveMaxFlaps() {
+		w.sticky = true
+		log.Printf("Write fail-safe: %d relapses after recovering; staying in BMC auto until restart", w.flaps)
+	}
+}
+
+func (w *writeRecovery) backOff(cfg config.WriteRecoveryConfig) {
+	limit := time.Duration(cfg.EffectiveMaxProbeInterval()) * time.Second
+	w.interval *= 2
+	if w.interval > limit {
+		w.interval = limit
+	}
+}
+
+// status reports the recovery for Status. Callers hold fc.mu.
+func (w *writeRecovery) status(cfg config.WriteRecoveryConfig) *WriteRecoveryStatus {
+	st := &WriteRecoveryStatus{HealthyProbes: w.healthy, Flaps: w.flaps, Sticky: w.sticky}
+	if w.held(cfg) {
+		st.Flaps = 0
+	}
+	if !w.nextProbe.IsZero() {
+		next := w.nextProbe
+		st.NextProbe = &next
+	}
+	return st
+}
+
+// recoverWriteFailsafe runs one tick of fan_control.write_recovery while the
+// write fail-safe is active and reports whether manual control was reclaimed,
+// in which case the fail-safe is cleared and the tick goes on as normal.
+// Probing waits for the hand-back to be confirmed: until then
+// ensureAutoRestored owns the BMC.
+func (fc *FanController) recoverWriteFailsafe() bool {
+	cfg := fc.cfg.FanControl.WriteRecovery
+	if !cfg.Enabled {
+		return false
+	}
+	fc.mu.Lock()
+	w := &fc.writeRec
+	now := w.now()
+	if !fc.restoreConfirmed || w.sticky {
+		fc.mu.Unlock()
+		return false
+	}
+	if w.nextProbe.IsZero() {
+		w.nextProbe = now.Add(w.interval)
+		fc.mu.Unlock()
+		return false
+	}
+	if now.Before(w.nextProbe) {
+		fc.mu.Unlock()
+		return false
+	}
+	fc.mu.Unlock()
+
+	err := fc.act.probe()
+
+	fc.mu.Lock()
+	if err != nil {
+		w.healthy = 0
+		w.backOff(cfg)
+	} else {
+		w.healthy++
+		w.interval = time.Duration(cfg.EffectiveProbeInterval()) * time.Second
+	}
+	w.nextProbe = now.Add(w.interval)
+	healthy, interval := w.healthy, w.interval
+	fc.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Write fail-safe: BMC probe failed, next in %s: %v", interval, err)
+		return false
+	}
+	want := cfg.EffectiveHealthyProbes()
+	log.Printf("Write fail-safe: BMC probe healthy (%d/%d)", healthy, want)
+	if healthy < want {
+		return false
+	}
+	return fc.reclaimAfterWriteFailsafe()
+}
+
+// reclaimAfterWriteFailsafe takes manual control back once, guarded by a
+// write of the last target speed: if either step fails, the BMC gets the fans
+// straight back and the attempt counts as a flap.
+func (fc *FanController) reclaimAfterWriteFailsafe() bool {
+	cfg := fc.cfg.FanControl.WriteRecovery
+	log.Printf("Write fail-safe: BMC healthy; reclaiming manual fan control")
+	fc.mu.RLock()
+	speed := fc.clampSpeed(fc.targetSpeed)
+	fc.mu.RUnlock()
+
+	err := fc.enableManualMode()
+	if err == nil {
+		err = fc.setFanSpeed(speed)
+	}
+	if err != nil {
+		log.Printf("Write fail-safe: reclaim failed, handing back to BMC auto: %v", err)
+		fc.mu.Lock()
+		fc.restoreConfirmed = false
+		fc.writeRec.relapse(cfg)
+		fc.mu.Unlock()
+		fc.attemptRestore()
+		return false
+	}
+
+	fc.mu.Lock()
+	fc.writeRec.reclaimed = true
+	fc.writeRec.reclaimedAt = fc.writeRec.now()
+	fc.writeRec.reset(cfg)
+	fc.mu.Unlock()
+	log.Printf("FAILSAFE CLEARED: fan writes healthy again, manual fan control reclaimed")
+	fc.clearFailsafe()
+	return true
+}
@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+)
+
+// probeCount counts the read-only BMC probes (raw 0x06 0x01).
+func (r *cmdRecorder) probeCount() int {
+	n := 0
+	for _, c := range r.cmds {
+		if endsWith(c, "0x06", "0x01") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestWriteFailsafeRecoversAfterHealthyProbes(t *testing.T) {
+	rec := &cmdRecorder{failOnFanSet: true}
+	cfg := testConfig()
+	cfg.FanControl.WriteFailureLimit = 1
+	cfg.FanControl.WriteRecovery = config.WriteRecoveryConfig{Enabled: true, ProbeInterval: 10, HealthyProbes: 2, MaxFlaps: 2}
+	fc := NewFanController(cfg, staticCPU{max: 40}, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = rec.run
+	now := time.Unix(1700000000, 0)
+	fc.writeRec.now = func() time.Time { return now }
+	tick := func(after time.Duration) {
+		now = now.Add(after)
+		fc.controlLoop()
+	}
+
+	tick(0)
+	if fc.currentFailsafeCause() != failsafeWrite || rec.restoreCount() != 1 {
+		t.Fatalf("fail-safe %s with %d restores, want write-failure handed back", fc.currentFailsafeCause(), rec.restoreCount())
+	}
+
+	// The first tick after the hand-back only schedules a probe; a failed
+	// probe doubles the wait.
+	tick(0)
+	rec.failProbe = true
+	tick(10 * time.Second)
+	if rec.probeCount() != 1 {
+		t.Fatalf("probes = %d, want 1", rec.probeCount())
+	}
+	if next := fc.GetStatus().WriteRecovery.NextProbe; next == nil || !next.Equal(now.Add(20*time.Second)) {
+		t.Fatalf("next probe = %v, want 20s out after a failed probe", next)
+	}
+	rec.failProbe = false
+	tick(10 * time.Second)
+	if rec.probeCount() != 1 {
+		t.Fatalf("probed again %d times before the backoff elapsed", rec.probeCount()-1)
+	}
+	tick(10 * time.Second)
+
+	// Two healthy probes: one guarded reclaim, whose verifying write still
+	// fails, so the BMC gets the fans straight back and it is a flap.
+	tick(10 * time.Second)
+	st := fc.GetStatus()
+	if rec.manualModeCount() != 1 || rec.restoreCount() != 2 || st.FailsafeReason != "write-failure" || st.WriteRecovery.Flaps != 1 {
+		t.Fatalf("manual %d, restores %d, reason %q, recovery %+v; want a failed reclaim counted as a flap",
+			rec.manualModeCount(), rec.restoreCount(), st.FailsafeReason, st.WriteRecovery)
+	}
+
+	// The BMC is back for real: after the backed-off probes, control resumes.
+	rec.failOnFanSet = false
+	tick(0)
+	tick(20 * time.Second)
+	tick(10 * time.Second)
+	if fc.currentFailsafeCause() != failsafeNone || rec.manualModeCount() != 2 {
+		t.Fatalf("fail-safe %s after %d reclaims, want recovered", fc.currentFailsafeCause(), rec.manualModeCount())
+	}
+
+	// Relapsing a second time reaches max_flaps: sticky, no more probes.
+	rec.failOnFanSet = true
+	tick(10 * time.Second)
+	probes := rec.probeCount()
+	for range 5 {
+		tick(time.Hour)
+	}
+	st = fc.GetStatus()
+	if !st.WriteRecovery.Sticky || st.WriteRecovery.Flaps != 2 || rec.probeCount() != probes || rec.manualModeCount() != 2 {
+		t.Fatalf("recovery %+v, probes %d -> %d, reclaims %d; want sticky after 2 flaps",
+			st.WriteRecovery, probes, rec.probeCount(), rec.manualModeCount())
+	}
+}
+
+// Tripping the write fail-safe long after a reclaim held is a new incident,
+// not a relapse: only one within stable_window counts toward max_flaps.
+func TestWriteFailsafeRelapseOnlyWithinStableWindow(t *testing.T) {
+	rec := &cmdRecorder{}
+	cfg := testConfig()
+	cfg.FanControl.WriteFailureLimit = 1
+	cfg.FanControl.WriteRecovery = config.WriteRecoveryConfig{Enabled: true, ProbeInterval: 10, HealthyProbes: 1, MaxFlaps: 1, StableWindow: 600}
+	fc := NewFanController(cfg, staticCPU{max: 40}, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = rec.run
+	now := time.Unix(1700000000, 0)
+	fc.writeRec.now = func() time.Time { return now }
+	tick := func(after time.Duration) {
+		now = now.Add(after)
+		fc.controlLoop()
+	}
+	// trip fails the fan writes for one tick; reclaim probes and takes control back.
+	trip := func(after time.Duration) {
+		rec.failOnFanSet = true
+		tick(after)
+		rec.failOnFanSet = false
+	}
+	reclaim := func() {
+		tick(0)
+		tick(10 * time.Second)
+		if fc.currentFailsafeCause() != failsafeNone {
+			t.Fatalf("fail-safe %s, want recovered", fc.currentFailsafeCause())
+		}
+	}
+
+	trip(0)
+	reclaim()
+	tick(time.Hour)
+	trip(0) // long after the reclaim: a new incident
+	if st := fc.GetStatus().WriteRecovery; st.Flaps != 0 || st.Sticky {
+		t.Fatalf("recovery %+v after an incident past stable_window, want no flap", st)
+	}
+	reclaim()
+
+	trip(time.Minute) // within stable_window: a relapse, and max_flaps is 1
+	if st := fc.GetStatus().WriteRecovery; st.Flaps != 1 || !st.Sticky {
+		t.Fatalf("recovery %+v after a relapse within stable_window, want sticky", st)
+	}
+}
+
+func TestWriteFailsafeProbesWaitForConfirmedRestore(t *testing.T) {
+	rec := &cmdRecorder{failOnFanSet: true, failRestore: true}
+	cfg := testConfig()
+	cfg.FanControl.WriteFailureLimit = 1
+	cfg.FanControl.WriteRecovery = config.WriteRecoveryConfig{Enabled: true, ProbeInterval: 1, HealthyProbes: 1}
+	fc := NewFanController(cfg, staticCPU{max: 40}, staticGPU{max: 40}, newTestStore(t))
+	fc.runCommand = rec.run
+	now := time.Unix(1700000000, 0)
+	fc.writeRec.now = func() time.Time { return now }
+
+	for range 5 {
+		now = now.Add(time.Minute)
+		fc.controlLoop()
+	}
+	if rec.probeCount() != 0 || rec.manualModeCount() != 0 {
+		t.Fatalf("probes %d, reclaims %d with the hand-back unconfirmed; want neither", rec.probeCount(), rec.manualModeCount())
+	}
+}
@@ -0,0 +1,417 @@
+// Package ipmi is a minimal in-process IPMI v2.0 LAN (RMCP+) client. It covers
+// what the controller needs from a BMC — raw commands and temperature sensor
+// readings — over one persistent session, instead of paying a full ipmitool
+// process spawn and RMCP+ handshake for every command.
+//
+// Only cipher suite 3 (RAKP-HMAC-SHA1 / HMAC-SHA1-96 / AES-CBC-128) is spoken.
+package ipmi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Network functions used by this package and its callers.
+const (
+	NetFnSensor  = 0x04
+	NetFnApp     = 0x06
+	NetFnStorage = 0x0a
+)
+
+const (
+	cmdGetDeviceID        = 0x01
+	cmdSetSessionPrivLvl  = 0x3b
+	cmdCloseSession       = 0x3c
+	privilegeAdmin        = 0x04
+	roleAdminNameOnly     = 0x14 // admin, look the user up by name only
+	defaultPort           = "623"
+	defaultKeepAlive      = 30 * time.Second
+	defaultRequestTimeout = 5 * time.Second
+	// fallbackPeriod is how long Usable reports false after the BMC could not
+	// be reached, so callers use their fallback instead of paying a native
+	// timeout on every request.
+	fallbackPeriod = time.Minute
+)
+
+// retransmitInterval is how long to wait for a reply before resending a
+// request; UDP gives no delivery guarantee and BMCs drop packets under load.
+// A reused session that stays silent for two intervals is presumed expired.
+var retransmitInterval = time.Second
+
+// Config holds the BMC connection settings.
+type Config struct {
+	Host     string // host or host:port; the port defaults to 623
+	Username string
+	Password string
+	// KeepAlive is how long the session may sit idle before a Get Device ID is
+	// sent to keep the BMC from expiring it. Zero means 30s.
+	KeepAlive time.Duration
+}
+
+// CompletionError is a request the BMC answered with a non-zero completion
+// code. The session is healthy; the command itself was refused.
+type CompletionError struct {
+	NetFn, Cmd, Code byte
+}
+
+func (e *CompletionError) Error() string {
+	return fmt.Sprintf("ipmi: netfn 0x%02x cmd 0x%02x failed with completion code 0x%02x", e.NetFn, e.Cmd, e.Code)
+}
+
+// Client is an RMCP+ connection to one BMC. The session is opened on first use,
+// kept alive while idle and re-established transparently after it is lost.
+// Client is safe for concurrent use; requests are serialised.
+type Client struct {
+	cfg Config
+
+	mu    sync.Mutex
+	conn  net.Conn
+	sess  *session
+	rqSeq byte
+	last  time.Time // last successful exchange
+	// failedAt is when a request last failed for a transport reason (zero
+	// after any success).
+	failedAt time.Time
+
+	sdrMu   sync.Mutex
+	sensors []sdrSensor // cached SDR repository, read once per client
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type session struct {
+	id        uint32 // managed system (BMC) session ID, sent in our packets
+	consoleID uint32 // our session ID, sent in the BMC's packets
+	seq       uint32
+	keys      sessionKeys
+}
+
+// NewClient returns a client for the BMC and starts its keep-alive loop. No
+// packets are sent until the first request.
+func NewClient(cfg Config) *Client {
+	if cfg.KeepAlive <= 0 {
+		cfg.KeepAlive = defaultKeepAlive
+	}
+	c := &Client{cfg: cfg, stop: make(chan struct{}), done: make(chan struct{})}
+	go c.keepAlive()
+	return c
+}
+
+// Raw sends one IPMI request and returns the response data after the
+// completion code. A non-zero completion code is returned as a
+// *CompletionError; any other error means the BMC could not be reached over a
+// valid session, and the next request starts a new one.
+func (c *Client) Raw(ctx context.Context, netfn, cmd byte, data []byte) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultRequestTimeout)
+		defer cancel()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fresh := c.sess == nil
+	if fresh {
+		if err := c.open(ctx); err != nil {
+			c.reset()
+			c.failedAt = time.Now()
+			return nil, err
+		}
+	}
+	var rsp *response
+	var err error
+	if fresh {
+		rsp, err = c.exchange(ctx, netfn, cmd, data)
+	} else {
+		// A reused session may have been expired by the BMC (reboot, idle
+		// timeout we missed), which then ignores it silently. Give it two
+		// retransmits before retrying once on a new session.
+		sctx, cancel := context.WithTimeout(ctx, 2*retransmitInterval)
+		rsp, err = c.exchange(sctx, netfn, cmd, data)
+		cancel()
+	}
+	if err != nil && !fresh && ctx.Err() == nil {
+		c.reset()
+		if err = c.open(ctx); err == nil {
+			rsp, err = c.exchange(ctx, netfn, cmd, data)
+		}
+	}
+	if err != nil {
+		c.reset()
+		c.failedAt = time.Now()
+		return nil, err
+	}
+	c.failedAt = time.Time{}
+	if rsp.code != 0 {
+		return nil, &CompletionError{NetFn: netfn, Cmd: cmd, Code: rsp.code}
+	}
+	return rsp.data, nil
+}
+
+// Usable reports whether requests are worth sending: false for a minute after
+// one failed to reach the BMC over a valid session, so callers can fall back
+// to ipmitool without waiting out a native timeout on every request.
+func (c *Client) Usable() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.failedAt.IsZero() || time.Since(c.failedAt) >= fallbackPeriod
+}
+
+// Close ends the session and stops the keep-alive loop.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		<-c.done
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sess != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*retransmitInterval)
+		defer cancel()
+		id := binary.LittleEndian.AppendUint32(nil, c.sess.id)
+		c.exchange(ctx, NetFnApp, cmdCloseSession, id) // best effort
+	}
+	c.reset()
+	return nil
+}
+
+// keepAlive pings the BMC whenever the session has been idle for the
+// keep-alive interval. A failed ping just drops the session; the next real
+// request reopens it.
+func (c *Client) keepAlive() {
+	defer close(c.done)
+	t := time.NewTicker(c.cfg.KeepAlive / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+		}
+		c.mu.Lock()
+		idle := c.sess != nil && time.Since(c.last) >= c.cfg.KeepAlive
+		c.mu.Unlock()
+		if idle {
+			ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
+			c.Raw(ctx, NetFnApp, cmdGetDeviceID, nil)
+			cancel()
+		}
+	}
+}
+
+// reset drops the session and socket. Callers hold c.mu.
+func (c *Client) reset() {
+	c.sess = nil
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+func (c *Client) addr() string {
+	if _, _, err := net.SplitHostPort(c.cfg.Host); err == nil {
+		return c.cfg.Host
+	}
+	return net.JoinHostPort(c.cfg.Host, defaultPort)
+}
+
+// open dials the BMC and runs the RMCP+ session handshake: Open Session,
+// RAKP 1-4, then raises the session to administrator privilege (the OEM fan
+// commands need it). Callers hold c.mu.
+func (c *Client) open(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", c.addr())
+	if err != nil {
+		return fmt.Errorf("ipmi: dial %s: %w", c.cfg.Host, err)
+	}
+	c.conn = conn
+
+	consoleID := randUint32()
+	tag := byte(randUint32())
+
+	// Open Session Request: requested privilege, our session ID and the
+	// cipher suite 3 algorithm payloads.
+	req := []byte{tag, privilegeAdmin, 0, 0}
+	req = binary.LittleEndian.AppendUint32(req, consoleID)
+	req = append(req,
+		0x00, 0, 0, 0x08, authRAKPHMACSHA1, 0, 0, 0,
+		0x01, 0, 0, 0x08, integrityHMACSHA1, 0, 0, 0,
+		0x02, 0, 0, 0x08, confAESCBC128, 0, 0, 0,
+	)
+	rsp, err := c.handshake(ctx, payloadOpenSession, payloadOpenSessRsp, tag, req, 36)
+	if err != nil {
+		return fmt.Errorf("ipmi: open session: %w", err)
+	}
+	if binary.LittleEndian.Uint32(rsp[4:8]) != consoleID {
+		return errors.New("ipmi: open session: response for another console session")
+	}
+	bmcID := binary.LittleEndian.Uint32(rsp[8:12])
+
+	user := []byte(c.cfg.Username)
+	if len(user) > 16 {
+		return errors.New("ipmi: username longer than 16 bytes")
+	}
+	kuid := []byte(c.cfg.Password)
+	rm := randBytes(16)
+
+	req = []byte{tag, 0, 0, 0}
+	req = binary.LittleEndian.AppendUint32(req, bmcID)
+	req = append(req, rm...)
+	req = append(req, roleAdminNameOnly, 0, 0, byte(len(user)))
+	req = append(req, user...)
+	rsp, err = c.handshake(ctx, payloadRAKP1, payloadRAKP2, tag, req, 60)
+	if err != nil {
+		return fmt.Errorf("ipmi: RAKP 1/2: %w", err)
+	}
+	rc, guid, code := rsp[8:24], rsp[24:40], rsp[40:60]
+	le := func(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+	userInfo := append([]byte{roleAdminNameOnly, byte(len(user))}, user...)
+	if !hmac.Equal(hmacSHA1(kuid, le(consoleID), le(bmcID), rm, rc, guid, userInfo), code) {
+		return errors.New("ipmi: RAKP 2: key exchange code mismatch (wrong password?)")
+	}
+	sik := hmacSHA1(kuid, rm, rc, userInfo)
+	keys := deriveKeys(sik)
+
+	req = []byte{tag, 0, 0, 0}
+	req = binary.LittleEndian.AppendUint32(req, bmcID)
+	req = append(req, hmacSHA1(kuid, rc, le(consoleID), userInfo)...)
+	rsp, err = c.handshake(ctx, payloadRAKP3, payloadRAKP4, tag, req, 8+authCodeLen)
+	if err != nil {
+		return fmt.Errorf("ipmi: RAKP 3/4: %w", err)
+	}
+	if !hmac.Equal(hmacSHA1(sik, rm, le(bmcID), guid)[:authCodeLen], rsp[8:8+authCodeLen]) {
+		return errors.New("ipmi: RAKP 4: integrity check mismatch")
+	}
+
+	c.sess = &session{id: bmcID, consoleID: consoleID, keys: keys}
+	prsp, err := c.exchange(ctx, NetFnApp, cmdSetSessionPrivLvl, []byte{privilegeAdmin})
+	if err != nil {
+		return fmt.Errorf("ipmi: set session privilege: %w", err)
+	}
+	if prsp.code != 0 {
+		return fmt.Errorf("ipmi: set session privilege: %w",
+			&CompletionError{NetFn: NetFnApp, Cmd: cmdSetSessionPrivLvl, Code: prsp.code})
+	}
+	return nil
+}
+
+// handshake sends one pre-session message and waits for its reply, checking
+// the message tag and the RMCP+ status code (byte 1 of every reply).
+func (c *Client) handshake(ctx context.Context, reqType, rspType, tag byte, payload []byte, minLen int) ([]byte, error) {
+	pkt, err := encodePacket(reqType, 0, 0, payload, nil)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	err = c.roundTrip(ctx, pkt, func(p *packet) bool {
+		if p.payloadType != rspType || len(p.payload) < 2 || p.payload[0] != tag {
+			return false
+		}
+		out = p.payload
+		return true
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if out[1] != 0 {
+		return nil, fmt.Errorf("BMC returned RMCP+ status 0x%02x", out[1])
+	}
+	if len(out) < minLen {
+		return nil, fmt.Errorf("short reply (%d bytes)", len(out))
+	}
+	return out, nil
+}
+
+// exchange sends one IPMI request over the session and waits for the
+// matching response. Callers hold c.mu.
+func (c *Client) exchange(ctx context.Context, netfn, cmd byte, data []byte) (*response, error) {
+	s := c.sess
+	c.rqSeq = (c.rqSeq + 1) & 0x3f
+	s.seq++
+	if s.seq == 0 {
+		s.seq = 1
+	}
+	seq := c.rqSeq
+	pkt, err := encodePacket(payloadIPMI, s.id, s.seq, encodeRequest(netfn, cmd, seq, 0, data), &s.keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var out *response
+	err = c.roundTrip(ctx, pkt, func(p *packet) bool {
+		if p.payloadType != payloadIPMI || p.sessionID != s.consoleID {
+			return false
+		}
+		rsp, err := decodeResponse(p.payload)
+		if err != nil || rsp.rqSeq != seq || rsp.cmd != cmd || rsp.netfn != netfn|1 {
+			return false // stale retransmit reply or garbage
+		}
+		out = rsp
+		return true
+	}, &s.keys)
+	if err != nil {
+		return nil, fmt.Errorf("ipmi: netfn 0x%02x cmd 0x%02x: %w", netfn, cmd, err)
+	}
+	c.last = time.Now()
+	return out, nil
+}
+
+// roundTrip writes pkt and reads datagrams until accept takes one, resending
+// every retransmitInterval until ctx expires. Datagrams that fail to decode
+// (another session's traffic, integrity failures) are ignored.
+func (c *Client) roundTrip(ctx context.Context, pkt []byte, accept func(*packet) bool, keys *sessionKeys) error {
+	deadline, _ := ctx.Deadline()
+	buf := make([]byte, 1024)
+	for {
+		if _, err := c.conn.Write(pkt); err != nil {
+			return err
+		}
+		resend := time.Now().Add(retransmitInterval)
+		if resend.After(deadline) {
+			resend = deadline
+		}
+		c.conn.SetReadDeadline(resend)
+		for {
+			n, err := c.conn.Read(buf)
+			if err != nil {
+				if errors.Is(err, os.ErrDeadlineExceeded) {
+					break
+				}
+				return err
+			}
+			p, err := decodePacket(buf[:n], keys)
+			if err == nil && accept(p) {
+				return nil
+			}
+		}
+		if !time.Now().Before(deadline) {
+			return errors.New("no response from BMC")
+		}
+	}
+}
+
+func randBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+func randUint32() uint32 {
+	for {
+		if v := binary.LittleEndian.Uint32(randBytes(4)); v != 0 {
+			return v
+		}
+	}
+}
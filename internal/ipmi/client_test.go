@@ -0,0 +1,304 @@
+package ipmi
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	// Keep the retransmit and stale-session paths fast in tests.
+	retransmitInterval = 50 * time.Millisecond
+}
+
+func testCtx(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func okHandler(netfn, cmd byte, data []byte) (byte, []byte) { return 0, nil }
+
+func TestRawReusesOneSession(t *testing.T) {
+	bmc := newFakeBMC(t, okHandler)
+	c := bmc.client()
+	defer c.Close()
+
+	for _, speed := range []byte{0x28, 0x30} {
+		if _, err := c.Raw(testCtx(t), 0x30, 0x30, []byte{0x02, 0xff, speed}); err != nil {
+			t.Fatalf("Raw: %v", err)
+		}
+	}
+	if n := bmc.handshakes(); n != 1 {
+		t.Fatalf("handshakes = %d, want 1 session reused for both writes", n)
+	}
+	got := bmc.seen()
+	if len(got) != 2 || got[1].netfn != 0x30 || got[1].cmd != 0x30 || !bytes.Equal(got[1].data, []byte{0x02, 0xff, 0x30}) {
+		t.Fatalf("BMC saw %+v, want two 0x30 0x30 0x02 0xff writes", got)
+	}
+}
+
+func TestWrongPasswordFailsHandshake(t *testing.T) {
+	bmc := newFakeBMC(t, okHandler)
+	c := NewClient(Config{Host: bmc.addr(), Username: "root", Password: "wrong"})
+	defer c.Close()
+
+	_, err := c.Raw(testCtx(t), NetFnApp, cmdGetDeviceID, nil)
+	if err == nil || !strings.Contains(err.Error(), "wrong password") {
+		t.Fatalf("Raw with a bad password = %v, want a key exchange mismatch", err)
+	}
+	if len(bmc.seen()) != 0 {
+		t.Fatal("request reached the BMC without an authenticated session")
+	}
+}
+
+func TestCompletionCodeIsReturned(t *testing.T) {
+	bmc := newFakeBMC(t, func(netfn, cmd byte, data []byte) (byte, []byte) { return 0xc1, nil })
+	c := bmc.client()
+	defer c.Close()
+
+	_, err := c.Raw(testCtx(t), 0x30, 0x30, []byte{0x01, 0x00})
+	var cerr *CompletionError
+	if !errors.As(err, &cerr) || cerr.Code != 0xc1 {
+		t.Fatalf("Raw = %v, want completion code 0xc1", err)
+	}
+	if _, err := c.Raw(testCtx(t), 0x30, 0x30, []byte{0x01, 0x00}); !errors.As(err, &cerr) {
+		t.Fatalf("second Raw = %v, want another completion error", err)
+	}
+	if n := bmc.handshakes(); n != 1 {
+		t.Fatalf("handshakes = %d; a refused command must not drop the session", n)
+	}
+	if !c.Usable() {
+		t.Fatal("a refused command must not push callers onto the fallback")
+	}
+}
+
+func TestSessionReopenedAfterBMCForgetsIt(t *testing.T) {
+	bmc := newFakeBMC(t, okHandler)
+	c := bmc.client()
+	defer c.Close()
+
+	if _, err := c.Raw(testCtx(t), NetFnApp, cmdGetDeviceID, nil); err != nil {
+		t.Fatalf("Raw: %v", err)
+	}
+	bmc.expire()
+	if _, err := c.Raw(testCtx(t), NetFnApp, cmdGetDeviceID, nil); err != nil {
+		t.Fatalf("Raw after the BMC dropped the session: %v", err)
+	}
+	if n := bmc.handshakes(); n != 2 {
+		t.Fatalf("handshakes = %d, want a second session after expiry", n)
+	}
+}
+
+func TestLostPacketIsRetransmitted(t *testing.T) {
+	bmc := newFakeBMC(t, okHandler)
+	c := bmc.client()
+	defer c.Close()
+
+	if _, err := c.Raw(testCtx(t), NetFnApp, cmdGetDeviceID, nil); err != nil {
+		t.Fatalf("Raw: %v", err)
+	}
+	bmc.mu.Lock()
+	bmc.drop = 1
+	bmc.mu.Unlock()
+	if _, err := c.Raw(testCtx(t), NetFnApp, cmdGetDeviceID, nil); err != nil {
+		t.Fatalf("Raw with one dropped packet: %v", err)
+	}
+	if n := bmc.handshakes(); n != 1 {
+		t.Fatalf("handshakes = %d; one lost packet must be retransmitted, not reconnect", n)
+	}
+}
+
+func TestUnreachableBMCTimesOut(t *testing.T) {
+	bmc := newFakeBMC(t, okHandler)
+	addr := bmc.addr()
+	bmc.conn.Close()
+	c := NewClient(Config{Host: addr, Username: "root", Password: "calvin"})
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := c.Raw(ctx, NetFnApp, cmdGetDeviceID, nil)
+	var cerr *CompletionError
+	if err == nil || errors.As(err, &cerr) {
+		t.Fatalf("Raw against a dead BMC = %v, want a transport error", err)
+	}
+	if c.Usable() {
+		t.Fatal("client still reports usable right after failing to reach the BMC")
+	}
+}
+
+func TestKeepAliveAndClose(t *testing.T) {
+	bmc := newFakeBMC(t, okHandler)
+	c := NewClient(Config{Host: bmc.addr(), Username: "root", Password: "calvin", KeepAlive: 40 * time.Millisecond})
+
+	if _, err := c.Raw(testCtx(t), 0x30, 0x30, []byte{0x01, 0x00}); err != nil {
+		t.Fatalf("Raw: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		pings := 0
+		for _, r := range bmc.seen() {
+			if r.netfn == NetFnApp && r.cmd == cmdGetDeviceID {
+				pings++
+			}
+		}
+		if pings > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("idle session was never kept alive")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c.Close()
+	bmc.mu.Lock()
+	open := len(bmc.sessions)
+	bmc.mu.Unlock()
+	if open != 0 {
+		t.Fatalf("%d session(s) still open on the BMC after Close", open)
+	}
+	if n := bmc.handshakes(); n != 1 {
+		t.Fatalf("handshakes = %d; keep-alive must reuse the session", n)
+	}
+}
+
+// fullSensorRecord builds a linear full sensor record reading raw*M degrees,
+// for entity 7.1 (system board).
+func fullSensorRecord(id uint16, number, sensorType byte, name string) []byte {
+	rec := make([]byte, 48+len(name))
+	binary.LittleEndian.PutUint16(rec, id)
+	rec[2] = 0x51
+	rec[3] = sdrFullSensor
+	rec[4] = byte(len(rec) - sdrHeaderLen)
+	rec[5] = bmcAddr
+	rec[7] = number
+	rec[8], rec[9] = 7, 1
+	rec[12] = sensorType
+	rec[21] = 0x01 // degrees C
+	rec[24] = 1    // M
+	rec[47] = 0xc0 | byte(len(name))
+	copy(rec[48:], name)
+	return rec
+}
+
+// sdrHandler serves an SDR repository and sensor readings; a sensor missing
+// from readings reports "reading unavailable".
+func sdrHandler(records [][]byte, readings map[byte]byte) func(netfn, cmd byte, data []byte) (byte, []byte) {
+	return func(netfn, cmd byte, data []byte) (byte, []byte) {
+		switch {
+		case netfn == NetFnStorage && cmd == cmdReserveSDRRepo:
+			return 0, []byte{0x01, 0x00}
+		case netfn == NetFnStorage && cmd == cmdGetSDR:
+			id := binary.LittleEndian.Uint16(data[2:4])
+			offset, n := int(data[4]), int(data[5])
+			for i, rec := range records {
+				if binary.LittleEndian.Uint16(rec) != id && !(id == 0 && i == 0) {
+					continue
+				}
+				next := uint16(sdrLastRecord)
+				if i+1 < len(records) {
+					next = binary.LittleEndian.Uint16(records[i+1])
+				}
+				out := binary.LittleEndian.AppendUint16(nil, next)
+				return 0, append(out, rec[offset:offset+n]...)
+			}
+			return 0xcb, nil // requested record not present
+		case netfn == NetFnSensor && cmd == cmdGetSensorReading:
+			if v, ok := readings[data[0]]; ok {
+				return 0, []byte{v, 0x40, 0x00}
+			}
+			return 0, []byte{0x00, 0x60, 0x00}
+		}
+		return 0xc1, nil
+	}
+}
+
+func TestTemperatureSensors(t *testing.T) {
+	records := [][]byte{
+		fullSensorRecord(0x0001, 0x04, SensorTypeTemperature, "Inlet Temp"),
+		fullSensorRecord(0x0002, 0x30, 0x04, "Fan1 RPM"),
+		fullSensorRecord(0x0003, 0x0e, SensorTypeTemperature, "Temp"),
+		fullSensorRecord(0x0004, 0x0f, SensorTypeTemperature, "Temp"), // empty socket
+	}
+	for i, rec := range records[2:] {
+		rec[8], rec[9] = 3, 0x80|byte(i+1) // processor, device-relative instance
+	}
+	bmc := newFakeBMC(t, sdrHandler(records, map[byte]byte{0x04: 21, 0x30: 50, 0x0e: 47}))
+	c := bmc.client()
+	defer c.Close()
+
+	for range 2 {
+		got, err := c.TemperatureSensors(testCtx(t))
+		if err != nil {
+			t.Fatalf("TemperatureSensors: %v", err)
+		}
+		want := []Sensor{
+			{Name: "Inlet Temp", Number: 0x04, Entity: "7.1", Type: SensorTypeTemperature, Value: 21},
+			{Name: "Temp", Number: 0x0e, Entity: "3.1", Type: SensorTypeTemperature, Value: 47},
+		}
+		if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Fatalf("TemperatureSensors = %+v, want %+v", got, want)
+		}
+	}
+
+	reserves := 0
+	for _, r := range bmc.seen() {
+		if r.cmd == cmdReserveSDRRepo {
+			reserves++
+		}
+	}
+	if reserves != 1 {
+		t.Fatalf("SDR repository read %d times, want it cached after the first", reserves)
+	}
+}
+
+func TestFanSensors(t *testing.T) {
+	fan1 := fullSensorRecord(0x0002, 0x30, SensorTypeFan, "Fan1 RPM")
+	fan1[21], fan1[24] = 0x12, 120 // RPM, 120 RPM per count
+	records := [][]byte{
+		fullSensorRecord(0x0001, 0x04, SensorTypeTemperature, "Inlet Temp"),
+		fan1,
+		fullSensorRecord(0x0003, 0x31, SensorTypeFan, "Fan2 RPM"), // no reading
+	}
+	bmc := newFakeBMC(t, sdrHandler(records, map[byte]byte{0x04: 21, 0x30: 50}))
+	c := bmc.client()
+	defer c.Close()
+
+	got, err := c.FanSensors(testCtx(t))
+	if err != nil {
+		t.Fatalf("FanSensors: %v", err)
+	}
+	want := []Sensor{
+		{Name: "Fan1 RPM", Number: 0x30, Entity: "7.1", Type: SensorTypeFan, Value: 6000},
+		{Name: "Fan2 RPM", Number: 0x31, Entity: "7.1", Type: SensorTypeFan, Unavailable: true},
+	}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("FanSensors = %+v, want %+v", got, want)
+	}
+}
+
+func TestSensorConversion(t *testing.T) {
+	rec := fullSensorRecord(1, 1, SensorTypeTemperature, "CPU")
+	rec[20] = 0x80                // 2's complement readings
+	rec[24], rec[25] = 0x05, 0x00 // M = 5
+	rec[26], rec[27] = 0x0a, 0x00 // B = 10
+	rec[29] = 0xf1                // Rexp = -1, Bexp = 1
+	s, ok := parseFullSensor(rec)
+	if !ok {
+		t.Fatal("full sensor record rejected")
+	}
+	// (5*x + 10*10) / 10
+	for raw, want := range map[byte]float64{0x00: 10, 0x14: 20, 0xfe: 9} {
+		if got := s.convert(raw); math.Abs(got-want) > 1e-9 {
+			t.Errorf("convert(0x%02x) = %v, want %v", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package ipmi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+)
+
+// fakeBMC is a local UDP server speaking the BMC side of RMCP+ cipher suite
+// 3. Session management (handshake, privilege, close) is built in; every other
+// request goes to handler.
+type fakeBMC struct {
+	conn     *net.UDPConn
+	user     string
+	password string
+	handler  func(netfn, cmd byte, data []byte) (code byte, rsp []byte)
+
+	mu       sync.Mutex
+	sessions map[uint32]*fakeSession // by BMC session ID
+	opened   int                     // completed handshakes
+	requests []fakeRequest
+	drop     int // session requests to silently drop
+	nextID   uint32
+}
+
+type fakeSession struct {
+	consoleID  uint32
+	rm, rc     []byte
+	guid       []byte
+	userInfo   []byte // role, username length, username
+	sik        []byte
+	keys       *sessionKeys
+	seq        uint32
+	privileged bool
+}
+
+type fakeRequest struct {
+	netfn, cmd byte
+	data       []byte
+}
+
+func newFakeBMC(t *testing.T, handler func(netfn, cmd byte, data []byte) (byte, []byte)) *fakeBMC {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBMC{
+		conn:     conn,
+		user:     "root",
+		password: "calvin",
+		handler:  handler,
+		sessions: make(map[uint32]*fakeSession),
+		nextID:   0x1000,
+	}
+	t.Cleanup(func() { conn.Close() })
+	go b.serve()
+	return b
+}
+
+func (b *fakeBMC) addr() string { return b.conn.LocalAddr().String() }
+
+func (b *fakeBMC) client() *Client {
+	return NewClient(Config{Host: b.addr(), Username: b.user, Password: b.password})
+}
+
+// expire forgets every session, as a BMC reboot or idle timeout would.
+func (b *fakeBMC) expire() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sessions = make(map[uint32]*fakeSession)
+}
+
+func (b *fakeBMC) handshakes() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.opened
+}
+
+// seen returns the handler-bound requests received so far.
+func (b *fakeBMC) seen() []fakeRequest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]fakeRequest(nil), b.requests...)
+}
+
+func (b *fakeBMC) serve() {
+	buf := make([]byte, 1024)
+	for {
+		n, from, err := b.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if reply := b.handle(buf[:n]); reply != nil {
+			b.conn.WriteToUDP(reply, from)
+		}
+	}
+}
+
+func (b *fakeBMC) handle(data []byte) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(data) < len(rmcpHeader)+12 {
+		return nil
+	}
+	id := binary.LittleEndian.Uint32(data[len(rmcpHeader)+2:])
+	s := b.sessions[id]
+	var keys *sessionKeys
+	if s != nil {
+		keys = s.keys
+	}
+	p, err := decodePacket(data, keys)
+	if err != nil {
+		return nil
+	}
+
+	switch p.payloadType {
+	case payloadOpenSession:
+		return b.openSession(p.payload)
+	case payloadRAKP1:
+		return b.rakp1(p.payload)
+	case payloadRAKP3:
+		return b.rakp3(p.payload)
+	case payloadIPMI:
+		if s == nil || s.keys == nil {
+			return nil // unknown or half-open session: a BMC stays silent
+		}
+		if b.drop > 0 {
+			b.drop--
+			return nil
+		}
+		return b.request(id, s, p.payload)
+	}
+	return nil
+}
+
+func (b *fakeBMC) openSession(req []byte) []byte {
+	if len(req) < 32 {
+		return nil
+	}
+	b.nextID++
+	s := &fakeSession{consoleID: binary.LittleEndian.Uint32(req[4:8])}
+	b.sessions[b.nextID] = s
+	rsp := []byte{req[0], 0, privilegeAdmin, 0}
+	rsp = binary.LittleEndian.AppendUint32(rsp, s.consoleID)
+	rsp = binary.LittleEndian.AppendUint32(rsp, b.nextID)
+	rsp = append(rsp, req[8:32]...)
+	pkt, _ := encodePacket(payloadOpenSessRsp, 0, 0, rsp, nil)
+	return pkt
+}
+
+func (b *fakeBMC) rakp1(req []byte) []byte {
+	if len(req) < 28 {
+		return nil
+	}
+	id := binary.LittleEndian.Uint32(req[4:8])
+	s := b.sessions[id]
+	if s == nil {
+		return nil
+	}
+	ulen := int(req[27])
+	user := req[28 : 28+ulen]
+	rsp := []byte{req[0], 0, 0, 0}
+	rsp = binary.LittleEndian.AppendUint32(rsp, s.consoleID)
+	if string(user) != b.user {
+		rsp[1] = 0x0d // unauthorized name
+		pkt, _ := encodePacket(payloadRAKP2, 0, 0, rsp, nil)
+		return pkt
+	}
+
+	s.rm = append([]byte(nil), req[8:24]...)
+	s.rc, s.guid = randBytes(16), randBytes(16)
+	s.userInfo = append([]byte{req[24], byte(ulen)}, user...)
+	le := func(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+	kuid := []byte(b.password)
+	rsp = append(rsp, s.rc...)
+	rsp = append(rsp, s.guid...)
+	rsp = append(rsp, hmacSHA1(kuid, le(s.consoleID), le(id), s.rm, s.rc, s.guid, s.userInfo)...)
+	pkt, _ := encodePacket(payloadRAKP2, 0, 0, rsp, nil)
+	return pkt
+}
+
+func (b *fakeBMC) rakp3(req []byte) []byte {
+	if len(req) < 28 {
+		return nil
+	}
+	id := binary.LittleEndian.Uint32(req[4:8])
+	s := b.sessions[id]
+	if s == nil || s.rc == nil {
+		return nil
+	}
+	le := func(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+	kuid := []byte(b.password)
+	rsp := []byte{req[0], 0, 0, 0}
+	rsp = binary.LittleEndian.AppendUint32(rsp, s.consoleID)
+	if !bytes.Equal(hmacSHA1(kuid, s.rc, le(s.consoleID), s.userInfo), req[8:28]) {
+		rsp[1] = 0x0f // invalid integrity check value
+		delete(b.sessions, id)
+		pkt, _ := encodePacket(payloadRAKP4, 0, 0, rsp, nil)
+		return pkt
+	}
+	s.sik = hmacSHA1(kuid, s.rm, s.rc, s.userInfo)
+	keys := deriveKeys(s.sik)
+	s.keys = &keys
+	b.opened++
+	rsp = append(rsp, hmacSHA1(s.sik, s.rm, le(id), s.guid)[:authCodeLen]...)
+	pkt, _ := encodePacket(payloadRAKP4, 0, 0, rsp, nil)
+	return pkt
+}
+
+func (b *fakeBMC) request(id uint32, s *fakeSession, msg []byte) []byte {
+	if len(msg) < 7 || checksum(msg[:2]) != msg[2] || checksum(msg[3:len(msg)-1]) != msg[len(msg)-1] {
+		return nil
+	}
+	netfn, rqSeq, cmd := msg[1]>>2, msg[4]>>2, msg[5]
+	data := msg[6 : len(msg)-1]
+
+	var code byte
+	var out []byte
+	switch {
+	case netfn == NetFnApp && cmd == cmdSetSessionPrivLvl:
+		s.privileged = true
+		out = []byte{data[0]}
+	case netfn == NetFnApp && cmd == cmdCloseSession:
+		delete(b.sessions, id)
+	case !s.privileged:
+		code = 0xd4 // insufficient privilege
+	default:
+		b.requests = append(b.requests, fakeRequest{netfn, cmd, append([]byte(nil), data...)})
+		if b.handler != nil {
+			b.mu.Unlock()
+			code, out = b.handler(netfn, cmd, data)
+			b.mu.Lock()
+		}
+	}
+
+	hdr := []byte{consoleAddr, (netfn | 1) << 2}
+	rsp := append(hdr, checksum(hdr))
+	body := append([]byte{bmcAddr, rqSeq << 2, cmd, code}, out...)
+	rsp = append(rsp, body...)
+	rsp = append(rsp, checksum(body))
+	s.seq++
+	pkt, _ := encodePacket(payloadIPMI, s.consoleID, s.seq, rsp, s.keys)
+	return pkt
+}
@@ -0,0 +1,233 @@
+package ipmi
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	cmdGetSensorReading = 0x2d
+	cmdReserveSDRRepo   = 0x22
+	cmdGetSDR           = 0x23
+
+	sdrFullSensor     = 0x01
+	sdrHeaderLen      = 5
+	sdrChunk          = 16 // bytes per partial Get SDR read; safe on every BMC
+	sdrLastRecord     = 0xffff
+	ccReservationLost = 0xc5
+
+	// SensorTypeTemperature is the IPMI sensor type code of temperature
+	// sensors.
+	SensorTypeTemperature = 0x01
+	// SensorTypeFan is the IPMI sensor type code of fan (tachometer)
+	// sensors.
+	SensorTypeFan = 0x04
+)
+
+// Sensor is one threshold sensor reading, converted to its unit.
+type Sensor struct {
+	Name   string
+	Number byte
+	Entity string // entity ID and instance as ipmitool prints them, "3.1"
+	Type   byte
+	Value  float64
+	// Unavailable marks a sensor the BMC has no reading for (Value is 0),
+	// which FanSensors reports: a dead or unplugged fan reads that way.
+	Unavailable bool
+}
+
+// sdrSensor is the part of an SDR full sensor record needed to read and
+// convert a sensor.
+type sdrSensor struct {
+	name       string
+	number     byte
+	entity     string
+	sensorType byte
+	format     byte // analog data format: 0 unsigned, 1 1's complement, 2 2's complement
+	m, b       int
+	bExp, rExp int
+}
+
+// TemperatureSensors reads every temperature sensor the BMC exposes, the
+// equivalent of `ipmitool sdr type temperature`. The SDR repository is read
+// once and cached; each call only issues Get Sensor Reading per sensor.
+// Sensors whose reading is unavailable (absent CPU socket, scanning disabled)
+// are left out.
+func (c *Client) TemperatureSensors(ctx context.Context) ([]Sensor, error) {
+	return c.sensorsOfType(ctx, SensorTypeTemperature, false)
+}
+
+// FanSensors reads every fan tachometer the BMC exposes, in RPM: the
+// equivalent of `ipmitool sdr type fan`. A fan whose reading is unavailable
+// is reported as Unavailable, since that is how a dead or unplugged fan
+// reads; sensors the BMC does not have at all are left out.
+func (c *Client) FanSensors(ctx context.Context) ([]Sensor, error) {
+	return c.sensorsOfType(ctx, SensorTypeFan, true)
+}
+
+// sensorsOfType reads the sensors of sensorType, reporting those without a
+// reading as Unavailable when keepUnavailable is set and leaving them out
+// otherwise.
+func (c *Client) sensorsOfType(ctx context.Context, sensorType byte, keepUnavailable bool) ([]Sensor, error) {
+	records, err := c.sdr(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []Sensor
+	for _, r := range records {
+		if r.sensorType != sensorType {
+			continue
+		}
+		data, err := c.Raw(ctx, NetFnSensor, cmdGetSensorReading, []byte{r.number})
+		var cerr *CompletionError
+		if errors.As(err, &cerr) {
+			continue // sensor not present on this system
+		}
+		if err != nil {
+			return nil, err
+		}
+		sensor := Sensor{Name: r.name, Number: r.number, Entity: r.entity, Type: r.sensorType}
+		// Byte 2: bit 5 set = reading unavailable, bit 6 clear = scanning disabled.
+		if len(data) < 2 || data[1]&0x20 != 0 || data[1]&0x40 == 0 {
+			if !keepUnavailable {
+				continue
+			}
+			sensor.Unavailable = true
+		} else {
+			sensor.Value = r.convert(data[0])
+		}
+		out = append(out, sensor)
+	}
+	return out, nil
+}
+
+// sdr returns the cached SDR repository, reading it on first use.
+func (c *Client) sdr(ctx context.Context) ([]sdrSensor, error) {
+	c.sdrMu.Lock()
+	defer c.sdrMu.Unlock()
+	if c.sensors != nil {
+		return c.sensors, nil
+	}
+	records, err := c.readSDR(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ipmi: read SDR repository: %w", err)
+	}
+	c.sensors = records
+	return records, nil
+}
+
+func (c *Client) readSDR(ctx context.Context) ([]sdrSensor, error) {
+	var out []sdrSensor
+	id := uint16(0)
+	for retries := 0; ; {
+		rsv, err := c.Raw(ctx, NetFnStorage, cmdReserveSDRRepo, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(rsv) < 2 {
+			return nil, errors.New("short Reserve SDR Repository reply")
+		}
+		for id != sdrLastRecord {
+			next, rec, err := c.readRecord(ctx, rsv[:2], id)
+			var cerr *CompletionError
+			if errors.As(err, &cerr) && cerr.Code == ccReservationLost && retries < 3 {
+				break // the repository changed under us; reserve again
+			}
+			if err != nil {
+				return nil, err
+			}
+			if s, ok := parseFullSensor(rec); ok {
+				out = append(out, s)
+			}
+			id = next
+		}
+		if id == sdrLastRecord {
+			return out, nil
+		}
+		retries++
+	}
+}
+
+// readRecord fetches one SDR record in sdrChunk-sized partial reads and
+// returns it with the ID of the next record.
+func (c *Client) readRecord(ctx context.Context, reservation []byte, id uint16) (uint16, []byte, error) {
+	read := func(offset, n int) (uint16, []byte, error) {
+		req := append([]byte{}, reservation...)
+		req = binary.LittleEndian.AppendUint16(req, id)
+		req = append(req, byte(offset), byte(n))
+		data, err := c.Raw(ctx, NetFnStorage, cmdGetSDR, req)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(data) < 2+n {
+			return 0, nil, fmt.Errorf("short Get SDR reply for record 0x%04x", id)
+		}
+		return binary.LittleEndian.Uint16(data[:2]), data[2 : 2+n], nil
+	}
+
+	next, hdr, err := read(0, sdrHeaderLen)
+	if err != nil {
+		return 0, nil, err
+	}
+	rec := append([]byte{}, hdr...)
+	total := sdrHeaderLen + int(hdr[4])
+	for len(rec) < total {
+		_, chunk, err := read(len(rec), min(sdrChunk, total-len(rec)))
+		if err != nil {
+			return 0, nil, err
+		}
+		rec = append(rec, chunk...)
+	}
+	return next, rec, nil
+}
+
+// parseFullSensor decodes an SDR full sensor record (IPMI v2.0 table 43-1).
+// Only linear sensors owned by the BMC itself on LUN 0 are kept: anything
+// else needs bridging or a linearisation formula and is not a temperature the
+// controller uses.
+func parseFullSensor(rec []byte) (sdrSensor, bool) {
+	if len(rec) < 48 || rec[3] != sdrFullSensor || rec[5] != bmcAddr || rec[6]&0x03 != 0 || rec[23]&0x7f != 0 {
+		return sdrSensor{}, false
+	}
+	nameLen := int(rec[47] & 0x1f)
+	if len(rec) < 48+nameLen {
+		return sdrSensor{}, false
+	}
+	return sdrSensor{
+		name:       strings.TrimRight(string(rec[48:48+nameLen]), "\x00 "),
+		number:     rec[7],
+		entity:     fmt.Sprintf("%d.%d", rec[8], rec[9]&0x7f),
+		sensorType: rec[12],
+		format:     rec[20] >> 6,
+		m:          signExtend(int(rec[24])|int(rec[25]>>6)<<8, 10),
+		b:          signExtend(int(rec[26])|int(rec[27]>>6)<<8, 10),
+		rExp:       signExtend(int(rec[29]>>4), 4),
+		bExp:       signExtend(int(rec[29]&0x0f), 4),
+	}, true
+}
+
+// convert applies the record's linear conversion:
+// y = (M*x + B*10^Bexp) * 10^Rexp.
+func (s sdrSensor) convert(raw byte) float64 {
+	x := int(raw)
+	switch s.format {
+	case 1:
+		if raw&0x80 != 0 {
+			x = -int(^raw)
+		}
+	case 2:
+		x = int(int8(raw))
+	}
+	return (float64(s.m*x) + float64(s.b)*math.Pow10(s.bExp)) * math.Pow10(s.rExp)
+}
+
+func signExtend(v, bits int) int {
+	if v&(1<<(bits-1)) != 0 {
+		return v - 1<<bits
+	}
+	return v
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"os/exec"
 	"regexp"
 	"strconv"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/sethpjohnson/only-fan-controller/internal/config"
+	"github.com/sethpjohnson/only-fan-controller/internal/ipmi"
 )
 
 // commandTimeout bounds how long an external monitoring command may run. A hung
@@ -33,126 +35,176 @@ func commandTimeout(cfg *config.Config) time.Duration {
 	return interval
 }
 
+// temperatureSource is the native BMC session (*ipmi.Client); an interface so
+// tests can stand in for the BMC.
+type temperatureSource interface {
+	Usable() bool
+	TemperatureSensors(ctx context.Context) ([]ipmi.Sensor, error)
+}
+
 type CPUMonitor struct {
 	cfg *config.Config
+	// native, when set, reads the sensors over the persistent RMCP+ session
+	// instead of running ipmitool.
+	native temperatureSource
+	// output runs ipmitool; a field so tests can capture the command line.
+	output outputFunc
+	// sdrMap picks the CPU, inlet and exhaust sensors out of the BMC's.
+	sdrMap sensorMap
+}
+
+// outputFunc runs a command with env added to the process environment and
+// returns its stdout and stderr.
+type outputFunc func(ctx context.Context, env []string, name string, args ...string) (stdout, stderr string, err error)
+
+func realOutput(ctx context.Context, env []string, name string, args ...string) (string, string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
 }
 
 type CPUReading struct {
 	Temps []int
 	Max   int
+
+	// Inlet and Exhaust are the chassis air temperatures from the same BMC
+	// read, nil when the BMC has no such sensor (or the source is hwmon).
+	Inlet   *int
+	Exhaust *int
 }
 
 func NewCPUMonitor(cfg *config.Config) *CPUMonitor {
-	return &CPUMonitor{cfg: cfg}
+	return &CPUMonitor{cfg: cfg, output: realOutput, sdrMap: newSensorMap(cfg.Monitoring)}
+}
+
+// UseNativeIPMI reads temperatures over client instead of spawning ipmitool
+// for each read.
+func (m *CPUMonitor) UseNativeIPMI(client *ipmi.Client) {
+	m.native = client
 }
 
-// Read gets current CPU temperatures via IPMI
+// Read gets current CPU temperatures via IPMI. With a native session it is
+// used first; if the session cannot produce a reading this tick, ipmitool is
+// tried instead.
 func (m *CPUMonitor) Read() (*CPUReading, error) {
+	if m.native != nil && m.native.Usable() {
+		reading, err := m.readNative()
+		if err == nil {
+			return reading, nil
+		}
+		log.Printf("Native IPMI CPU read failed (%v); falling back to ipmitool", err)
+	}
+	return m.readIpmitool()
+}
+
+func (m *CPUMonitor) readNative() (*CPUReading, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout(m.cfg))
 	defer cancel()
 
-	var cmd *exec.Cmd
-
-	if m.cfg.IDRAC.Host == "local" {
-		// Local IPMI access
-		cmd = exec.CommandContext(ctx, "ipmitool", "sdr", "type", "temperature")
-	} else {
-		// Remote IPMI access
-		cmd = exec.CommandContext(ctx, "ipmitool",
-			"-I", "lanplus",
-			"-H", m.cfg.IDRAC.Host,
-			"-U", m.cfg.IDRAC.Username,
-			"-P", m.cfg.IDRAC.Password,
-			"sdr", "type", "temperature",
-		)
+	sensors, err := m.native.TemperatureSensors(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return m.sdrMap.reading(sensors)
+}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+func (m *CPUMonitor) readIpmitool() (*CPUReading, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout(m.cfg))
+	defer cancel()
 
-	if err := cmd.Run(); err != nil {
+	// Local access needs no connection arguments; remote access gets the
+	// lanplus arguments, with the password in the environment.
+	args, env := m.cfg.IDRAC.IpmitoolConnection()
+	args = append(args, "sdr", "type", "temperature")
+
+	stdout, stderr, err := m.output(ctx, env, "ipmitool", args...)
+	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			log.Printf("ipmitool CPU read timed out after %s", commandTimeout(m.cfg))
 			return nil, fmt.Errorf("ipmitool CPU read timed out: %w", err)
 		}
-		log.Printf("ipmitool error: %v, stderr: %s", err, stderr.String())
+		log.Printf("ipmitool error: %v, stderr: %s", err, stderr)
 		return nil, err
 	}
 
 	// Empty parse (e.g. after an iDRAC firmware update changed the output
 	// format) is an error, never a silent 0°C reading.
-	temps, err := parseCPUTemps(stdout.String())
+	reading, err := m.sdrMap.reading(parseSDRTemps(stdout))
 	if err != nil {
-		log.Printf("CPU temperature parse failed: %v; raw output: %q", err, stdout.String())
+		log.Printf("CPU temperature parse failed: %v", err)
 		return nil, err
 	}
+	return reading, nil
+}
+
+// temperatureSensors lists every BMC temperature sensor with its name, for
+// the named sensors (inlet, exhaust, ...) rather than the CPU selection.
+func (m *CPUMonitor) temperatureSensors() ([]ipmi.Sensor, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout(m.cfg))
+	defer cancel()
 
-	reading := &CPUReading{
-		Temps: temps,
-		Max:   maxInt(temps),
+	if m.native != nil && m.native.Usable() {
+		sensors, err := m.native.TemperatureSensors(ctx)
+		if err == nil {
+			return sensors, nil
+		}
+		log.Printf("Native IPMI sensor read failed (%v); falling back to ipmitool", err)
 	}
 
-	return reading, nil
+	args, env := m.cfg.IDRAC.IpmitoolConnection()
+	args = append(args, "sdr", "type", "temperature")
+	stdout, stderr, err := m.output(ctx, env, "ipmitool", args...)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("ipmitool sensor read timed out: %w", err)
+		}
+		return nil, fmt.Errorf("ipmitool error: %v, stderr: %s", err, stderr)
+	}
+	return parseSDRTemps(stdout), nil
 }
 
-// parseCPUTemps extracts CPU temperatures from ipmitool output
-// Example output from R730:
+// sdrValueRe matches the reading column of `ipmitool sdr type temperature`.
+var sdrValueRe = regexp.MustCompile(`^(-?\d+(?:\.\d+)?)\s*degrees`)
+
+// parseSDRTemps turns `ipmitool sdr type temperature` output into sensors.
+// Rows without a reading ("No Reading", "Disabled") are left out. From an
+// R730:
 //
 //	Inlet Temp       | 04h | ok  |  7.1 | 20 degrees C
 //	Exhaust Temp     | 01h | ok  |  7.1 | 28 degrees C
 //	Temp             | 0Eh | ok  |  3.1 | 33 degrees C  <- CPU 1
 //	Temp             | 0Fh | ok  |  3.2 | 35 degrees C  <- CPU 2
-func parseCPUTemps(output string) ([]int, error) {
-	var temps []int
-
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		// Skip inlet/exhaust temps, focus on CPU temps
-		lower := strings.ToLower(line)
-		if strings.Contains(lower, "inlet") || strings.Contains(lower, "exhaust") {
+func parseSDRTemps(output string) []ipmi.Sensor {
+	var sensors []ipmi.Sensor
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) < 5 {
 			continue
 		}
-
-		// Only process lines that start with "Temp" (CPU temps on Dell servers)
-		trimmed := strings.TrimSpace(line)
-		if !strings.HasPrefix(trimmed, "Temp") {
+		m := sdrValueRe.FindStringSubmatch(strings.TrimSpace(fields[len(fields)-1]))
+		if m == nil {
 			continue
 		}
-
-		// Extract temperature value: look for "NN degrees"
-		re := regexp.MustCompile(`(\d+)\s*degrees`)
-		matches := re.FindStringSubmatch(line)
-		if len(matches) >= 2 {
-			if temp, err := strconv.Atoi(matches[1]); err == nil && temp > 0 && temp < 120 {
-				temps = append(temps, temp)
-			}
-		}
-	}
-
-	// Fallback: try to find any temperature reading if regex didn't match
-	if len(temps) == 0 {
-		re2 := regexp.MustCompile(`(\d+)\s*degrees`)
-		for _, line := range lines {
-			// Still skip chassis inlet/exhaust sensors in the fallback path.
-			lower := strings.ToLower(line)
-			if strings.Contains(lower, "inlet") || strings.Contains(lower, "exhaust") {
-				continue
-			}
-			matches := re2.FindStringSubmatch(line)
-			if len(matches) >= 2 {
-				if temp, err := strconv.Atoi(matches[1]); err == nil && temp > 0 && temp < 120 {
-					temps = append(temps, temp)
-				}
-			}
+		v, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			continue
 		}
+		number, _ := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(fields[1]), "h"), 16, 8)
+		sensors = append(sensors, ipmi.Sensor{
+			Name:   strings.TrimSpace(fields[0]),
+			Number: byte(number),
+			Entity: strings.TrimSpace(fields[3]),
+			Type:   ipmi.SensorTypeTemperature,
+			Value:  v,
+		})
 	}
-
-	if len(temps) == 0 {
-		return nil, fmt.Errorf("no valid CPU temperatures found in ipmitool output")
-	}
-
-	return temps, nil
+	return sensors
 }
 
 func maxInt(vals []int) int {
@@ -1,6 +1,14 @@
 package monitor
 
-import "testing"
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+	"github.com/sethpjohnson/only-fan-controller/internal/ipmi"
+)
 
 func TestParseCPUTemps(t *testing.T) {
 	tests := []struct {
@@ -38,12 +46,20 @@ Exhaust Temp     | 01h | ok  |  7.1 | 28 degrees C`,
 			wantErr: true,
 		},
 		{
-			name: "firmware update changed labels but temps still present via fallback",
+			name: "firmware update changed labels but the processor entity still maps",
 			output: `CPU1 Temp        | 0Eh | ok  |  3.1 | 41 degrees C
 CPU2 Temp        | 0Fh | ok  |  3.2 | 44 degrees C`,
 			want:    []int{41, 44},
 			wantErr: false,
 		},
+		{
+			name: "DIMM and PSU temperatures are not taken for CPUs",
+			output: `Inlet Temp       | 04h | ok  |  7.1 | 20 degrees C
+DIMM A1 Temp     | 2Ah | ok  | 32.1 | 38 degrees C
+PS1 Temp         | 5Ch | ok  | 10.1 | 33 degrees C`,
+			want:    nil,
+			wantErr: true,
+		},
 		{
 			name: "out-of-range readings are ignored and remaining empty is an error",
 			output: `Temp             | 0Eh | ok  |  3.1 | 0 degrees C
@@ -55,7 +71,11 @@ Temp             | 0Fh | ok  |  3.2 | 999 degrees C`,
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseCPUTemps(tt.output)
+			var got []int
+			r, err := newSensorMap(config.Default().Monitoring).reading(parseSDRTemps(tt.output))
+			if r != nil {
+				got = r.Temps
+			}
 			if tt.wantErr && err == nil {
 				t.Fatalf("expected error, got nil (temps=%v)", got)
 			}
@@ -69,6 +89,174 @@ Temp             | 0Fh | ok  |  3.2 | 999 degrees C`,
 	}
 }
 
+func TestParseSDRTempsKeepsNumberAndEntity(t *testing.T) {
+	sensors := parseSDRTemps("Temp             | 0Fh | ok  |  3.2 | 35 degrees C\n")
+	want := ipmi.Sensor{Name: "Temp", Number: 0x0f, Entity: "3.2", Type: ipmi.SensorTypeTemperature, Value: 35}
+	if len(sensors) != 1 || sensors[0] != want {
+		t.Fatalf("sensors = %+v, want [%+v]", sensors, want)
+	}
+}
+
+func TestSensorMapReading(t *testing.T) {
+	r740 := []ipmi.Sensor{
+		{Name: "Inlet Temp", Number: 0x04, Entity: "7.1", Value: 21},
+		{Name: "Exhaust Temp", Number: 0x01, Entity: "7.1", Value: 30},
+		{Name: "CPU2 Temp", Number: 0x0f, Entity: "7.1", Value: 48},
+		{Name: "CPU1 Temp", Number: 0x0e, Entity: "7.1", Value: 52},
+		{Name: "DIMM Temp", Number: 0x2a, Entity: "32.1", Value: 40},
+	}
+	tests := []struct {
+		name    string
+		mon     config.MonitoringConfig
+		sensors []ipmi.Sensor
+		want    []int
+		inlet   *int
+		wantErr bool
+	}{
+		{
+			name:    "R730 sensors keep only the CPU Temp ones",
+			sensors: []ipmi.Sensor{{Name: "Inlet Temp", Value: 20}, {Name: "Exhaust Temp", Value: 28}, {Name: "Temp", Value: 33.4}, {Name: "Temp", Value: 34.6}},
+			want:    []int{33, 35},
+			inlet:   intPtr(20),
+		},
+		{
+			name:    "renamed CPU sensors map by name",
+			sensors: []ipmi.Sensor{{Name: "Inlet Temp", Value: 20}, {Name: "CPU1 Temp", Value: 41}},
+			want:    []int{41},
+			inlet:   intPtr(20),
+		},
+		{
+			name:    "only chassis sensors is an error",
+			sensors: []ipmi.Sensor{{Name: "Inlet Temp", Value: 20}, {Name: "Exhaust Temp", Value: 28}},
+			wantErr: true,
+		},
+		{
+			name:    "implausible readings are ignored",
+			sensors: []ipmi.Sensor{{Name: "Temp", Value: 0}, {Name: "Temp", Value: 200}},
+			wantErr: true,
+		},
+		{
+			name:    "r740 profile orders CPUs by socket and skips the DIMMs",
+			mon:     config.MonitoringConfig{SDRProfile: "r740"},
+			sensors: r740,
+			want:    []int{52, 48},
+			inlet:   intPtr(21),
+		},
+		{
+			name:    "r730 profile maps the CPUs by entity instance",
+			mon:     config.MonitoringConfig{SDRProfile: "R730"},
+			sensors: []ipmi.Sensor{{Name: "Temp", Entity: "3.2", Value: 35}, {Name: "Temp", Entity: "3.1", Value: 33}},
+			want:    []int{33, 35},
+		},
+		{
+			name:    "r730 profile does not guess at names it does not know",
+			mon:     config.MonitoringConfig{SDRProfile: "r730"},
+			sensors: r740,
+			inlet:   intPtr(21),
+			wantErr: true,
+		},
+		{
+			name: "sdr_map entries come before the profile",
+			mon: config.MonitoringConfig{SDRProfile: "r740", SDRMap: []config.SDRMapping{
+				{Name: "^CPU2 Temp$", Role: config.SDRRoleIgnore},
+				{Number: intPtr(0x2a), Role: "cpu1"},
+			}},
+			sensors: r740,
+			want:    []int{52, 40},
+			inlet:   intPtr(21),
+		},
+		{
+			name: "sdr_map entries match entity for any instance",
+			mon: config.MonitoringConfig{SDRMap: []config.SDRMapping{
+				{Entity: "65", Role: config.SDRRoleCPU},
+				{Name: "(?i)ambient", Role: config.SDRRoleInlet},
+			}},
+			sensors: []ipmi.Sensor{{Name: "Ambient", Value: 19}, {Name: "SoC", Entity: "65.2", Value: 61}},
+			want:    []int{61},
+			inlet:   intPtr(19),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newSensorMap(tt.mon).reading(tt.sensors)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !equalInts(r.Temps, tt.want) || r.Max != maxInt(tt.want) {
+				t.Fatalf("temps = %v max %d, want %v", r.Temps, r.Max, tt.want)
+			}
+			if (r.Inlet == nil) != (tt.inlet == nil) || (r.Inlet != nil && *r.Inlet != *tt.inlet) {
+				t.Fatalf("inlet = %v, want %v", r.Inlet, tt.inlet)
+			}
+		})
+	}
+}
+
+func TestSensorMapErrorListsSensors(t *testing.T) {
+	_, err := newSensorMap(config.MonitoringConfig{SDRProfile: "r750"}).reading([]ipmi.Sensor{
+		{Name: "Inlet Temp", Number: 0x04, Entity: "7.1", Value: 20},
+		{Name: "SoC Temp", Number: 0x31, Entity: "65.1", Value: 55},
+	})
+	if err == nil {
+		t.Fatal("expected an error with no CPU sensor mapped")
+	}
+	for _, want := range []string{`"r750"`, `"SoC Temp" (number 31h, entity 65.1) 55°C`, `"Inlet Temp"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+type fakeSensors []ipmi.Sensor
+
+func (fakeSensors) Usable() bool { return true }
+
+func (f fakeSensors) TemperatureSensors(ctx context.Context) ([]ipmi.Sensor, error) {
+	return f, nil
+}
+
+func TestReadUsesNativeSession(t *testing.T) {
+	m := NewCPUMonitor(config.Default())
+	m.native = fakeSensors{{Name: "Temp", Value: 52}, {Name: "Temp", Value: 47}}
+
+	r, err := m.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if r.Max != 52 || !equalInts(r.Temps, []int{52, 47}) {
+		t.Fatalf("reading = %+v, want temps [52 47] max 52", r)
+	}
+}
+
+// TestRemoteReadPasswordNotInArgv: the ipmitool fallback must hand the
+// password over in IPMI_PASSWORD, never on the command line.
+func TestRemoteReadPasswordNotInArgv(t *testing.T) {
+	cfg := config.Default()
+	cfg.IDRAC.Host = "10.0.0.5"
+	cfg.IDRAC.Username = "root"
+	cfg.IDRAC.Password = "s3cret-pw"
+	m := NewCPUMonitor(cfg)
+	var argv, env []string
+	m.output = func(ctx context.Context, e []string, name string, args ...string) (string, string, error) {
+		argv, env = append([]string{name}, args...), e
+		return "Temp             | 0Eh | ok  |  3.1 | 45 degrees C\n", "", nil
+	}
+
+	if _, err := m.Read(); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := "ipmitool -I lanplus -H 10.0.0.5 -U root -E sdr type temperature"
+	if got := strings.Join(argv, " "); got != want {
+		t.Fatalf("argv = %q, want %q", got, want)
+	}
+	if !slices.Contains(env, "IPMI_PASSWORD=s3cret-pw") {
+		t.Fatalf("env = %v, want IPMI_PASSWORD set", env)
+	}
+}
+
 func equalInts(a, b []int) bool {
 	if len(a) != len(b) {
 		return false
@@ -80,3 +268,37 @@ func equalInts(a, b []int) bool {
 	}
 	return true
 }
+
+func TestReadKeepsInletAndExhaust(t *testing.T) {
+	m := NewCPUMonitor(config.Default())
+	m.output = func(ctx context.Context, env []string, name string, args ...string) (string, string, error) {
+		return `Inlet Temp       | 04h | ok  |  7.1 | -3 degrees C
+Exhaust Temp     | 01h | ok  |  7.1 | 28 degrees C
+Temp             | 0Eh | ok  |  3.1 | 33 degrees C
+Temp             | 0Fh | ok  |  3.2 | 35 degrees C`, "", nil
+	}
+
+	r, err := m.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !equalInts(r.Temps, []int{33, 35}) {
+		t.Fatalf("temps = %v, want only the CPU sensors", r.Temps)
+	}
+	if r.Inlet == nil || *r.Inlet != -3 || r.Exhaust == nil || *r.Exhaust != 28 {
+		t.Fatalf("inlet/exhaust = %v/%v, want -3/28", r.Inlet, r.Exhaust)
+	}
+}
+
+func TestNativeReadKeepsInletAndExhaust(t *testing.T) {
+	m := NewCPUMonitor(config.Default())
+	m.native = fakeSensors{{Name: "Inlet Temp", Value: 21.4}, {Name: "Temp", Value: 52}}
+
+	r, err := m.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if r.Inlet == nil || *r.Inlet != 21 || r.Exhaust != nil {
+		t.Fatalf("inlet/exhaust = %v/%v, want 21 and no exhaust", r.Inlet, r.Exhaust)
+	}
+}
@@ -0,0 +1,140 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+	"github.com/sethpjohnson/only-fan-controller/internal/ipmi"
+)
+
+// FanSpeed is one fan tachometer reading. Unreadable marks a fan the BMC
+// has no reading for (RPM is 0): dead, unplugged, or a header with no fan.
+type FanSpeed struct {
+	Name       string `json:"name"`
+	RPM        int    `json:"rpm"`
+	Unreadable bool   `json:"unreadable,omitempty"`
+}
+
+// fanSource is the native BMC session (*ipmi.Client); an interface so tests
+// can stand in for the BMC.
+type fanSource interface {
+	Usable() bool
+	FanSensors(ctx context.Context) ([]ipmi.Sensor, error)
+}
+
+// FanMonitor reads the chassis fan tachometers from the BMC, so the
+// controller can check that the fans actually run at the speed it set.
+type FanMonitor struct {
+	cfg *config.Config
+	// native, when set, reads the tachometers over the persistent RMCP+
+	// session instead of running ipmitool.
+	native fanSource
+	// output runs ipmitool; a field so tests can capture the command line.
+	output outputFunc
+}
+
+func NewFanMonitor(cfg *config.Config) *FanMonitor {
+	return &FanMonitor{cfg: cfg, output: realOutput}
+}
+
+// UseNativeIPMI reads the tachometers over client instead of spawning
+// ipmitool for each read.
+func (m *FanMonitor) UseNativeIPMI(client *ipmi.Client) {
+	m.native = client
+}
+
+// Read returns every fan tachometer, in the BMC's order, including those
+// without a reading. A BMC with no fan reading at all is an error.
+func (m *FanMonitor) Read() ([]FanSpeed, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout(m.cfg))
+	defer cancel()
+
+	sensors, err := m.fanSensors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fans := make([]FanSpeed, 0, len(sensors))
+	readable := 0
+	for _, s := range sensors {
+		fans = append(fans, FanSpeed{Name: strings.TrimSpace(s.Name), RPM: int(math.Round(s.Value)), Unreadable: s.Unavailable})
+		if !s.Unavailable {
+			readable++
+		}
+	}
+	if readable == 0 {
+		return nil, errors.New("no fan tachometer readings from the BMC")
+	}
+	return fans, nil
+}
+
+// fanSensors uses the native session first and ipmitool when that fails.
+func (m *FanMonitor) fanSensors(ctx context.Context) ([]ipmi.Sensor, error) {
+	if m.native != nil && m.native.Usable() {
+		sensors, err := m.native.FanSensors(ctx)
+		if err == nil {
+			return sensors, nil
+		}
+		log.Printf("Native IPMI fan read failed (%v); falling back to ipmitool", err)
+	}
+
+	args, env := m.cfg.IDRAC.IpmitoolConnection()
+	args = append(args, "sdr", "type", "fan")
+	stdout, stderr, err := m.output(ctx, env, "ipmitool", args...)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("ipmitool fan read timed out: %w", err)
+		}
+		return nil, fmt.Errorf("ipmitool error: %v, stderr: %s", err, stderr)
+	}
+	return parseSDRFans(stdout), nil
+}
+
+// sdrRPMRe matches the reading column of `ipmitool sdr type fan`.
+var sdrRPMRe = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*RPM`)
+
+// parseSDRFans turns `ipmitool sdr type fan` output into sensors. A row
+// without a reading ("ns" status or "No Reading", how a dead or unplugged fan
+// reads) is an Unavailable sensor; other rows without an RPM reading, such as
+// the redundancy status, are left out. From an R730:
+//
+//	Fan1             | 30h | ok  |  7.1 | 3360 RPM
+//	Fan2             | 31h | ok  |  7.1 | 3480 RPM
+//	Fan3             | 32h | ns  |  7.1 | No Reading
+//	Fan Redundancy   | 75h | ok  |  7.1 | Fully Redundant
+func parseSDRFans(output string) []ipmi.Sensor {
+	var sensors []ipmi.Sensor
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) < 5 {
+			continue
+		}
+		number, _ := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(fields[1]), "h"), 16, 8)
+		sensor := ipmi.Sensor{
+			Name:   strings.TrimSpace(fields[0]),
+			Number: byte(number),
+			Entity: strings.TrimSpace(fields[3]),
+			Type:   ipmi.SensorTypeFan,
+		}
+		reading := strings.TrimSpace(fields[len(fields)-1])
+		if m := sdrRPMRe.FindStringSubmatch(reading); m != nil {
+			v, err := strconv.ParseFloat(m[1], 64)
+			if err != nil {
+				continue
+			}
+			sensor.Value = v
+		} else if strings.TrimSpace(fields[2]) == "ns" || reading == "No Reading" {
+			sensor.Unavailable = true
+		} else {
+			continue
+		}
+		sensors = append(sensors, sensor)
+	}
+	return sensors
+}
@@ -0,0 +1,98 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+	"github.com/sethpjohnson/only-fan-controller/internal/ipmi"
+)
+
+func TestFanMonitorParsesIpmitool(t *testing.T) {
+	m := NewFanMonitor(config.Default())
+	var argv []string
+	m.output = func(ctx context.Context, env []string, name string, args ...string) (string, string, error) {
+		argv = append([]string{name}, args...)
+		return `Fan1             | 30h | ok  |  7.1 | 3360 RPM
+Fan2             | 31h | ok  |  7.1 | 3480 RPM
+Fan3             | 32h | ns  |  7.1 | No Reading
+Fan Redundancy   | 75h | ok  |  7.1 | Fully Redundant
+`, "", nil
+	}
+
+	fans, err := m.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if got := strings.Join(argv, " "); !strings.HasSuffix(got, " sdr type fan") {
+		t.Fatalf("argv = %q, want an sdr type fan listing", got)
+	}
+	want := []FanSpeed{{Name: "Fan1", RPM: 3360}, {Name: "Fan2", RPM: 3480}, {Name: "Fan3", Unreadable: true}}
+	if len(fans) != 3 || fans[0] != want[0] || fans[1] != want[1] || fans[2] != want[2] {
+		t.Fatalf("fans = %+v, want %+v", fans, want)
+	}
+}
+
+// A dead or unplugged fan reads as "No Reading"; it must stay in the list so
+// the controller can tell it stopped, while discrete rows are still dropped.
+func TestParseSDRFansKeepsNoReading(t *testing.T) {
+	sensors := parseSDRFans(`Fan1             | 30h | ok  |  7.1 | 3360 RPM
+Fan2             | 31h | cr  |  7.1 | No Reading
+Fan Redundancy   | 75h | ok  |  7.1 | Redundancy Lost
+`)
+	if len(sensors) != 2 || sensors[0].Unavailable || sensors[0].Value != 3360 ||
+		sensors[1].Name != "Fan2" || !sensors[1].Unavailable || sensors[1].Value != 0 || sensors[1].Number != 0x31 {
+		t.Fatalf("sensors = %+v, want Fan1 at 3360 RPM and Fan2 unavailable", sensors)
+	}
+}
+
+func TestFanMonitorUnreadableFansAloneAreAnError(t *testing.T) {
+	m := NewFanMonitor(config.Default())
+	m.output = func(ctx context.Context, env []string, name string, args ...string) (string, string, error) {
+		return "Fan1             | 30h | ns  |  7.1 | No Reading\n", "", nil
+	}
+	if fans, err := m.Read(); err == nil {
+		t.Fatalf("Read = %+v, want an error without any reading", fans)
+	}
+}
+
+func TestFanMonitorNoReadingIsAnError(t *testing.T) {
+	m := NewFanMonitor(config.Default())
+	m.output = func(ctx context.Context, env []string, name string, args ...string) (string, string, error) {
+		return "Fan Redundancy   | 75h | ok  |  7.1 | Fully Redundant\n", "", nil
+	}
+	if fans, err := m.Read(); err == nil {
+		t.Fatalf("Read = %+v, want an error without any tachometer", fans)
+	}
+}
+
+type fakeFans struct {
+	fans []ipmi.Sensor
+	err  error
+}
+
+func (fakeFans) Usable() bool { return true }
+
+func (f fakeFans) FanSensors(ctx context.Context) ([]ipmi.Sensor, error) {
+	return f.fans, f.err
+}
+
+func TestFanMonitorNativeFallsBackToIpmitool(t *testing.T) {
+	m := NewFanMonitor(config.Default())
+	m.native = fakeFans{fans: []ipmi.Sensor{{Name: "Fan1 RPM", Value: 6120}}}
+	m.output = func(ctx context.Context, env []string, name string, args ...string) (string, string, error) {
+		return "Fan1             | 30h | ok  |  7.1 | 3360 RPM\n", "", nil
+	}
+	fans, err := m.Read()
+	if err != nil || len(fans) != 1 || fans[0].RPM != 6120 {
+		t.Fatalf("native Read = %+v, %v; want Fan1 RPM at 6120", fans, err)
+	}
+
+	m.native = fakeFans{err: errors.New("session lost")}
+	fans, err = m.Read()
+	if err != nil || len(fans) != 1 || fans[0].RPM != 3360 {
+		t.Fatalf("fallback Read = %+v, %v; want ipmitool's Fan1 at 3360", fans, err)
+	}
+}
@@ -1,7 +1,6 @@
 package monitor
 
 import (
-	"bytes"
 	"context"
 	"encoding/csv"
 	"fmt"
@@ -15,16 +14,52 @@ import (
 
 type GPUMonitor struct {
 	cfg *config.Config
+	// backend is gpu.backend; "auto" is replaced by the detected backend on
+	// the first Read that finds one.
+	backend   string
+	hwmonRoot string
+	// output and lookPath run and locate the vendor tools; fields so tests can
+	// stand in for them.
+	output   outputFunc
+	lookPath func(file string) (string, error)
+	// stream is the persistent nvidia-smi (gpu.persistent); nil when off.
+	stream *nvidiaStream
+	// query is nvidiaQuery, or nvidiaBaseQuery once nvidia-smi has rejected
+	// a telemetry field.
+	query string
 }
 
 type GPUDevice struct {
 	Index       int    `json:"index"`
+	UUID        string `json:"uuid,omitempty"` // nvidia-smi only
 	Name        string `json:"name"`
 	Temp        int    `json:"temp"`
 	Utilization int    `json:"utilization"`
 	MemoryUsed  int    `json:"memory_used"`  // MB
 	MemoryTotal int    `json:"memory_total"` // MB
 	PowerDraw   int    `json:"power_draw"`   // Watts
+
+	// MemoryTemp is the HBM/memory junction temperature and FanSpeed the
+	// card's own fan (%); nil when the card does not report them (GDDR
+	// cards, passively cooled cards).
+	MemoryTemp    *int `json:"memory_temp,omitempty"`
+	FanSpeed      *int `json:"fan_speed,omitempty"`
+	GraphicsClock int  `json:"graphics_clock"` // MHz
+	MemoryClock   int  `json:"memory_clock"`   // MHz
+	// ThrottleReasons names the active clocks_throttle_reasons (nvidia-smi's
+	// field names, e.g. "hw_thermal_slowdown"); empty when running freely.
+	ThrottleReasons []string `json:"throttle_reasons,omitempty"`
+}
+
+// ThermalThrottled reports whether the card is slowing its clocks because it
+// is too hot, by its driver (sw) or its hardware (hw) thermal limit.
+func (d GPUDevice) ThermalThrottled() bool {
+	for _, r := range d.ThrottleReasons {
+		if r == "sw_thermal_slowdown" || r == "hw_thermal_slowdown" {
+			return true
+		}
+	}
+	return false
 }
 
 type GPUReading struct {
@@ -32,35 +67,160 @@ type GPUReading struct {
 	Max     int
 }
 
+// ThermalThrottled reports whether any GPU is thermally throttling.
+func (r *GPUReading) ThermalThrottled() bool {
+	for _, d := range r.Devices {
+		if d.ThermalThrottled() {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxMemoryTemp returns the hottest reported memory temperature, or nil when
+// no GPU reports one.
+func (r *GPUReading) MaxMemoryTemp() *int {
+	var hottest *int
+	for _, d := range r.Devices {
+		if d.MemoryTemp != nil && (hottest == nil || *d.MemoryTemp > *hottest) {
+			hottest = d.MemoryTemp
+		}
+	}
+	return hottest
+}
+
+// MaxFanSpeed returns the fastest reported GPU fan, or nil when no GPU has
+// one.
+func (r *GPUReading) MaxFanSpeed() *int {
+	var fastest *int
+	for _, d := range r.Devices {
+		if d.FanSpeed != nil && (fastest == nil || *d.FanSpeed > *fastest) {
+			fastest = d.FanSpeed
+		}
+	}
+	return fastest
+}
+
 func NewGPUMonitor(cfg *config.Config) *GPUMonitor {
-	return &GPUMonitor{cfg: cfg}
+	m := &GPUMonitor{
+		cfg:       cfg,
+		backend:   cfg.GPU.EffectiveBackend(),
+		hwmonRoot: cfg.Monitoring.Hwmon.EffectiveRoot(),
+		output:    realOutput,
+		lookPath:  exec.LookPath,
+		query:     nvidiaQuery,
+	}
+	if cfg.GPU.Persistent {
+		m.stream = newNvidiaStream(cfg.GPU.NvidiaSmiPath)
+	}
+	return m
 }
 
-// Read gets current GPU temperatures and stats via nvidia-smi
+// Close stops the persistent nvidia-smi, if one is running.
+func (m *GPUMonitor) Close() {
+	if m.stream != nil {
+		m.stream.close()
+	}
+}
+
+// Read gets current GPU temperatures and stats from the configured backend.
 func (m *GPUMonitor) Read() (*GPUReading, error) {
 	if !m.cfg.GPU.Enabled {
 		return &GPUReading{}, nil
 	}
 
+	if m.backend == config.GPUBackendAuto {
+		backend, err := m.detect()
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("GPU backend: %s (auto-detected)", backend)
+		m.backend = backend
+	}
+
+	var devices []GPUDevice
+	var err error
+	switch m.backend {
+	case config.GPUBackendROCm:
+		devices, err = m.readROCm()
+	case config.GPUBackendHwmon:
+		devices, err = readGPUHwmon(m.hwmonRoot)
+	default:
+		devices, err = m.readNvidia()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reading := &GPUReading{
+		Devices: devices,
+		Max:     maxGPUTemp(devices),
+	}
+
+	return reading, nil
+}
+
+// detect picks the first GPU backend present on this host: nvidia-smi, then
+// rocm-smi, then an amdgpu/i915/xe hwmon node.
+func (m *GPUMonitor) detect() (string, error) {
+	if _, err := m.lookPath(m.cfg.GPU.NvidiaSmiPath); err == nil {
+		return config.GPUBackendNvidia, nil
+	}
+	if _, err := m.lookPath(m.cfg.GPU.EffectiveRocmSmiPath()); err == nil {
+		return config.GPUBackendROCm, nil
+	}
+	if len(gpuHwmonDevices(m.hwmonRoot)) > 0 {
+		return config.GPUBackendHwmon, nil
+	}
+	return "", fmt.Errorf("no GPU found: neither %s, %s nor an amdgpu/i915/xe hwmon node is present (set gpu.enabled: false on a host without GPUs)",
+		m.cfg.GPU.NvidiaSmiPath, m.cfg.GPU.EffectiveRocmSmiPath())
+}
+
+// nvidia-smi arguments selecting the GPUDevice fields, in parseGPUOutput's
+// column order. nvidia-smi rejects the whole query when the driver does not
+// know one of the fields, so nvidiaBaseQuery leaves out the telemetry fields
+// (temperature.memory through clocks_throttle_reasons.active) for drivers
+// that lack them.
+const (
+	nvidiaBaseFields = "index,name,temperature.gpu,utilization.gpu,memory.used,memory.total,power.draw"
+	nvidiaQuery      = "--query-gpu=" + nvidiaBaseFields +
+		",temperature.memory,fan.speed,clocks.current.graphics,clocks.current.memory,clocks_throttle_reasons.active,uuid"
+	nvidiaBaseQuery = "--query-gpu=" + nvidiaBaseFields + ",uuid"
+	nvidiaFormat    = "--format=csv,noheader,nounits"
+)
+
+// nvidiaFieldRejected reports whether nvidia-smi output says a queried field
+// is unknown to the driver ("Field "fan.speed" is not a valid field to
+// query.").
+func nvidiaFieldRejected(output string) bool {
+	return strings.Contains(output, "is not a valid field")
+}
+
+// readNvidia returns the persistent nvidia-smi's rows when they are current,
+// else runs nvidia-smi once.
+func (m *GPUMonitor) readNvidia() ([]GPUDevice, error) {
+	if m.stream != nil {
+		if devices, ok := m.stream.devices(); ok {
+			return devices, nil
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout(m.cfg))
 	defer cancel()
 
 	// Query nvidia-smi for key metrics in CSV format
-	cmd := exec.CommandContext(ctx, m.cfg.GPU.NvidiaSmiPath,
-		"--query-gpu=index,name,temperature.gpu,utilization.gpu,memory.used,memory.total,power.draw",
-		"--format=csv,noheader,nounits",
-	)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
+	stdout, stderr, err := m.output(ctx, nil, m.cfg.GPU.NvidiaSmiPath, m.query, nvidiaFormat)
+	if err != nil && m.query != nvidiaBaseQuery && nvidiaFieldRejected(stdout+stderr) {
+		log.Printf("nvidia-smi rejected the GPU telemetry fields; reading temperatures without them: %s", strings.TrimSpace(stdout+stderr))
+		m.query = nvidiaBaseQuery
+		stdout, stderr, err = m.output(ctx, nil, m.cfg.GPU.NvidiaSmiPath, m.query, nvidiaFormat)
+	}
+	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			log.Printf("nvidia-smi timed out after %s", commandTimeout(m.cfg))
 			return nil, fmt.Errorf("nvidia-smi timed out: %w", err)
 		}
-		log.Printf("nvidia-smi error: %v, stderr: %s", err, stderr.String())
+		log.Printf("nvidia-smi error: %v, stderr: %s", err, stderr)
 		return nil, err
 	}
 
@@ -68,22 +228,18 @@ func (m *GPUMonitor) Read() (*GPUReading, error) {
 	// silent 0°C reading — otherwise empty/garbage nvidia-smi output would look
 	// like a healthy "cold" GPU and the fail-safe would never engage. Mirrors the
 	// CPU treatment.
-	devices, err := parseGPUOutput(stdout.String())
+	devices, err := parseGPUOutput(stdout)
 	if err != nil {
-		log.Printf("GPU output parse failed: %v; raw output: %q", err, stdout.String())
+		log.Printf("GPU output parse failed: %v; raw output: %q", err, stdout)
 		return nil, err
 	}
-
-	reading := &GPUReading{
-		Devices: devices,
-		Max:     maxGPUTemp(devices),
-	}
-
-	return reading, nil
+	return devices, nil
 }
 
 // parseGPUOutput parses nvidia-smi CSV output. It returns an error when no valid
-// device rows are present.
+// device rows are present. The telemetry columns after power.draw are optional,
+// so a row with only the first seven still parses, and an eighth column alone
+// is the uuid (nvidiaBaseQuery).
 func parseGPUOutput(output string) ([]GPUDevice, error) {
 	var devices []GPUDevice
 
@@ -115,6 +271,19 @@ func parseGPUOutput(output string) ([]GPUDevice, error) {
 			MemoryTotal: parseInt(record[5]),
 			PowerDraw:   parseInt(record[6]),
 		}
+		if len(record) >= 12 {
+			device.MemoryTemp = parseOptionalInt(record[7])
+			device.FanSpeed = parseOptionalInt(record[8])
+			device.GraphicsClock = parseInt(record[9])
+			device.MemoryClock = parseInt(record[10])
+			device.ThrottleReasons = throttleReasons(record[11])
+		}
+		switch {
+		case len(record) >= 13:
+			device.UUID = record[12]
+		case len(record) == 8:
+			device.UUID = record[7]
+		}
 
 		devices = append(devices, device)
 	}
@@ -145,6 +314,53 @@ func parseInt(s string) int {
 	return val
 }
 
+// parseOptionalInt is parseInt for fields a card may not have: "N/A" (or
+// anything unparseable) is nil rather than 0.
+func parseOptionalInt(s string) *int {
+	s = strings.TrimSpace(s)
+	if idx := strings.Index(s, "."); idx != -1 {
+		s = s[:idx]
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// nvidiaThrottleReasons are the clocks_throttle_reasons bits, by the name of
+// nvidia-smi's per-reason field.
+var nvidiaThrottleReasons = []struct {
+	bit  uint64
+	name string
+}{
+	{0x1, "gpu_idle"},
+	{0x2, "applications_clocks_setting"},
+	{0x4, "sw_power_cap"},
+	{0x8, "hw_slowdown"},
+	{0x10, "sync_boost"},
+	{0x20, "sw_thermal_slowdown"},
+	{0x40, "hw_thermal_slowdown"},
+	{0x80, "hw_power_brake_slowdown"},
+	{0x100, "display_clock_setting"},
+}
+
+// throttleReasons decodes clocks_throttle_reasons.active
+// ("0x0000000000000040") into reason names.
+func throttleReasons(s string) []string {
+	mask, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(s), "0x"), 16, 64)
+	if err != nil {
+		return nil
+	}
+	var reasons []string
+	for _, r := range nvidiaThrottleReasons {
+		if mask&r.bit != 0 {
+			reasons = append(reasons, r.name)
+		}
+	}
+	return reasons
+}
+
 func maxGPUTemp(devices []GPUDevice) int {
 	max := 0
 	for _, d := range devices {
@@ -155,8 +371,18 @@ func maxGPUTemp(devices []GPUDevice) int {
 	return max
 }
 
-// IsAvailable checks if nvidia-smi is available
+// IsAvailable checks if the configured GPU backend (any, for auto) is present
 func (m *GPUMonitor) IsAvailable() bool {
-	cmd := exec.Command(m.cfg.GPU.NvidiaSmiPath, "--version")
-	return cmd.Run() == nil
+	var err error
+	switch m.backend {
+	case config.GPUBackendNvidia:
+		_, err = m.lookPath(m.cfg.GPU.NvidiaSmiPath)
+	case config.GPUBackendROCm:
+		_, err = m.lookPath(m.cfg.GPU.EffectiveRocmSmiPath())
+	case config.GPUBackendHwmon:
+		return len(gpuHwmonDevices(m.hwmonRoot)) > 0
+	default:
+		_, err = m.detect()
+	}
+	return err == nil
 }
@@ -0,0 +1,100 @@
+package monitor
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gpuHwmonChips are the hwmon drivers of GPUs: AMD (amdgpu) and Intel (i915,
+// and xe on Arc and newer).
+var gpuHwmonChips = map[string]bool{"amdgpu": true, "i915": true, "xe": true}
+
+// gpuHwmonDevices lists the hwmon device directories under root that belong
+// to a GPU driver, in directory order.
+func gpuHwmonDevices(root string) []string {
+	devices, _ := filepath.Glob(filepath.Join(root, "hwmon*"))
+	sort.Strings(devices)
+	var gpus []string
+	for _, dev := range devices {
+		if gpuHwmonChips[readSysfs(filepath.Join(dev, "name"))] {
+			gpus = append(gpus, dev)
+		}
+	}
+	return gpus
+}
+
+// readGPUHwmon reads every GPU hwmon node under root. The GPUs are numbered in
+// directory order. The "edge" input is preferred (amdgpu's equivalent of
+// nvidia-smi's temperature.gpu), else the first readable one; "mem" is the
+// memory temperature. Utilization and VRAM come from the PCI device's amdgpu
+// attributes and stay 0 on Intel. A
+// node without a readable temperature is skipped; none at all is an error.
+func readGPUHwmon(root string) ([]GPUDevice, error) {
+	var devices []GPUDevice
+	for i, dev := range gpuHwmonDevices(root) {
+		temp, ok := gpuHwmonTemp(dev)
+		if !ok {
+			continue
+		}
+		chip := readSysfs(filepath.Join(dev, "name"))
+		d := GPUDevice{Index: i, Name: chip, Temp: temp}
+		if mem, ok := gpuHwmonLabeled(dev, "mem"); ok {
+			d.MemoryTemp = &mem
+		}
+		if name := readSysfs(filepath.Join(dev, "device", "product_name")); name != "" {
+			d.Name = name
+		}
+		for _, attr := range []string{"power1_average", "power1_input"} {
+			if uw, err := strconv.Atoi(readSysfs(filepath.Join(dev, attr))); err == nil {
+				d.PowerDraw = uw / 1000000
+				break
+			}
+		}
+		d.Utilization = parseInt(readSysfs(filepath.Join(dev, "device", "gpu_busy_percent")))
+		d.MemoryUsed = bytesToMB(readSysfs(filepath.Join(dev, "device", "mem_info_vram_used")))
+		d.MemoryTotal = bytesToMB(readSysfs(filepath.Join(dev, "device", "mem_info_vram_total")))
+		devices = append(devices, d)
+	}
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("no readable amdgpu/i915/xe hwmon temperature under %s", root)
+	}
+	return devices, nil
+}
+
+// gpuHwmonLabeled returns the input of one hwmon device labeled label.
+func gpuHwmonLabeled(dev, label string) (int, bool) {
+	inputs, _ := filepath.Glob(filepath.Join(dev, "temp*_input"))
+	for _, input := range inputs {
+		if readSysfs(strings.TrimSuffix(input, "_input")+"_label") != label {
+			continue
+		}
+		if temp, err := readMillidegrees(input); err == nil && temp > 0 && temp < 120 {
+			return temp, true
+		}
+	}
+	return 0, false
+}
+
+// gpuHwmonTemp returns the GPU temperature of one hwmon device.
+func gpuHwmonTemp(dev string) (int, bool) {
+	inputs, _ := filepath.Glob(filepath.Join(dev, "temp*_input"))
+	sort.Slice(inputs, func(i, j int) bool { return tempIndex(inputs[i]) < tempIndex(inputs[j]) })
+
+	first, found := 0, false
+	for _, input := range inputs {
+		temp, err := readMillidegrees(input)
+		if err != nil || temp <= 0 || temp >= 120 {
+			continue
+		}
+		if readSysfs(strings.TrimSuffix(input, "_input")+"_label") == "edge" {
+			return temp, true
+		}
+		if !found {
+			first, found = temp, true
+		}
+	}
+	return first, found
+}
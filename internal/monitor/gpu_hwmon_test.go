@@ -0,0 +1,80 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// radeonAndArcHost is a host with a Radeon Pro W6800 (amdgpu) and an Arc A380
+// (xe) next to the CPU's coretemp.
+func radeonAndArcHost(t *testing.T) string {
+	root := fakeSysfs(t, map[string]map[string]string{
+		"hwmon0": {"name": "coretemp\n", "temp1_label": "Package id 0\n", "temp1_input": "48000\n"},
+		"hwmon1": {
+			"name":        "amdgpu\n",
+			"temp1_label": "edge\n", "temp1_input": "52000\n",
+			"temp2_label": "junction\n", "temp2_input": "61000\n",
+			"temp3_label": "mem\n", "temp3_input": "58000\n",
+			"power1_average": "87000000\n",
+		},
+		"hwmon2": {
+			"name":        "xe\n",
+			"temp2_label": "pkg\n", "temp2_input": "44000\n",
+			"temp3_label": "vram\n", "temp3_input": "47000\n",
+		},
+	})
+	device := filepath.Join(root, "hwmon1", "device")
+	if err := os.MkdirAll(device, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"product_name":        "Radeon PRO W6800\n",
+		"gpu_busy_percent":    "35\n",
+		"mem_info_vram_used":  "4294967296\n",
+		"mem_info_vram_total": "34342961152\n",
+	} {
+		if err := os.WriteFile(filepath.Join(device, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestReadGPUHwmon(t *testing.T) {
+	devices, err := readGPUHwmon(radeonAndArcHost(t))
+	if err != nil {
+		t.Fatalf("readGPUHwmon: %v", err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("got %d devices, want the two GPUs only: %+v", len(devices), devices)
+	}
+	want := GPUDevice{
+		Index:       0,
+		Name:        "Radeon PRO W6800",
+		Temp:        52,
+		Utilization: 35,
+		MemoryUsed:  4096,
+		MemoryTotal: 32752,
+		PowerDraw:   87,
+		MemoryTemp:  intPtr(58),
+	}
+	if !reflect.DeepEqual(devices[0], want) {
+		t.Fatalf("amdgpu = %+v, want %+v", devices[0], want)
+	}
+	if arc := devices[1]; arc.Index != 1 || arc.Name != "xe" || arc.Temp != 44 || arc.MemoryTemp != nil {
+		t.Fatalf("xe = %+v, want index 1 at its first input, 44", arc)
+	}
+}
+
+func TestReadGPUHwmonWithoutGPUIsAnError(t *testing.T) {
+	root := fakeSysfs(t, map[string]map[string]string{
+		"hwmon0": {"name": "coretemp\n", "temp1_input": "48000\n"},
+		// A GPU node whose sensor cannot be read right now.
+		"hwmon1": {"name": "amdgpu\n", "temp1_input": ""},
+	})
+	if devices, err := readGPUHwmon(root); err == nil {
+		t.Fatalf("readGPUHwmon = %+v, want an error", devices)
+	}
+}
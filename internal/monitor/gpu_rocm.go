@@ -0,0 +1,139 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rocmArgs asks rocm-smi for the fields a GPUDevice carries, as JSON.
+var rocmArgs = []string{
+	"--showtemp", "--showuse", "--showpower", "--showmeminfo", "vram", "--showproductname", "--json",
+}
+
+func (m *GPUMonitor) readROCm() ([]GPUDevice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout(m.cfg))
+	defer cancel()
+
+	path := m.cfg.GPU.EffectiveRocmSmiPath()
+	stdout, stderr, err := m.output(ctx, nil, path, rocmArgs...)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("rocm-smi timed out after %s", commandTimeout(m.cfg))
+			return nil, fmt.Errorf("rocm-smi timed out: %w", err)
+		}
+		log.Printf("rocm-smi error: %v, stderr: %s", err, stderr)
+		return nil, err
+	}
+
+	devices, err := parseROCmOutput(stdout)
+	if err != nil {
+		log.Printf("GPU output parse failed: %v; raw output: %q", err, stdout)
+		return nil, err
+	}
+	return devices, nil
+}
+
+// parseROCmOutput parses `rocm-smi --json` output: one object per card keyed
+// "cardN" whose field names vary a little between ROCm releases, so fields are
+// matched case-insensitively and by prefix. The edge temperature is used,
+// like nvidia-smi's temperature.gpu, falling back to junction and memory on
+// cards without an edge sensor. A card without any temperature is skipped; no
+// card at all is an error.
+func parseROCmOutput(output string) ([]GPUDevice, error) {
+	var cards map[string]map[string]any
+	if err := json.Unmarshal([]byte(output), &cards); err != nil {
+		return nil, fmt.Errorf("failed to parse rocm-smi JSON output: %w", err)
+	}
+
+	var devices []GPUDevice
+	for key, fields := range cards {
+		index, err := strconv.Atoi(strings.TrimPrefix(key, "card"))
+		if err != nil || !strings.HasPrefix(key, "card") {
+			continue // "system" and other non-card entries
+		}
+		f := make(map[string]string, len(fields))
+		for k, v := range fields {
+			f[strings.ToLower(k)] = strings.TrimSpace(fmt.Sprint(v))
+		}
+
+		temp := 0
+		for _, sensor := range []string{"edge", "junction", "memory"} {
+			if v, ok := rocmField(f, "temperature (sensor "+sensor+")"); ok {
+				if t := parseInt(v); t > 0 {
+					temp = t
+					break
+				}
+			}
+		}
+		if temp == 0 {
+			continue
+		}
+
+		d := GPUDevice{Index: index, Temp: temp}
+		if v, ok := rocmField(f, "temperature (sensor memory)"); ok {
+			d.MemoryTemp = parseOptionalInt(v)
+		}
+		if v, ok := rocmField(f, "card series"); ok {
+			d.Name = v
+		} else if v, ok := rocmField(f, "card model"); ok {
+			d.Name = v
+		}
+		if v, ok := rocmField(f, "gpu use (%)"); ok {
+			d.Utilization = parseInt(v)
+		}
+		for _, prefix := range []string{"average graphics package power", "current socket graphics package power"} {
+			if v, ok := rocmField(f, prefix); ok {
+				d.PowerDraw = parseInt(v)
+				break
+			}
+		}
+		if v, ok := rocmField(f, "vram total used memory (b)"); ok {
+			d.MemoryUsed = bytesToMB(v)
+		}
+		if v, ok := rocmField(f, "vram total memory (b)"); ok {
+			d.MemoryTotal = bytesToMB(v)
+		}
+		devices = append(devices, d)
+	}
+
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("no GPU with a temperature found in rocm-smi output")
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Index < devices[j].Index })
+	return devices, nil
+}
+
+// rocmField returns the first field whose lower-cased name starts with prefix
+// and has a value ("N/A" counts as none).
+func rocmField(fields map[string]string, prefix string) (string, bool) {
+	// Map order is random; sort so that a prefix matching two fields always
+	// picks the same one.
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if v := fields[k]; v != "" && v != "N/A" {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// bytesToMB converts a byte count to MB (MiB, as nvidia-smi reports).
+func bytesToMB(s string) int {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0
+	}
+	return int(math.Round(v / (1 << 20)))
+}
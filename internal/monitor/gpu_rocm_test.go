@@ -0,0 +1,150 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+)
+
+// rocmMI100 is `rocm-smi --showtemp --showuse --showpower --showmeminfo vram
+// --showproductname --json` from a two-card Radeon Instinct MI100 host (ROCm
+// 5.7).
+const rocmMI100 = `{"card0": {"Temperature (Sensor edge) (C)": "41.0", "Temperature (Sensor junction) (C)": "44.0", "Temperature (Sensor memory) (C)": "49.0", "Average Graphics Package Power (W)": "38.0", "GPU use (%)": "0", "VRAM Total Memory (B)": "34342961152", "VRAM Total Used Memory (B)": "10854400", "Card series": "Arcturus GL-XL [Instinct MI100]", "Card model": "0x0c34", "Card vendor": "Advanced Micro Devices, Inc. [AMD/ATI]", "Card SKU": "D3430401"}, "card1": {"Temperature (Sensor edge) (C)": "46.0", "Temperature (Sensor junction) (C)": "52.0", "Temperature (Sensor memory) (C)": "55.0", "Average Graphics Package Power (W)": "129.0", "GPU use (%)": "87", "VRAM Total Memory (B)": "34342961152", "VRAM Total Used Memory (B)": "17179869184", "Card series": "Arcturus GL-XL [Instinct MI100]", "Card model": "0x0c34", "Card vendor": "Advanced Micro Devices, Inc. [AMD/ATI]", "Card SKU": "D3430401"}, "system": {"Driver version": "6.2.4"}}`
+
+// rocmMI210 is the same query from ROCm 6.1 on an MI210, which names the power
+// field differently, capitalizes "Card Series" and has no edge sensor.
+const rocmMI210 = `{"card0": {"Temperature (Sensor edge) (C)": "N/A", "Temperature (Sensor junction) (C)": "38.0", "Temperature (Sensor memory) (C)": "42.0", "Current Socket Graphics Package Power (W)": "43.0", "GPU use (%)": "2", "VRAM Total Memory (B)": "68702699520", "VRAM Total Used Memory (B)": "11145216", "Card Series": "Aldebaran/MI200 [Instinct MI210]", "Card Model": "0x740f"}}`
+
+func TestParseROCmOutput(t *testing.T) {
+	tests := []struct {
+		name      string
+		output    string
+		wantTemps []int
+		wantErr   bool
+	}{
+		{name: "two MI100s", output: rocmMI100, wantTemps: []int{41, 46}},
+		{name: "MI210 without an edge sensor uses junction", output: rocmMI210, wantTemps: []int{38}},
+		{name: "empty output is an error, never 0C", output: "", wantErr: true},
+		{name: "rocm-smi error text is an error", output: "ERROR: GPU[0] : Unable to get temperature", wantErr: true},
+		{name: "system entry only is an error", output: `{"system": {"Driver version": "6.2.4"}}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devices, err := parseROCmOutput(tt.output)
+			if tt.wantErr && err == nil {
+				t.Fatalf("expected error, got nil (devices=%+v)", devices)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(devices) != len(tt.wantTemps) {
+				t.Fatalf("device count = %d, want %d", len(devices), len(tt.wantTemps))
+			}
+			for i, want := range tt.wantTemps {
+				if devices[i].Temp != want {
+					t.Fatalf("device %d temp = %d, want %d", i, devices[i].Temp, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseROCmOutputFields(t *testing.T) {
+	devices, err := parseROCmOutput(rocmMI100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := GPUDevice{
+		Index:       1,
+		Name:        "Arcturus GL-XL [Instinct MI100]",
+		Temp:        46,
+		Utilization: 87,
+		MemoryUsed:  16384,
+		MemoryTotal: 32752,
+		PowerDraw:   129,
+		MemoryTemp:  intPtr(55),
+	}
+	if !reflect.DeepEqual(devices[1], want) {
+		t.Fatalf("card1 = %+v, want %+v", devices[1], want)
+	}
+
+	devices, err = parseROCmOutput(rocmMI210)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if devices[0].Name != "Aldebaran/MI200 [Instinct MI210]" || devices[0].PowerDraw != 43 {
+		t.Fatalf("MI210 = %+v, want its series name and socket power", devices[0])
+	}
+}
+
+func TestGPUBackendAutoDetection(t *testing.T) {
+	amdHost := func(t *testing.T) string {
+		return fakeSysfs(t, map[string]map[string]string{
+			"hwmon0": {"name": "k10temp\n", "temp1_input": "45000\n"},
+			"hwmon1": {"name": "amdgpu\n", "temp1_label": "edge\n", "temp1_input": "39000\n"},
+		})
+	}
+	tests := []struct {
+		name  string
+		tools []string // commands found on PATH
+		root  func(t *testing.T) string
+		want  string
+	}{
+		{name: "nvidia-smi wins", tools: []string{"/usr/bin/nvidia-smi", "rocm-smi"}, root: amdHost, want: config.GPUBackendNvidia},
+		{name: "rocm-smi without nvidia-smi", tools: []string{"rocm-smi"}, root: amdHost, want: config.GPUBackendROCm},
+		{name: "hwmon node without vendor tools", root: amdHost, want: config.GPUBackendHwmon},
+		{name: "nothing found", root: func(t *testing.T) string { return t.TempDir() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Monitoring.Hwmon.Root = tt.root(t)
+			m := NewGPUMonitor(cfg)
+			m.lookPath = func(file string) (string, error) {
+				for _, tool := range tt.tools {
+					if tool == file {
+						return file, nil
+					}
+				}
+				return "", errors.New("not found")
+			}
+			got, err := m.detect()
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("detect = %q, want an error with no GPU present", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("detect = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadUsesROCmBackend(t *testing.T) {
+	cfg := config.Default()
+	cfg.GPU.Backend = config.GPUBackendROCm
+	m := NewGPUMonitor(cfg)
+	var ran string
+	m.output = func(ctx context.Context, env []string, name string, args ...string) (string, string, error) {
+		ran = name + " " + strings.Join(args, " ")
+		return rocmMI100, "", nil
+	}
+
+	r, err := m.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if r.Max != 46 || len(r.Devices) != 2 {
+		t.Fatalf("reading = %+v, want two cards, max 46", r)
+	}
+	if !strings.HasPrefix(ran, "rocm-smi ") || !strings.HasSuffix(ran, " --json") {
+		t.Fatalf("ran %q, want rocm-smi ... --json", ran)
+	}
+}
@@ -0,0 +1,177 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// nvidiaStreamPeriod is how often the persistent nvidia-smi prints a row
+	// per GPU.
+	nvidiaStreamPeriod = time.Second
+	// nvidiaStreamStale is the age past which a streamed row is no longer
+	// used. One stale GPU makes the whole stream stale, so a card that stops
+	// reporting is read (and fails) through the one-shot query instead of
+	// silently dropping out.
+	nvidiaStreamStale = 5 * nvidiaStreamPeriod
+	// nvidiaStreamRestart is the least time between starts, so an nvidia-smi
+	// that keeps exiting is not respawned every tick.
+	nvidiaStreamRestart = 30 * time.Second
+)
+
+// startFunc starts a long-running command. It returns the command's stdout
+// and a wait func that reaps it once stdout has ended.
+type startFunc func(ctx context.Context, name string, args ...string) (io.Reader, func() error, error)
+
+func realStart(ctx context.Context, name string, args ...string) (io.Reader, func() error, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	return stdout, func() error {
+		if err := cmd.Wait(); err != nil {
+			return fmt.Errorf("%w, stderr: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}, nil
+}
+
+// nvidiaStream keeps one nvidia-smi running in loop mode (-lms). It
+// initializes NVML once and prints a CSV row per GPU every period, which is
+// far cheaper than starting nvidia-smi every interval on a multi-GPU host.
+type nvidiaStream struct {
+	path string
+	// start and now are fields so tests can stand in for nvidia-smi and the
+	// clock.
+	start startFunc
+	now   func() time.Time
+
+	mu sync.Mutex
+	// query is nvidiaQuery until nvidia-smi rejects a telemetry field, then
+	// nvidiaBaseQuery.
+	query   string
+	running bool
+	closed  bool
+	started time.Time // last start attempt
+	cancel  context.CancelFunc
+	rows    map[int]streamedRow // latest row per GPU index
+}
+
+type streamedRow struct {
+	device GPUDevice
+	at     time.Time
+}
+
+func newNvidiaStream(path string) *nvidiaStream {
+	return &nvidiaStream{path: path, start: realStart, now: time.Now, query: nvidiaQuery}
+}
+
+// devices returns the latest row of every GPU the stream has reported,
+// starting nvidia-smi if it is not running. ok is false when there is nothing
+// current to return: the stream has just started, has exited, or a GPU's row
+// is older than nvidiaStreamStale.
+func (s *nvidiaStream) devices() ([]GPUDevice, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running && !s.closed && (s.started.IsZero() || s.now().Sub(s.started) >= nvidiaStreamRestart) {
+		s.launch()
+	}
+	if len(s.rows) == 0 {
+		return nil, false
+	}
+	devices := make([]GPUDevice, 0, len(s.rows))
+	for _, row := range s.rows {
+		if s.now().Sub(row.at) > nvidiaStreamStale {
+			return nil, false
+		}
+		devices = append(devices, row.device)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Index < devices[j].Index })
+	return devices, true
+}
+
+// launch starts nvidia-smi. s.mu must be held.
+func (s *nvidiaStream) launch() {
+	s.started = s.now()
+	ctx, cancel := context.WithCancel(context.Background())
+	ms := strconv.Itoa(int(nvidiaStreamPeriod / time.Millisecond))
+	out, wait, err := s.start(ctx, s.path, s.query, nvidiaFormat, "-lms", ms)
+	if err != nil {
+		cancel()
+		log.Printf("Persistent nvidia-smi failed to start (%v); using one-shot nvidia-smi", err)
+		return
+	}
+	s.running, s.cancel = true, cancel
+	s.rows = make(map[int]streamedRow)
+	log.Printf("GPU: persistent nvidia-smi streaming every %s", nvidiaStreamPeriod)
+	go s.consume(out, wait, cancel)
+}
+
+// consume stores each streamed row until nvidia-smi exits. An nvidia-smi that
+// rejected a telemetry field is restarted at once on nvidiaBaseQuery.
+func (s *nvidiaStream) consume(out io.Reader, wait func() error, cancel context.CancelFunc) {
+	scanner := bufio.NewScanner(out)
+	rejected := false
+	for scanner.Scan() {
+		if nvidiaFieldRejected(scanner.Text()) {
+			rejected = true
+			continue
+		}
+		// Rows that do not parse (blank lines, error text) are skipped; the
+		// GPU they were for goes stale if no good row follows.
+		devices, err := parseGPUOutput(scanner.Text())
+		if err != nil {
+			continue
+		}
+		s.mu.Lock()
+		for _, d := range devices {
+			s.rows[d.Index] = streamedRow{device: d, at: s.now()}
+		}
+		s.mu.Unlock()
+	}
+	err := wait()
+	cancel()
+
+	rejected = rejected || (err != nil && nvidiaFieldRejected(err.Error()))
+
+	s.mu.Lock()
+	s.running = false
+	s.rows = nil
+	closed := s.closed
+	if rejected && s.query != nvidiaBaseQuery {
+		s.query = nvidiaBaseQuery
+		s.started = time.Time{}
+		log.Printf("Persistent nvidia-smi rejected the GPU telemetry fields; restarting without them")
+	}
+	s.mu.Unlock()
+	if !closed {
+		log.Printf("Persistent nvidia-smi exited (%v); using one-shot nvidia-smi until it restarts", err)
+	}
+}
+
+// close stops nvidia-smi and keeps it from being restarted.
+func (s *nvidiaStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
@@ -0,0 +1,201 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+)
+
+// fakeClock is a settable clock shared with the stream's reader goroutine.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+	c.mu.Unlock()
+}
+
+// fakeNvidiaSmi stands in for a looping nvidia-smi: rows written to the
+// current run's pipe are its stdout, and closing the pipe is the process
+// exiting.
+type fakeNvidiaSmi struct {
+	mu     sync.Mutex
+	starts int
+	args   []string
+	w      *io.PipeWriter
+}
+
+func (f *fakeNvidiaSmi) start(ctx context.Context, name string, args ...string) (io.Reader, func() error, error) {
+	r, w := io.Pipe()
+	f.mu.Lock()
+	f.starts++
+	f.args = append([]string{name}, args...)
+	f.w = w
+	f.mu.Unlock()
+	return r, func() error { return errors.New("exit status 15") }, nil
+}
+
+func (f *fakeNvidiaSmi) print(t *testing.T, rows string) {
+	t.Helper()
+	f.mu.Lock()
+	w := f.w
+	f.mu.Unlock()
+	if _, err := io.WriteString(w, rows); err != nil {
+		t.Fatalf("writing rows: %v", err)
+	}
+}
+
+func (f *fakeNvidiaSmi) exit() {
+	f.mu.Lock()
+	f.w.Close()
+	f.mu.Unlock()
+}
+
+func (f *fakeNvidiaSmi) startCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.starts
+}
+
+// eventually polls cond until it holds, for state the reader goroutine sets.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// persistentGPUMonitor returns a monitor on the persistent nvidia-smi path,
+// with its one-shot nvidia-smi stubbed to report 40°C and count its calls.
+func persistentGPUMonitor(t *testing.T) (*GPUMonitor, *fakeNvidiaSmi, *fakeClock, *int) {
+	cfg := config.Default()
+	cfg.GPU.Backend = config.GPUBackendNvidia
+	cfg.GPU.Persistent = true
+	m := NewGPUMonitor(cfg)
+	t.Cleanup(m.Close)
+
+	smi := &fakeNvidiaSmi{}
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	m.stream.start, m.stream.now = smi.start, clock.now
+
+	oneShots := 0
+	m.output = func(ctx context.Context, env []string, name string, args ...string) (string, string, error) {
+		oneShots++
+		return "0, Tesla P40, 40, 0, 0, 24576, 50\n", "", nil
+	}
+	return m, smi, clock, &oneShots
+}
+
+func TestPersistentNvidiaSmiServesStreamedRows(t *testing.T) {
+	m, smi, _, oneShots := persistentGPUMonitor(t)
+
+	// Nothing streamed yet: the first read starts nvidia-smi and falls back.
+	r, err := m.Read()
+	if err != nil || r.Max != 40 || *oneShots != 1 {
+		t.Fatalf("first Read = %+v, %v (one-shots %d); want the one-shot 40°C", r, err, *oneShots)
+	}
+	if got := strings.Join(smi.args, " "); !strings.Contains(got, "-lms 1000") || !strings.Contains(got, nvidiaQuery) {
+		t.Fatalf("started %q, want nvidia-smi looping on the GPUDevice query", got)
+	}
+
+	smi.print(t, "0, Tesla P40, 45, 80, 2048, 24576, 220\n1, Tesla P40, 47, 85, 4096, 24576, 230\n")
+	eventually(t, "both streamed rows", func() bool {
+		d, ok := m.stream.devices()
+		return ok && len(d) == 2
+	})
+
+	r, err = m.Read()
+	if err != nil || r.Max != 47 || len(r.Devices) != 2 {
+		t.Fatalf("streamed Read = %+v, %v; want two GPUs, max 47", r, err)
+	}
+	if *oneShots != 1 || smi.startCount() != 1 {
+		t.Fatalf("one-shots %d, starts %d; want the stream to serve reads without forking", *oneShots, smi.startCount())
+	}
+}
+
+func TestPersistentNvidiaSmiStaleRowsFallBack(t *testing.T) {
+	m, smi, clock, oneShots := persistentGPUMonitor(t)
+	m.Read()
+	smi.print(t, "0, Tesla P40, 45, 80, 2048, 24576, 220\n1, Tesla P40, 47, 85, 4096, 24576, 230\n")
+	eventually(t, "both streamed rows", func() bool { _, ok := m.stream.devices(); return ok })
+
+	// GPU 1 stops reporting: its row goes stale and the whole read falls
+	// back rather than returning GPU 0 alone.
+	clock.advance(4 * time.Second)
+	smi.print(t, "0, Tesla P40, 46, 80, 2048, 24576, 220\n")
+	clock.advance(2 * time.Second)
+
+	r, err := m.Read()
+	if err != nil || r.Max != 40 || *oneShots != 2 {
+		t.Fatalf("Read with a stale GPU = %+v, %v (one-shots %d); want the one-shot reading", r, err, *oneShots)
+	}
+}
+
+func TestPersistentNvidiaSmiRestartsAfterExit(t *testing.T) {
+	m, smi, clock, oneShots := persistentGPUMonitor(t)
+	m.Read()
+	smi.print(t, "0, Tesla P40, 45, 80, 2048, 24576, 220\n")
+	eventually(t, "the streamed row", func() bool { _, ok := m.stream.devices(); return ok })
+
+	smi.exit()
+	eventually(t, "the stream to stop", func() bool { _, ok := m.stream.devices(); return !ok })
+
+	// Within the restart backoff reads use the one-shot query without
+	// respawning nvidia-smi.
+	clock.advance(10 * time.Second)
+	if r, err := m.Read(); err != nil || r.Max != 40 {
+		t.Fatalf("Read after exit = %+v, %v; want the one-shot reading", r, err)
+	}
+	if smi.startCount() != 1 {
+		t.Fatalf("starts = %d within the backoff, want 1", smi.startCount())
+	}
+
+	clock.advance(nvidiaStreamRestart)
+	m.Read()
+	if smi.startCount() != 2 {
+		t.Fatalf("starts = %d after the backoff, want a restart", smi.startCount())
+	}
+	if *oneShots != 3 {
+		t.Fatalf("one-shots = %d, want every read without current rows to use one", *oneShots)
+	}
+}
+
+func TestPersistentNvidiaSmiDropsRejectedTelemetry(t *testing.T) {
+	m, smi, _, _ := persistentGPUMonitor(t)
+	m.Read()
+	smi.print(t, "Field \"temperature.memory\" is not a valid field to query.\n")
+	smi.exit()
+	eventually(t, "the base query", func() bool {
+		m.stream.mu.Lock()
+		defer m.stream.mu.Unlock()
+		return !m.stream.running && m.stream.query == nvidiaBaseQuery
+	})
+
+	// Restarted at once, without waiting out the restart backoff.
+	m.stream.devices()
+	smi.mu.Lock()
+	args := smi.args
+	smi.mu.Unlock()
+	if smi.startCount() != 2 || len(args) < 2 || args[1] != nvidiaBaseQuery {
+		t.Fatalf("starts %d with %q, want a restart on the base query", smi.startCount(), args)
+	}
+}
@@ -1,6 +1,12 @@
 package monitor
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+)
 
 func TestParseGPUOutput(t *testing.T) {
 	tests := []struct {
@@ -67,3 +73,89 @@ func TestParseGPUOutput(t *testing.T) {
 		})
 	}
 }
+
+func intPtr(v int) *int { return &v }
+
+func TestParseGPUOutputTelemetry(t *testing.T) {
+	// An H100 (HBM, thermally throttling at the hardware limit) next to a
+	// passively cooled P40 (GDDR, no memory sensor or fan), plus a row from
+	// an older query without the telemetry columns.
+	output := "0, NVIDIA H100 PCIe, 83, 100, 61000, 81559, 310, 92, 100, 1410, 1593, 0x0000000000000048, GPU-4f7c1d1e-52b3-7a8e-3c1f-9d0e6b2a8c44\n" +
+		"1, Tesla P40, 47, 0, 0, 24576, 50, N/A, [N/A], 544, 405, 0x0000000000000001\n" +
+		"2, Tesla P40, 46, 0, 0, 24576, 49\n"
+
+	devices, err := parseGPUOutput(output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h100 := devices[0]
+	if h100.MemoryTemp == nil || *h100.MemoryTemp != 92 || h100.FanSpeed == nil || *h100.FanSpeed != 100 {
+		t.Fatalf("H100 memory temp/fan = %v/%v, want 92/100", h100.MemoryTemp, h100.FanSpeed)
+	}
+	if h100.UUID != "GPU-4f7c1d1e-52b3-7a8e-3c1f-9d0e6b2a8c44" {
+		t.Fatalf("H100 UUID = %q", h100.UUID)
+	}
+	if h100.GraphicsClock != 1410 || h100.MemoryClock != 1593 {
+		t.Fatalf("H100 clocks = %d/%d, want 1410/1593", h100.GraphicsClock, h100.MemoryClock)
+	}
+	if !equalStrings(h100.ThrottleReasons, []string{"hw_slowdown", "hw_thermal_slowdown"}) || !h100.ThermalThrottled() {
+		t.Fatalf("H100 throttle reasons = %v, want hw_slowdown and hw_thermal_slowdown", h100.ThrottleReasons)
+	}
+
+	p40 := devices[1]
+	if p40.MemoryTemp != nil || p40.FanSpeed != nil {
+		t.Fatalf("P40 memory temp/fan = %v/%v, want nil for N/A", p40.MemoryTemp, p40.FanSpeed)
+	}
+	if !equalStrings(p40.ThrottleReasons, []string{"gpu_idle"}) || p40.ThermalThrottled() {
+		t.Fatalf("P40 throttle reasons = %v, want only gpu_idle (not thermal)", p40.ThrottleReasons)
+	}
+	if devices[2].Temp != 46 || devices[2].ThrottleReasons != nil {
+		t.Fatalf("seven-column row = %+v, want temperature only", devices[2])
+	}
+
+	r := &GPUReading{Devices: devices, Max: maxGPUTemp(devices)}
+	if !r.ThermalThrottled() || *r.MaxMemoryTemp() != 92 || *r.MaxFanSpeed() != 100 {
+		t.Fatalf("reading throttled=%v memory=%v fan=%v, want true/92/100", r.ThermalThrottled(), r.MaxMemoryTemp(), r.MaxFanSpeed())
+	}
+	if (&GPUReading{Devices: devices[1:]}).MaxMemoryTemp() != nil {
+		t.Fatal("MaxMemoryTemp without memory sensors should be nil")
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// A driver that does not know a telemetry field makes nvidia-smi reject the
+// whole query; the read falls back to the base fields and stays there.
+func TestNvidiaRejectedTelemetryFallsBackToBaseQuery(t *testing.T) {
+	cfg := config.Default()
+	cfg.GPU.Backend = config.GPUBackendNvidia
+	m := NewGPUMonitor(cfg)
+	var queries []string
+	m.output = func(ctx context.Context, env []string, name string, args ...string) (string, string, error) {
+		queries = append(queries, args[0])
+		if args[0] != nvidiaBaseQuery {
+			return "Field \"temperature.memory\" is not a valid field to query.\n", "", errors.New("exit status 2")
+		}
+		return "0, Tesla K80, 45, 10, 100, 11441, 60, GPU-1d3e\n", "", nil
+	}
+
+	for range 2 {
+		r, err := m.Read()
+		if err != nil || r.Max != 45 || r.Devices[0].UUID != "GPU-1d3e" || r.Devices[0].MemoryTemp != nil {
+			t.Fatalf("Read = %+v, %v; want the base fields with the uuid", r, err)
+		}
+	}
+	if len(queries) != 3 || queries[0] != nvidiaQuery || queries[1] != nvidiaBaseQuery || queries[2] != nvidiaBaseQuery {
+		t.Fatalf("queries = %q, want the full query once, then only the base one", queries)
+	}
+}
@@ -0,0 +1,135 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+)
+
+// HwmonMonitor reads CPU (and optionally NVMe/drive) temperatures from the
+// local kernel's hwmon drivers under /sys/class/hwmon, for a controller that
+// runs on the host it cools. It is a drop-in for CPUMonitor: every selected
+// input is one entry in CPUReading.Temps.
+type HwmonMonitor struct {
+	root    string
+	sensors []config.HwmonSensor
+}
+
+func NewHwmonMonitor(cfg *config.Config) *HwmonMonitor {
+	return &HwmonMonitor{
+		root:    cfg.Monitoring.Hwmon.EffectiveRoot(),
+		sensors: cfg.Monitoring.Hwmon.EffectiveSensors(),
+	}
+}
+
+// Read walks every hwmon device and returns the temperature inputs matching
+// the configured chip/label patterns. Inputs that cannot be read right now (a
+// drive in standby returns ENODATA) are skipped; finding none at all is an
+// error, never a 0°C reading.
+func (m *HwmonMonitor) Read() (*CPUReading, error) {
+	temps, err := readHwmon(m.root, m.selected)
+	if err != nil {
+		return nil, err
+	}
+	if len(temps) == 0 {
+		return nil, fmt.Errorf("no hwmon temperature input under %s matches monitoring.hwmon.sensors", m.root)
+	}
+	return &CPUReading{Temps: temps, Max: maxInt(temps)}, nil
+}
+
+// selected reports whether an input of chip with label matches any configured
+// sensor.
+func (m *HwmonMonitor) selected(chip, label string) bool {
+	for _, s := range m.sensors {
+		if hwmonMatch(s, chip, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// readHwmon returns the plausible temperatures of every input under root that
+// selected accepts, in device then input order. Only selected inputs are read,
+// so an unrelated sleeping drive is never touched.
+func readHwmon(root string, selected func(chip, label string) bool) ([]int, error) {
+	devices, err := filepath.Glob(filepath.Join(root, "hwmon*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(devices)
+
+	var temps []int
+	for _, dev := range devices {
+		chip := readSysfs(filepath.Join(dev, "name"))
+		inputs, _ := filepath.Glob(filepath.Join(dev, "temp*_input"))
+		sort.Slice(inputs, func(i, j int) bool { return tempIndex(inputs[i]) < tempIndex(inputs[j]) })
+		for _, input := range inputs {
+			label := readSysfs(strings.TrimSuffix(input, "_input") + "_label")
+			if !selected(chip, label) {
+				continue
+			}
+			temp, err := readMillidegrees(input)
+			if err != nil {
+				log.Printf("hwmon: skipping %s (%s %q): %v", input, chip, label, err)
+				continue
+			}
+			if temp > 0 && temp < 120 {
+				temps = append(temps, temp)
+			}
+		}
+	}
+	return temps, nil
+}
+
+// hwmonMatch reports whether an input of chip with label matches s. Patterns
+// were validated with the config, so match errors cannot occur here.
+func hwmonMatch(s config.HwmonSensor, chip, label string) bool {
+	if ok, _ := path.Match(s.Chip, chip); !ok {
+		return false
+	}
+	if s.Label == "" {
+		return true
+	}
+	ok, _ := path.Match(s.Label, label)
+	return ok
+}
+
+// readSysfs returns a sysfs attribute without its trailing newline, or "" if
+// it does not exist.
+func readSysfs(name string) string {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// readMillidegrees reads a tempN_input file (millidegrees Celsius) and rounds
+// it to whole degrees.
+func readMillidegrees(name string) (int, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, fmt.Errorf("malformed reading %q", strings.TrimSpace(string(b)))
+	}
+	return int(math.Round(float64(v) / 1000)), nil
+}
+
+// tempIndex extracts N from a .../tempN_input path so inputs sort
+// numerically (temp2 before temp10).
+func tempIndex(input string) int {
+	base := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(input), "temp"), "_input")
+	n, _ := strconv.Atoi(base)
+	return n
+}
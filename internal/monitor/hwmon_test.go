@@ -0,0 +1,124 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+)
+
+// fakeSysfs lays out a /sys/class/hwmon tree: each device maps attribute file
+// names to contents.
+func fakeSysfs(t *testing.T, devices map[string]map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for dev, files := range devices {
+		dir := filepath.Join(root, dev)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+// dualSocketHost is a two-socket Intel host with an NVMe drive and a SATA disk.
+func dualSocketHost(t *testing.T) string {
+	return fakeSysfs(t, map[string]map[string]string{
+		"hwmon0": {"name": "acpitz\n", "temp1_input": "27800\n"},
+		"hwmon1": {
+			"name":        "coretemp\n",
+			"temp1_label": "Package id 0\n", "temp1_input": "51000\n",
+			"temp2_label": "Core 0\n", "temp2_input": "49000\n",
+		},
+		"hwmon2": {
+			"name":        "coretemp\n",
+			"temp1_label": "Package id 1\n", "temp1_input": "55600\n",
+			"temp2_label": "Core 0\n", "temp2_input": "53000\n",
+		},
+		"hwmon3": {"name": "nvme\n", "temp1_label": "Composite\n", "temp1_input": "38850\n"},
+		"hwmon4": {"name": "drivetemp\n", "temp1_input": "33000\n"},
+	})
+}
+
+func hwmonMonitor(root string, sensors ...config.HwmonSensor) *HwmonMonitor {
+	cfg := config.Default()
+	cfg.Monitoring.CPUSource = config.CPUSourceHwmon
+	cfg.Monitoring.Hwmon = config.HwmonSourceConfig{Root: root, Sensors: sensors}
+	return NewHwmonMonitor(cfg)
+}
+
+func TestHwmonDefaultsToCPUPackages(t *testing.T) {
+	r, err := hwmonMonitor(dualSocketHost(t)).Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !equalInts(r.Temps, []int{51, 56}) || r.Max != 56 {
+		t.Fatalf("reading = %+v, want the two package temps [51 56]", r)
+	}
+}
+
+func TestHwmonLabelSelection(t *testing.T) {
+	m := hwmonMonitor(dualSocketHost(t),
+		config.HwmonSensor{Chip: "coretemp", Label: "Package id *"},
+		config.HwmonSensor{Chip: "nvme", Label: "Composite"},
+		config.HwmonSensor{Chip: "drivetemp"}, // unlabelled input, empty label matches
+	)
+	r, err := m.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !equalInts(r.Temps, []int{51, 56, 39, 33}) {
+		t.Fatalf("temps = %v, want packages, NVMe composite and drive", r.Temps)
+	}
+}
+
+func TestHwmonAMD(t *testing.T) {
+	root := fakeSysfs(t, map[string]map[string]string{
+		"hwmon0": {
+			"name":        "k10temp\n",
+			"temp1_label": "Tctl\n", "temp1_input": "62125\n",
+			"temp3_label": "Tccd1\n", "temp3_input": "58000\n",
+		},
+	})
+	r, err := hwmonMonitor(root).Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !equalInts(r.Temps, []int{62}) {
+		t.Fatalf("temps = %v, want Tctl only", r.Temps)
+	}
+}
+
+func TestHwmonNoMatchIsAnError(t *testing.T) {
+	root := fakeSysfs(t, map[string]map[string]string{
+		"hwmon0": {"name": "acpitz\n", "temp1_input": "27800\n"},
+		// A sleeping drive: the input exists but cannot be parsed.
+		"hwmon1": {"name": "drivetemp\n", "temp1_input": ""},
+	})
+	if r, err := hwmonMonitor(root, config.HwmonSensor{Chip: "coretemp"}, config.HwmonSensor{Chip: "drivetemp"}).Read(); err == nil {
+		t.Fatalf("Read = %+v, want an error when no input is readable", r)
+	}
+}
+
+func TestHwmonSortsInputsNumerically(t *testing.T) {
+	root := fakeSysfs(t, map[string]map[string]string{
+		"hwmon0": {
+			"name":        "coretemp\n",
+			"temp2_label": "Core 0\n", "temp2_input": "40000\n",
+			"temp10_label": "Core 8\n", "temp10_input": "48000\n",
+		},
+	})
+	r, err := hwmonMonitor(root, config.HwmonSensor{Chip: "coretemp", Label: "Core *"}).Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !equalInts(r.Temps, []int{40, 48}) {
+		t.Fatalf("temps = %v, want temp2 before temp10", r.Temps)
+	}
+}
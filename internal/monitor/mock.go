@@ -85,9 +85,11 @@ func (m *MockGPUMonitor) Read() (*GPUReading, error) {
 
 		util := 0
 		power := 50
+		clock := 544
 		if m.loadActive {
 			util = 80 + rand.Intn(20)
 			power = 200 + rand.Intn(50)
+			clock = 1531
 		}
 
 		devices[i] = GPUDevice{
@@ -98,6 +100,9 @@ func (m *MockGPUMonitor) Read() (*GPUReading, error) {
 			MemoryUsed:  1024 + rand.Intn(2048),
 			MemoryTotal: 24576,
 			PowerDraw:   power,
+			// A P40 is passively cooled GDDR5: no fan or memory temperature.
+			GraphicsClock: clock,
+			MemoryClock:   3615,
 		}
 	}
 
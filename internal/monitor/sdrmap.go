@@ -0,0 +1,139 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+	"github.com/sethpjohnson/only-fan-controller/internal/ipmi"
+)
+
+// sdrRule is a compiled config.SDRMapping.
+type sdrRule struct {
+	name   *regexp.Regexp // nil matches every name
+	entity string
+	number *int
+	role   string
+}
+
+// sensorMap assigns BMC temperature sensors their role: the first rule
+// matching a sensor decides, and a sensor no rule matches is ignored.
+type sensorMap struct {
+	profile string
+	rules   []sdrRule
+}
+
+// newSensorMap compiles monitoring.sdr_map and the monitoring.sdr_profile
+// entries after it. Config validation rejects bad patterns; one that gets
+// here anyway is logged and skipped.
+func newSensorMap(m config.MonitoringConfig) sensorMap {
+	sm := sensorMap{profile: m.SDRProfile}
+	if sm.profile == "" {
+		sm.profile = config.SDRProfileGeneric
+	}
+	for _, e := range m.EffectiveSDRMap() {
+		r := sdrRule{entity: e.Entity, number: e.Number, role: e.Role}
+		if e.Name != "" {
+			re, err := regexp.Compile(e.Name)
+			if err != nil {
+				log.Printf("Ignoring SDR map entry with bad name pattern %q: %v", e.Name, err)
+				continue
+			}
+			r.name = re
+		}
+		sm.rules = append(sm.rules, r)
+	}
+	return sm
+}
+
+func (r sdrRule) matches(s ipmi.Sensor) bool {
+	if r.name != nil && !r.name.MatchString(s.Name) {
+		return false
+	}
+	if r.number != nil && *r.number != int(s.Number) {
+		return false
+	}
+	if r.entity != "" && s.Entity != r.entity && !strings.HasPrefix(s.Entity, r.entity+".") {
+		return false
+	}
+	return true
+}
+
+// role returns the role of s, "" when no rule matches it.
+func (sm sensorMap) role(s ipmi.Sensor) string {
+	for _, r := range sm.rules {
+		if r.matches(s) {
+			return r.role
+		}
+	}
+	return ""
+}
+
+// reading sorts the BMC's temperature sensors into a CPU reading: the CPU
+// temperatures with socket-numbered ones first in socket order, and the
+// inlet and exhaust air. CPU readings outside 0..120°C are dropped as
+// implausible; a sub-zero inlet is plausible in an unheated room, so the
+// chassis sensors only drop readings outside -40..120°C. No CPU sensor is an
+// error that lists every sensor seen, never a 0°C reading.
+func (sm sensorMap) reading(sensors []ipmi.Sensor) (*CPUReading, error) {
+	type socketTemp struct{ socket, temp int }
+	var sockets []socketTemp
+	var unnumbered []int
+	reading := &CPUReading{}
+	for _, s := range sensors {
+		temp := int(math.Round(s.Value))
+		role := sm.role(s)
+		if socket, ok := config.SDRCPUSocket(role); ok {
+			if temp <= 0 || temp >= 120 {
+				continue
+			}
+			if socket < 0 {
+				unnumbered = append(unnumbered, temp)
+			} else {
+				sockets = append(sockets, socketTemp{socket, temp})
+			}
+			continue
+		}
+		if temp <= -40 || temp >= 120 {
+			continue
+		}
+		switch {
+		case role == config.SDRRoleInlet && reading.Inlet == nil:
+			reading.Inlet = &temp
+		case role == config.SDRRoleExhaust && reading.Exhaust == nil:
+			reading.Exhaust = &temp
+		}
+	}
+	sort.SliceStable(sockets, func(i, j int) bool { return sockets[i].socket < sockets[j].socket })
+	for _, s := range sockets {
+		reading.Temps = append(reading.Temps, s.temp)
+	}
+	reading.Temps = append(reading.Temps, unnumbered...)
+	if len(reading.Temps) == 0 {
+		return nil, fmt.Errorf("no valid CPU temperature among the BMC sensors (sdr_profile %q, add monitoring.sdr_map entries for this platform); sensors seen: %s",
+			sm.profile, describeSensors(sensors))
+	}
+	reading.Max = maxInt(reading.Temps)
+	return reading, nil
+}
+
+// describeSensors lists sensors the way `ipmitool sdr type temperature` shows
+// them, as a starting point for an sdr_map.
+func describeSensors(sensors []ipmi.Sensor) string {
+	if len(sensors) == 0 {
+		return "none"
+	}
+	parts := make([]string, len(sensors))
+	for i, s := range sensors {
+		entity := s.Entity
+		if entity == "" {
+			entity = "?"
+		}
+		parts[i] = fmt.Sprintf("%q (number %02Xh, entity %s) %g°C", s.Name, s.Number, entity, s.Value)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,106 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+)
+
+// SensorMonitor reads the named sensors configured under sensors: (inlet and
+// exhaust air, NVMe, HBA, DIMMs, ...). The BMC's temperature sensors are
+// listed once per Read however many ipmi sensors are configured.
+type SensorMonitor struct {
+	sensors   []config.SensorConfig
+	bmc       *CPUMonitor
+	hwmonRoot string
+}
+
+// NewSensorMonitor returns a reader for cfg.Sensors. bmc provides access to
+// the BMC (native session or ipmitool) for the ipmi-sourced sensors.
+func NewSensorMonitor(cfg *config.Config, bmc *CPUMonitor) *SensorMonitor {
+	return &SensorMonitor{
+		sensors:   cfg.Sensors,
+		bmc:       bmc,
+		hwmonRoot: cfg.Monitoring.Hwmon.EffectiveRoot(),
+	}
+}
+
+// Read returns the temperature of every configured sensor that could be read,
+// keyed by name: the hottest input its selector matches. A sensor that cannot
+// be read is left out of the map and named in the returned error, which
+// therefore does not mean the map is empty.
+func (m *SensorMonitor) Read() (map[string]int, error) {
+	temps := make(map[string]int, len(m.sensors))
+	var errs []error
+
+	var bmcSensors []bmcTemp
+	var bmcErr error
+	bmcRead := false
+
+	for _, s := range m.sensors {
+		switch s.Source {
+		case config.SensorSourceIPMI:
+			if !bmcRead {
+				bmcSensors, bmcErr = m.readBMC()
+				bmcRead = true
+			}
+			if bmcErr != nil {
+				errs = append(errs, fmt.Errorf("sensor %s: %w", s.Name, bmcErr))
+				continue
+			}
+			found := false
+			for _, b := range bmcSensors {
+				if ok, _ := path.Match(s.IPMIName, b.name); ok && (!found || b.temp > temps[s.Name]) {
+					temps[s.Name] = b.temp
+					found = true
+				}
+			}
+			if !found {
+				errs = append(errs, fmt.Errorf("sensor %s: no BMC temperature sensor named %q", s.Name, s.IPMIName))
+			}
+		case config.SensorSourceHwmon:
+			match := func(chip, label string) bool { return hwmonMatch(s.Hwmon, chip, label) }
+			vals, err := readHwmon(m.hwmonRoot, match)
+			if err == nil && len(vals) == 0 {
+				err = fmt.Errorf("no hwmon input matches chip %q label %q", s.Hwmon.Chip, s.Hwmon.Label)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("sensor %s: %w", s.Name, err))
+				continue
+			}
+			temps[s.Name] = maxInt(vals)
+		}
+	}
+	return temps, errors.Join(errs...)
+}
+
+// bmcTemp is one plausible BMC temperature reading.
+type bmcTemp struct {
+	name string
+	temp int
+}
+
+// readBMC lists the BMC's temperature sensors, dropping implausible values
+// just as the CPU read does.
+func (m *SensorMonitor) readBMC() ([]bmcTemp, error) {
+	if m.bmc == nil {
+		return nil, errors.New("no BMC connection")
+	}
+	sensors, err := m.bmc.temperatureSensors()
+	if err != nil {
+		return nil, err
+	}
+	var out []bmcTemp
+	for _, s := range sensors {
+		temp := int(math.Round(s.Value))
+		if temp <= 0 || temp >= 120 {
+			continue
+		}
+		out = append(out, bmcTemp{name: strings.TrimSpace(s.Name), temp: temp})
+	}
+	return out, nil
+}
@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+)
+
+const r730SDR = `Inlet Temp       | 04h | ok  |  7.1 | 21 degrees C
+Exhaust Temp     | 01h | ok  |  7.1 | 34 degrees C
+Temp             | 0Eh | ok  |  3.1 | 48 degrees C
+Temp             | 0Fh | ok  |  3.2 | 52 degrees C
+PCH Temp         | 10h | ns  |  7.1 | No Reading
+`
+
+func TestParseSDRTemps(t *testing.T) {
+	sensors := parseSDRTemps(r730SDR)
+	if len(sensors) != 4 {
+		t.Fatalf("got %d sensors, want 4 (the row without a reading is dropped): %+v", len(sensors), sensors)
+	}
+	if sensors[0].Name != "Inlet Temp" || sensors[0].Value != 21 {
+		t.Fatalf("first sensor = %+v, want Inlet Temp at 21", sensors[0])
+	}
+}
+
+func TestSensorMonitorReadsBMCAndHwmon(t *testing.T) {
+	cfg := config.Default()
+	cfg.Monitoring.Hwmon.Root = dualSocketHost(t)
+	cfg.Sensors = []config.SensorConfig{
+		{Name: "inlet", Source: config.SensorSourceIPMI, IPMIName: "Inlet Temp"},
+		{Name: "exhaust", Source: config.SensorSourceIPMI, IPMIName: "Exhaust*"},
+		{Name: "cpus", Source: config.SensorSourceIPMI, IPMIName: "Temp"},
+		{Name: "nvme", Source: config.SensorSourceHwmon, Hwmon: config.HwmonSensor{Chip: "nvme", Label: "Composite"}},
+	}
+	bmc := NewCPUMonitor(cfg)
+	calls := 0
+	bmc.output = func(ctx context.Context, env []string, name string, args ...string) (string, string, error) {
+		calls++
+		return r730SDR, "", nil
+	}
+
+	temps, err := NewSensorMonitor(cfg, bmc).Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := map[string]int{"inlet": 21, "exhaust": 34, "cpus": 52, "nvme": 39}
+	for name, temp := range want {
+		if temps[name] != temp {
+			t.Errorf("%s = %d, want %d", name, temps[name], temp)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("ipmitool ran %d times, want one SDR listing for all ipmi sensors", calls)
+	}
+}
+
+func TestSensorMonitorReportsMissingSensors(t *testing.T) {
+	cfg := config.Default()
+	cfg.Monitoring.Hwmon.Root = dualSocketHost(t)
+	cfg.Sensors = []config.SensorConfig{
+		{Name: "inlet", Source: config.SensorSourceIPMI, IPMIName: "Inlet Temp"},
+		{Name: "hba", Source: config.SensorSourceIPMI, IPMIName: "HBA Temp"},
+	}
+	bmc := NewCPUMonitor(cfg)
+	bmc.native = fakeSensors{{Name: "Inlet Temp", Value: 22.6}}
+
+	temps, err := NewSensorMonitor(cfg, bmc).Read()
+	if err == nil {
+		t.Fatal("Read returned no error for a sensor the BMC does not have")
+	}
+	if _, ok := temps["hba"]; ok {
+		t.Fatalf("missing sensor got a reading: %v", temps)
+	}
+	if temps["inlet"] != 23 {
+		t.Fatalf("inlet = %d, want 23 despite the missing sensor", temps["inlet"])
+	}
+}
@@ -82,6 +82,10 @@ type Bridge struct {
 	// (re)connect so a reconnect re-announces every socket.
 	cpuDiscMu     sync.Mutex
 	cpuDiscovered map[int]bool
+	// fanDiscovered does the same for the fan tachometers read back by
+	// fan_control.verify, by position in the state fans array.
+	fanDiscMu     sync.Mutex
+	fanDiscovered map[int]bool
 	// retainWarned tracks command topics we have already logged a dropped
 	// retained message for, so reconnect replays warn once per topic instead of
 	// flooding the log.
@@ -123,6 +127,7 @@ func New(cfg *config.Config, consumer Consumer, factory ClientFactory) *Bridge {
 		newClient:     factory,
 		gpuDiscovered: map[int]bool{},
 		cpuDiscovered: map[int]bool{},
+		fanDiscovered: map[int]bool{},
 		retainWarned:  map[string]bool{},
 	}
 }
@@ -170,15 +175,17 @@ func (b *Bridge) Start() {
 // the entities registered and the command paths live before it sees them go
 // online.
 //
-// Per-GPU and per-CPU discovery are deliberately NOT published here: at cold
-// start MQTT connects before the control loop's first sensor read, so the device
-// set is still empty. They are announced lazily from the publish loop as devices
-// appear (see publishNewGPUDiscovery / publishNewCPUDiscovery). Clearing the
+// Per-GPU, per-CPU and per-fan discovery are deliberately NOT published here:
+// at cold start MQTT connects before the control loop's first sensor read, so
+// the device set is still empty. They are announced lazily from the publish
+// loop as devices appear (see publishNewGPUDiscovery / publishNewCPUDiscovery
+// / publishNewFanDiscovery). Clearing the
 // discovered maps on every (re)connect makes that loop re-announce everything on
 // the new connection.
 func (b *Bridge) onConnect() {
 	b.resetGPUDiscovery()
 	b.resetCPUDiscovery()
+	b.resetFanDiscovery()
 	b.publishDiscovery()
 	b.subscribeCommands()
 	b.publishAvailability(true)
@@ -201,6 +208,14 @@ func (b *Bridge) resetCPUDiscovery() {
 	b.cpuDiscMu.Unlock()
 }
 
+// resetFanDiscovery forgets which fans have been announced, so the publish
+// loop re-publishes per-fan discovery on the next tick. Called on (re)connect.
+func (b *Bridge) resetFanDiscovery() {
+	b.fanDiscMu.Lock()
+	b.fanDiscovered = map[int]bool{}
+	b.fanDiscMu.Unlock()
+}
+
 // publishAvailability publishes the retained availability state.
 func (b *Bridge) publishAvailability(online bool) {
 	payload := "offline"
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
 	"github.com/sethpjohnson/only-fan-controller/internal/monitor"
 )
 
@@ -82,12 +84,22 @@ func (b *Bridge) gpuSensorConfig(objectID, name, valueExpr, deviceClass, unit st
 // binarySensorConfig builds an ON/OFF binary sensor from a boolean value_json
 // key, using device_class "problem" (HA shows red when true).
 func (b *Bridge) binarySensorConfig(objectID, name, valueKey string) map[string]any {
+	e := b.flagSensorConfig(objectID, name, valueKey, "")
+	e["device_class"] = "problem"
+	return e
+}
+
+// flagSensorConfig builds an ON/OFF binary sensor from a boolean value_json key
+// for informational flags that are not problems.
+func (b *Bridge) flagSensorConfig(objectID, name, valueKey, icon string) map[string]any {
 	e := b.baseEntity(objectID, name)
 	e["state_topic"] = b.stateTopic()
 	e["value_template"] = "{{ 'ON' if value_json." + valueKey + " else 'OFF' }}"
 	e["payload_on"] = "ON"
 	e["payload_off"] = "OFF"
-	e["device_class"] = "problem"
+	if icon != "" {
+		e["icon"] = icon
+	}
 	return e
 }
 
@@ -139,9 +151,22 @@ func (b *Bridge) discoveryEntities() []discoveryEntity {
 		{"binary_sensor", "failsafe_active", b.binarySensorConfig("failsafe_active", "Failsafe Active", "failsafe_active")},
 		{"binary_sensor", "restore_pending", b.binarySensorConfig("restore_pending", "Restore Pending", "restore_pending")},
 		{"binary_sensor", "last_write_failed", b.binarySensorConfig("last_write_failed", "Last Fan Write Failed", "last_write_failed")},
+		{"sensor", "cpu_crossing_in", b.sensorConfig("cpu_crossing_in", "CPU Threshold Predicted In", "cpu_crossing_in", "duration", "s", "")},
+		{"sensor", "gpu_crossing_in", b.sensorConfig("gpu_crossing_in", "GPU Threshold Predicted In", "gpu_crossing_in", "duration", "s", "")},
+		{"sensor", "inlet_temp", b.sensorConfig("inlet_temp", "Inlet Temperature", "inlet_temp", "temperature", "°C", "")},
+		{"sensor", "exhaust_temp", b.sensorConfig("exhaust_temp", "Exhaust Temperature", "exhaust_temp", "temperature", "°C", "")},
+		{"sensor", "ambient_shift", b.sensorConfig("ambient_shift", "Ambient Threshold Shift", "ambient_shift", "", "°C", "mdi:thermometer-auto")},
+		{"binary_sensor", "pre_ramp", b.flagSensorConfig("pre_ramp", "Predictive Pre-Ramp", "pre_ramp", "mdi:fan-chevron-up")},
+		{"binary_sensor", "gpu_throttled", b.binarySensorConfig("gpu_throttled", "GPU Thermal Throttling", "gpu_throttled")},
 		{"number", "override_speed", b.numberConfig()},
 		{"button", "override_clear", b.buttonConfig()},
 	}
+	for _, s := range b.cfg.Sensors {
+		specs = append(specs, b.namedSensorSpec(s))
+	}
+	if b.cfg.FanControl.Verify.Enabled {
+		specs = append(specs, discoverySpec{"binary_sensor", "fan_failure", b.binarySensorConfig("fan_failure", "Fan Failure", "fan_failure")})
+	}
 	entities := make([]discoveryEntity, 0, len(specs))
 	for _, s := range specs {
 		payload, err := json.Marshal(s.config)
@@ -157,8 +182,9 @@ func (b *Bridge) discoveryEntities() []discoveryEntity {
 	return entities
 }
 
-// gpuDeviceSpecs builds the three discovery sensors (temperature, utilization,
-// power) for a single GPU device.
+// gpuDeviceSpecs builds the discovery sensors for a single GPU device:
+// temperature, utilization and power, plus memory temperature, fan and graphics
+// clock when the card reports them.
 //
 // arrayIndex is the device's position in the state `gpus` array (what the
 // value_templates index into, matching the order buildStatePayload appends
@@ -171,7 +197,7 @@ func (b *Bridge) gpuDeviceSpecs(arrayIndex int, d monitor.GPUDevice) []discovery
 	utilID := fmt.Sprintf("gpu%d_utilization", d.Index)
 	powerID := fmt.Sprintf("gpu%d_power", d.Index)
 	label := fmt.Sprintf("GPU %d (%s)", d.Index, d.Name)
-	return []discoverySpec{
+	specs := []discoverySpec{
 		{"sensor", tempID, b.gpuSensorConfig(
 			tempID, label+" Temperature",
 			fmt.Sprintf("value_json.gpus[%d].temp", arrayIndex), "temperature", "°C")},
@@ -182,6 +208,27 @@ func (b *Bridge) gpuDeviceSpecs(arrayIndex int, d monitor.GPUDevice) []discovery
 			powerID, label+" Power",
 			fmt.Sprintf("value_json.gpus[%d].power", arrayIndex), "power", "W")},
 	}
+	// Memory temperature, the card's fan and clocks only exist on some cards
+	// and backends; a card that does not report one gets no entity for it.
+	if d.MemoryTemp != nil {
+		id := fmt.Sprintf("gpu%d_memory_temp", d.Index)
+		specs = append(specs, discoverySpec{"sensor", id, b.gpuSensorConfig(
+			id, label+" Memory Temperature",
+			fmt.Sprintf("value_json.gpus[%d].memory_temp", arrayIndex), "temperature", "°C")})
+	}
+	if d.FanSpeed != nil {
+		id := fmt.Sprintf("gpu%d_fan_speed", d.Index)
+		specs = append(specs, discoverySpec{"sensor", id, b.gpuSensorConfig(
+			id, label+" Fan",
+			fmt.Sprintf("value_json.gpus[%d].fan_speed", arrayIndex), "", "%")})
+	}
+	if d.GraphicsClock > 0 {
+		id := fmt.Sprintf("gpu%d_graphics_clock", d.Index)
+		specs = append(specs, discoverySpec{"sensor", id, b.gpuSensorConfig(
+			id, label+" Graphics Clock",
+			fmt.Sprintf("value_json.gpus[%d].graphics_clock", arrayIndex), "frequency", "MHz")})
+	}
+	return specs
 }
 
 // cpuDeviceSpecs builds the single temperature discovery sensor for one CPU
@@ -205,6 +252,39 @@ func (b *Bridge) cpuDeviceSpecs(index int) []discoverySpec {
 	}
 }
 
+// fanDeviceSpec builds the RPM sensor for the fan at index in the state
+// `fans` array. The BMC lists its fans in a fixed order, so index keys the
+// stable object_id (fan0_rpm, fan1_rpm, ...) and name, the BMC's sensor name,
+// only labels it.
+func (b *Bridge) fanDeviceSpec(index int, name string) discoverySpec {
+	objectID := fmt.Sprintf("fan%d_rpm", index)
+	e := b.gpuSensorConfig(objectID, name+" Speed", fmt.Sprintf("value_json.fans[%d].rpm", index), "", "RPM")
+	e["icon"] = "mdi:fan"
+	return discoverySpec{"sensor", objectID, e}
+}
+
+// namedSensorSpec builds the temperature sensor for one configured named
+// sensor. The set comes from the config, so unlike the per-GPU and per-CPU
+// entities it is known at connect and published with the fixed entities. The
+// value is looked up by subscript: a name may start with a digit ("0nvme"),
+// which is not a valid Jinja attribute.
+func (b *Bridge) namedSensorSpec(s config.SensorConfig) discoverySpec {
+	objectID := "sensor_" + s.Name + "_temp"
+	return discoverySpec{"sensor", objectID, b.gpuSensorConfig(
+		objectID, namedSensorLabel(s.Name)+" Temperature",
+		"value_json.sensors['"+s.Name+"']", "temperature", "°C")}
+}
+
+// namedSensorLabel turns a sensor name into a display label: "nvme_0" becomes
+// "Nvme 0".
+func namedSensorLabel(name string) string {
+	words := strings.Fields(strings.ReplaceAll(name, "_", " "))
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
+
 // publishDiscovery publishes all discovery config messages, retained.
 func (b *Bridge) publishDiscovery() {
 	for _, e := range b.discoveryEntities() {
@@ -3,6 +3,9 @@ package mqtt
 import (
 	"encoding/json"
 	"testing"
+
+	"github.com/sethpjohnson/only-fan-controller/internal/config"
+	"github.com/sethpjohnson/only-fan-controller/internal/controller"
 )
 
 func TestDiscoveryEntitiesCoverAllEntities(t *testing.T) {
@@ -21,6 +24,13 @@ func TestDiscoveryEntitiesCoverAllEntities(t *testing.T) {
 		"homeassistant/binary_sensor/only-fan-controller/failsafe_active/config",
 		"homeassistant/binary_sensor/only-fan-controller/restore_pending/config",
 		"homeassistant/binary_sensor/only-fan-controller/last_write_failed/config",
+		"homeassistant/sensor/only-fan-controller/cpu_crossing_in/config",
+		"homeassistant/sensor/only-fan-controller/gpu_crossing_in/config",
+		"homeassistant/sensor/only-fan-controller/inlet_temp/config",
+		"homeassistant/sensor/only-fan-controller/exhaust_temp/config",
+		"homeassistant/sensor/only-fan-controller/ambient_shift/config",
+		"homeassistant/binary_sensor/only-fan-controller/pre_ramp/config",
+		"homeassistant/binary_sensor/only-fan-controller/gpu_throttled/config",
 		"homeassistant/number/only-fan-controller/override_speed/config",
 		"homeassistant/button/only-fan-controller/override_clear/config",
 	}
@@ -38,6 +48,28 @@ func TestDiscoveryEntitiesCoverAllEntities(t *testing.T) {
 	}
 }
 
+func TestFanFailureDiscoveryFollowsVerify(t *testing.T) {
+	cfg := testConfig()
+	cfg.FanControl.Verify.Enabled = true
+	h := &clientHolder{}
+	b := New(cfg, &fakeConsumer{}, h.factory)
+
+	var payload map[string]any
+	for _, e := range b.discoveryEntities() {
+		if e.topic == "homeassistant/binary_sensor/only-fan-controller/fan_failure/config" {
+			if err := json.Unmarshal(e.payload, &payload); err != nil {
+				t.Fatalf("unmarshal fan_failure config: %v", err)
+			}
+		}
+	}
+	if payload["device_class"] != "problem" || payload["value_template"] != "{{ 'ON' if value_json.fan_failure else 'OFF' }}" {
+		t.Fatalf("fan_failure config = %v, want a problem sensor on fan_failure", payload)
+	}
+	if p := buildStatePayload(&controller.Status{FanFailure: true}); !p.FanFailure {
+		t.Fatal("state payload dropped fan_failure")
+	}
+}
+
 func TestCPUAggregateSensorLabel(t *testing.T) {
 	h := &clientHolder{}
 	b := New(testConfig(), &fakeConsumer{}, h.factory)
@@ -122,3 +154,90 @@ func TestPublishDiscoveryOnConnect(t *testing.T) {
 		t.Fatalf("discovery publish retained=%v qos=%d, want true/1", msg.retained, msg.qos)
 	}
 }
+
+func TestNamedSensorDiscoveryMatchesState(t *testing.T) {
+	cfg := testConfig()
+	cfg.Sensors = []config.SensorConfig{
+		{Name: "inlet", Source: config.SensorSourceIPMI, IPMIName: "Inlet Temp"},
+		{Name: "nvme_0", Source: config.SensorSourceHwmon, Hwmon: config.HwmonSensor{Chip: "nvme"}},
+	}
+	h := &clientHolder{}
+	b := New(cfg, &fakeConsumer{}, h.factory)
+
+	configs := map[string]map[string]any{}
+	for _, e := range b.discoveryEntities() {
+		var payload map[string]any
+		if err := json.Unmarshal(e.payload, &payload); err != nil {
+			t.Fatalf("unmarshal %s: %v", e.topic, err)
+		}
+		configs[e.topic] = payload
+	}
+	inlet := configs["homeassistant/sensor/only-fan-controller/sensor_inlet_temp/config"]
+	nvme := configs["homeassistant/sensor/only-fan-controller/sensor_nvme_0_temp/config"]
+	if inlet == nil || nvme == nil {
+		t.Fatalf("named sensor discovery missing; topics: %v", configs)
+	}
+	if nvme["name"] != "Nvme 0 Temperature" || nvme["device_class"] != "temperature" {
+		t.Fatalf("nvme_0 config = %v", nvme)
+	}
+
+	temp := 24
+	status := &controller.Status{Sensors: []controller.SensorStatus{
+		{Name: "cpu", BuiltIn: true, Temp: &temp},
+		{Name: "inlet", Temp: &temp},
+		{Name: "nvme_0"},
+	}}
+	stateJSON, err := json.Marshal(buildStatePayload(status))
+	if err != nil {
+		t.Fatalf("marshal state: %v", err)
+	}
+	var state struct {
+		Sensors map[string]any `json:"sensors"`
+	}
+	if err := json.Unmarshal(stateJSON, &state); err != nil {
+		t.Fatalf("unmarshal state: %v", err)
+	}
+	if _, ok := state.Sensors["cpu"]; ok {
+		t.Fatalf("built-in cpu leaked into sensors: %v", state.Sensors)
+	}
+	if state.Sensors["inlet"] != float64(24) {
+		t.Fatalf("sensors.inlet = %v, want 24", state.Sensors["inlet"])
+	}
+	if v, ok := state.Sensors["nvme_0"]; !ok || v != nil {
+		t.Fatalf("sensors.nvme_0 = %v (present %v), want null", v, ok)
+	}
+	if tmpl := inlet["value_template"]; tmpl != "{{ value_json.sensors['inlet'] | default('') }}" {
+		t.Fatalf("inlet value_template = %v", tmpl)
+	}
+}
+
+func TestFanDiscoveryFollowsReadback(t *testing.T) {
+	consumer := &fakeConsumer{status: &controller.Status{}}
+	h := &clientHolder{}
+	b := New(testConfig(), consumer, h.factory)
+	b.Start()
+
+	consumer.setStatus(&controller.Status{Fans: []controller.FanStatus{
+		{Name: "Fan1", RPM: 4200},
+		{Name: "Fan2", RPM: 0, Stalled: true},
+	}})
+	b.publishState()
+	b.publishState()
+
+	msg, ok := h.client.lastPublishOn("homeassistant/sensor/only-fan-controller/fan1_rpm/config")
+	if !ok {
+		t.Fatal("missing per-fan discovery for the second fan")
+	}
+	var cfg map[string]any
+	if err := json.Unmarshal(msg.payload, &cfg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if cfg["name"] != "Fan2 Speed" || cfg["unit_of_measurement"] != "RPM" || cfg["value_template"] != "{{ value_json.fans[1].rpm | default('') }}" {
+		t.Fatalf("fan1_rpm config = %v", cfg)
+	}
+
+	p := buildStatePayload(consumer.GetStatus())
+	if len(p.Fans) != 2 || p.Fans[1].RPM != 0 || !p.Fans[1].Stalled || p.Fans[0].RPM != 4200 {
+		t.Fatalf("state fans = %+v", p.Fans)
+	}
+}
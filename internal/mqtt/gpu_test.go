@@ -256,3 +256,60 @@ func TestGPUDiscoveryTemplatesMatchStateKeys(t *testing.T) {
 		t.Fatalf("checked %d per-GPU templates, want 6", checked)
 	}
 }
+
+// TestGPUTelemetryDiscoveryMatchesState covers the optional per-card entities:
+// published only for a card reporting the value, and bound to a key present in
+// its state entry.
+func TestGPUTelemetryDiscoveryMatchesState(t *testing.T) {
+	memory, fan := 92, 100
+	status := &controller.Status{
+		GPUThrottled: true,
+		GPU: &monitor.GPUReading{Max: 83, Devices: []monitor.GPUDevice{
+			{Index: 0, Name: "NVIDIA H100 PCIe", Temp: 83, MemoryTemp: &memory, FanSpeed: &fan,
+				GraphicsClock: 1410, ThrottleReasons: []string{"hw_thermal_slowdown"}},
+			{Index: 1, Name: "Tesla P40", Temp: 47},
+		}},
+	}
+	h := &clientHolder{}
+	b := New(testConfig(), &fakeConsumer{status: status}, h.factory)
+
+	stateJSON, err := json.Marshal(buildStatePayload(status))
+	if err != nil {
+		t.Fatalf("marshal state: %v", err)
+	}
+	var state struct {
+		GPUThrottled bool             `json:"gpu_throttled"`
+		GPUs         []map[string]any `json:"gpus"`
+	}
+	if err := json.Unmarshal(stateJSON, &state); err != nil {
+		t.Fatalf("unmarshal state: %v", err)
+	}
+	if !state.GPUThrottled || state.GPUs[0]["throttled"] != true || state.GPUs[1]["throttled"] != false {
+		t.Fatalf("throttle flags = %v/%v/%v, want only GPU 0 throttled", state.GPUThrottled, state.GPUs[0]["throttled"], state.GPUs[1]["throttled"])
+	}
+
+	ids := map[string]bool{}
+	for i, d := range status.GPU.Devices {
+		for _, s := range b.gpuDeviceSpecs(i, d) {
+			ids[s.objectID] = true
+			tmpl, _ := s.config["value_template"].(string)
+			match := valueJSONKeyRe.FindStringSubmatch(tmpl)
+			if match == nil {
+				t.Fatalf("%s template %q does not index gpus", s.objectID, tmpl)
+			}
+			if _, ok := state.GPUs[i][match[2]]; !ok {
+				t.Errorf("%s reads gpus[%d].%s, absent from the state", s.objectID, i, match[2])
+			}
+		}
+	}
+	for _, id := range []string{"gpu0_memory_temp", "gpu0_fan_speed", "gpu0_graphics_clock"} {
+		if !ids[id] {
+			t.Errorf("missing %s for a card reporting it", id)
+		}
+	}
+	for _, id := range []string{"gpu1_memory_temp", "gpu1_fan_speed", "gpu1_graphics_clock"} {
+		if ids[id] {
+			t.Errorf("published %s for a card without it", id)
+		}
+	}
+}
@@ -26,6 +26,21 @@ type statePayload struct {
 	OverrideReason  *string `json:"override_reason"`
 	OverrideExpires *string `json:"override_expires"`
 	ActiveHintCount int     `json:"active_hint_count"`
+	// Trend feed-forward: seconds until each source is predicted to reach its
+	// threshold (null when not heading there) and whether fans are being
+	// pre-ramped on that prediction, so HA can show why fans moved early.
+	CPUCrossingIn *float64 `json:"cpu_crossing_in"`
+	GPUCrossingIn *float64 `json:"gpu_crossing_in"`
+	PreRamp       bool     `json:"pre_ramp"`
+	// Chassis inlet/exhaust air temperatures (null when the BMC has none) and
+	// the threshold shift inlet compensation is applying.
+	InletTemp    *int `json:"inlet_temp"`
+	ExhaustTemp  *int `json:"exhaust_temp"`
+	AmbientShift int  `json:"ambient_shift"`
+	// GPUThrottled is true while any GPU is thermally throttling.
+	GPUThrottled bool `json:"gpu_throttled"`
+	// FanFailure is true while fan_control.verify finds a fan failed.
+	FanFailure bool `json:"fan_failure"`
 	// CPUs carries one entry per CPU socket, so Home Assistant can show per-socket
 	// temperature on a multi-socket box. CPUTemp above stays the overall max (fan
 	// logic and the aggregate sensor depend on it). IPMI reports only per-socket
@@ -37,6 +52,24 @@ type statePayload struct {
 	// (fan logic and the aggregate sensor depend on it). The per-card discovery
 	// entities index into this array by position (gpus[0], gpus[1], ...).
 	GPUs []gpuState `json:"gpus"`
+	// Sensors maps each configured named sensor to its latest temperature
+	// (null when it could not be read). The named-sensor discovery entities
+	// read sensors.<name>.
+	Sensors map[string]*int `json:"sensors"`
+	// Fans carries one entry per fan tachometer read back by
+	// fan_control.verify (empty when that is off). The per-fan discovery
+	// entities index into this array by position (fans[0], fans[1], ...).
+	Fans []fanState `json:"fans"`
+}
+
+// fanState is one per-fan entry in statePayload.Fans. Keys here MUST stay in
+// sync with the value_templates emitted by the per-fan discovery sensors (see
+// discovery.go).
+type fanState struct {
+	Name    string `json:"name"`
+	RPM     int    `json:"rpm"`
+	Stalled bool   `json:"stalled"`
+	Failed  bool   `json:"failed"`
 }
 
 // cpuState is one per-socket entry in statePayload.CPUs. Keys here MUST stay in
@@ -56,6 +89,12 @@ type gpuState struct {
 	Temp        int    `json:"temp"`
 	Utilization int    `json:"utilization"`
 	Power       int    `json:"power"`
+	// Telemetry some cards lack: omitted rather than null, and the matching
+	// discovery entities are only published for cards that report it.
+	MemoryTemp    *int `json:"memory_temp,omitempty"`
+	FanSpeed      *int `json:"fan_speed,omitempty"`
+	GraphicsClock int  `json:"graphics_clock"`
+	Throttled     bool `json:"throttled"`
 }
 
 // buildStatePayload derives the wire payload from a controller status snapshot.
@@ -70,6 +109,14 @@ func buildStatePayload(status *controller.Status) statePayload {
 		RestorePending:  status.RestorePending,
 		LastWriteFailed: status.LastWriteFailed,
 		ActiveHintCount: len(status.ActiveHints),
+		CPUCrossingIn:   status.CPUCrossingIn,
+		GPUCrossingIn:   status.GPUCrossingIn,
+		PreRamp:         status.PreRamp,
+		InletTemp:       status.InletTemp,
+		ExhaustTemp:     status.ExhaustTemp,
+		AmbientShift:    status.AmbientShift,
+		GPUThrottled:    status.GPUThrottled,
+		FanFailure:      status.FanFailure,
 	}
 	if status.CPU != nil {
 		v := status.CPU.Max
@@ -83,14 +130,30 @@ func buildStatePayload(status *controller.Status) statePayload {
 		p.GPUTemp = &v
 		for _, d := range status.GPU.Devices {
 			p.GPUs = append(p.GPUs, gpuState{
-				Index:       d.Index,
-				Name:        d.Name,
-				Temp:        d.Temp,
-				Utilization: d.Utilization,
-				Power:       d.PowerDraw,
+				Index:         d.Index,
+				Name:          d.Name,
+				Temp:          d.Temp,
+				Utilization:   d.Utilization,
+				Power:         d.PowerDraw,
+				MemoryTemp:    d.MemoryTemp,
+				FanSpeed:      d.FanSpeed,
+				GraphicsClock: d.GraphicsClock,
+				Throttled:     d.ThermalThrottled(),
 			})
 		}
 	}
+	for _, f := range status.Fans {
+		p.Fans = append(p.Fans, fanState{Name: f.Name, RPM: f.RPM, Stalled: f.Stalled, Failed: f.Failed})
+	}
+	for _, s := range status.Sensors {
+		if s.BuiltIn {
+			continue
+		}
+		if p.Sensors == nil {
+			p.Sensors = make(map[string]*int)
+		}
+		p.Sensors[s.Name] = s.Temp
+	}
 	if status.Override != nil {
 		s := status.Override.Speed
 		r := status.Override.Reason
@@ -137,6 +200,39 @@ func (b *Bridge) publishState() {
 	// discovery while the broker is down.
 	b.publishNewGPUDiscovery(status)
 	b.publishNewCPUDiscovery(status)
+	b.publishNewFanDiscovery(status)
+}
+
+// publishNewFanDiscovery publishes the RPM sensor for any fan in status not
+// yet announced on the current connection, as publishNewCPUDiscovery does for
+// sockets. The fan set only appears once fan_control.verify has read the
+// tachometers.
+func (b *Bridge) publishNewFanDiscovery(status *controller.Status) {
+	if status == nil {
+		return
+	}
+	for i, f := range status.Fans {
+		b.fanDiscMu.Lock()
+		already := b.fanDiscovered[i]
+		b.fanDiscMu.Unlock()
+		if already {
+			continue
+		}
+		s := b.fanDeviceSpec(i, f.Name)
+		payload, err := json.Marshal(s.config)
+		if err != nil {
+			log.Printf("MQTT: failed to marshal fan discovery for %s/%s: %v", s.component, s.objectID, err)
+			continue
+		}
+		topic := b.discoveryTopic(s.component, s.objectID)
+		if err := b.client.Publish(topic, 1, true, payload); err != nil {
+			log.Printf("MQTT: failed to publish fan discovery %s: %v", topic, err)
+			continue
+		}
+		b.fanDiscMu.Lock()
+		b.fanDiscovered[i] = true
+		b.fanDiscMu.Unlock()
+	}
 }
 
 // publishNewCPUDiscovery publishes retained discovery configs for any CPU socket
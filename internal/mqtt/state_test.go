@@ -54,6 +54,27 @@ func TestBuildStatePayloadFull(t *testing.T) {
 	}
 }
 
+func TestBuildStatePayloadSafeSpeedStage(t *testing.T) {
+	p := buildStatePayload(&controller.Status{FailsafeActive: true, FailsafeReason: "safe-speed", TargetSpeed: 60})
+	if !p.FailsafeActive || p.FailsafeReason != "safe-speed" || p.RestorePending {
+		t.Fatalf("failsafe fields = %+v, want the safe-speed stage with no restore pending", p)
+	}
+}
+
+func TestBuildStatePayloadPrediction(t *testing.T) {
+	eta := 42.5
+	p := buildStatePayload(&controller.Status{CPUCrossingIn: &eta, PreRamp: true})
+	if p.CPUCrossingIn == nil || *p.CPUCrossingIn != 42.5 {
+		t.Fatalf("cpu_crossing_in = %v, want 42.5", p.CPUCrossingIn)
+	}
+	if p.GPUCrossingIn != nil {
+		t.Fatalf("gpu_crossing_in = %v, want nil", *p.GPUCrossingIn)
+	}
+	if !p.PreRamp {
+		t.Fatal("pre_ramp not carried into the state payload")
+	}
+}
+
 func TestBuildStatePayloadNilsBecomeNull(t *testing.T) {
 	p := buildStatePayload(&controller.Status{})
 	b, err := json.Marshal(p)
@@ -64,7 +85,7 @@ func TestBuildStatePayloadNilsBecomeNull(t *testing.T) {
 	if err := json.Unmarshal(b, &m); err != nil {
 		t.Fatalf("unmarshal: %v", err)
 	}
-	for _, k := range []string{"cpu_temp", "gpu_temp", "override_speed", "override_reason", "override_expires"} {
+	for _, k := range []string{"cpu_temp", "gpu_temp", "override_speed", "override_reason", "override_expires", "cpu_crossing_in", "gpu_crossing_in", "inlet_temp", "exhaust_temp"} {
 		if m[k] != nil {
 			t.Fatalf("%s = %v, want null", k, m[k])
 		}
@@ -2,8 +2,10 @@ package storage
 
 import (
 	"database/sql"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -18,6 +20,36 @@ type HistoryPoint struct {
 	CPUTemp   int       `json:"cpu_temp"`
 	GPUTemp   int       `json:"gpu_temp"`
 	FanSpeed  int       `json:"fan_speed"`
+
+	// Sensors holds the named sensors' temperatures (sensors: in the config),
+	// keyed by name. Omitted when none were read.
+	Sensors map[string]int `json:"sensors,omitempty"`
+
+	// Fans holds each fan tachometer's RPM, keyed by the BMC's sensor name.
+	// Omitted when fan readback is off or the read failed.
+	Fans map[string]int `json:"fans,omitempty"`
+
+	// GPU cooling telemetry; see GPUTelemetry.
+	GPUMemoryTemp *int `json:"gpu_memory_temp,omitempty"`
+	GPUFanSpeed   *int `json:"gpu_fan_speed,omitempty"`
+	GPUThrottled  bool `json:"gpu_throttled,omitempty"`
+}
+
+// Event is a notable change in the controller's state, such as a fan failing,
+// kept alongside the readings.
+type Event struct {
+	Timestamp time.Time `json:"timestamp"`
+	Kind      string    `json:"kind"`
+	Message   string    `json:"message"`
+}
+
+// GPUTelemetry is the GPU cooling telemetry stored with a reading, across all
+// GPUs: the hottest memory temperature and fastest GPU fan (nil when no GPU
+// reports them) and whether any GPU was thermally throttling.
+type GPUTelemetry struct {
+	MemoryTemp *int
+	FanSpeed   *int
+	Throttled  bool
 }
 
 func New(dbPath string) (*Store, error) {
@@ -43,34 +75,117 @@ func New(dbPath string) (*Store, error) {
 		);
 		
 		CREATE INDEX IF NOT EXISTS idx_readings_timestamp ON readings(timestamp);
+
+		CREATE TABLE IF NOT EXISTS events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+			kind TEXT NOT NULL,
+			message TEXT NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_events_timestamp ON events(timestamp);
 	`)
 	if err != nil {
 		db.Close()
 		return nil, err
 	}
+	if err := addColumns(db, map[string]string{
+		"sensors":         "TEXT",
+		"fans":            "TEXT",
+		"gpu_memory_temp": "INTEGER",
+		"gpu_fan_speed":   "INTEGER",
+		"gpu_throttled":   "INTEGER NOT NULL DEFAULT 0",
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
 
 	return &Store{db: db}, nil
 }
 
+// addColumns adds the columns (name to type) missing from a readings table
+// created before they existed: the JSON-encoded named sensors and fan RPMs
+// and the GPU telemetry.
+func addColumns(db *sql.DB, columns map[string]string) error {
+	rows, err := db.Query("PRAGMA table_info(readings)")
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if existing[name] {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE readings ADD COLUMN " + name + " " + columns[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *Store) Close() error {
 	return s.db.Close()
 }
 
-// RecordReading stores a temperature/fan reading
-func (s *Store) RecordReading(cpuTemp, gpuTemp, fanSpeed int) error {
-	_, err := s.db.Exec(
-		"INSERT INTO readings (cpu_temp, gpu_temp, fan_speed) VALUES (?, ?, ?)",
-		cpuTemp, gpuTemp, fanSpeed,
+// RecordReading stores a temperature/fan reading. sensors carries the named
+// sensors' temperatures and fans the fan RPMs; either may be nil.
+func (s *Store) RecordReading(cpuTemp, gpuTemp, fanSpeed int, gpu GPUTelemetry, sensors, fans map[string]int) error {
+	encodedSensors, err := encodeMap(sensors)
+	if err != nil {
+		return err
+	}
+	encodedFans, err := encodeMap(fans)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO readings (cpu_temp, gpu_temp, fan_speed, sensors, fans, gpu_memory_temp, gpu_fan_speed, gpu_throttled)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		cpuTemp, gpuTemp, fanSpeed, encodedSensors, encodedFans, gpu.MemoryTemp, gpu.FanSpeed, gpu.Throttled,
 	)
 	return err
 }
 
+// encodeMap JSON-encodes m for a TEXT column, NULL when it is empty.
+func encodeMap(m map[string]int) (sql.NullString, error) {
+	if len(m) == 0 {
+		return sql.NullString{}, nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
+
 // GetHistory retrieves readings for the specified duration
 func (s *Store) GetHistory(duration time.Duration) ([]HistoryPoint, error) {
 	cutoff := time.Now().Add(-duration)
 	
 	rows, err := s.db.Query(`
-		SELECT timestamp, cpu_temp, gpu_temp, fan_speed 
+		SELECT timestamp, cpu_temp, gpu_temp, fan_speed, sensors, fans, gpu_memory_temp, gpu_fan_speed, gpu_throttled
 		FROM readings 
 		WHERE timestamp > datetime(?)
 		ORDER BY timestamp ASC
@@ -84,28 +199,75 @@ func (s *Store) GetHistory(duration time.Duration) ([]HistoryPoint, error) {
 	for rows.Next() {
 		var p HistoryPoint
 		var ts string
-		if err := rows.Scan(&ts, &p.CPUTemp, &p.GPUTemp, &p.FanSpeed); err != nil {
+		var sensors, fans sql.NullString
+		if err := rows.Scan(&ts, &p.CPUTemp, &p.GPUTemp, &p.FanSpeed, &sensors, &fans,
+			&p.GPUMemoryTemp, &p.GPUFanSpeed, &p.GPUThrottled); err != nil {
 			continue
 		}
-		// Try multiple timestamp formats
-		for _, format := range []string{
-			"2006-01-02 15:04:05",
-			"2006-01-02T15:04:05Z",
-			time.RFC3339,
-		} {
-			if t, err := time.Parse(format, ts); err == nil {
-				p.Timestamp = t
-				break
-			}
+		// A malformed value only costs this point its named sensors or fans.
+		if sensors.Valid {
+			_ = json.Unmarshal([]byte(sensors.String), &p.Sensors)
+		}
+		if fans.Valid {
+			_ = json.Unmarshal([]byte(fans.String), &p.Fans)
 		}
+		p.Timestamp = parseTimestamp(ts)
 		history = append(history, p)
 	}
 
 	return history, nil
 }
 
-// Cleanup removes old readings beyond retention period and returns the number
-// of rows deleted.
+// RecordEvent stores an event of the given kind.
+func (s *Store) RecordEvent(kind, message string) error {
+	_, err := s.db.Exec("INSERT INTO events (kind, message) VALUES (?, ?)", kind, message)
+	return err
+}
+
+// GetEvents retrieves the events for the specified duration, oldest first.
+func (s *Store) GetEvents(duration time.Duration) ([]Event, error) {
+	cutoff := time.Now().Add(-duration)
+	rows, err := s.db.Query(`
+		SELECT timestamp, kind, message
+		FROM events
+		WHERE timestamp > datetime(?)
+		ORDER BY timestamp ASC, id ASC
+	`, cutoff.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var ts string
+		if err := rows.Scan(&ts, &e.Kind, &e.Message); err != nil {
+			continue
+		}
+		e.Timestamp = parseTimestamp(ts)
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// parseTimestamp parses a timestamp column, trying the formats SQLite and
+// go-sqlite3 write; the zero time when none match.
+func parseTimestamp(ts string) time.Time {
+	for _, format := range []string{
+		"2006-01-02 15:04:05",
+		"2006-01-02T15:04:05Z",
+		time.RFC3339,
+	} {
+		if t, err := time.Parse(format, ts); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Cleanup removes old readings and events beyond retention period and returns
+// the number of readings deleted.
 func (s *Store) Cleanup(retention time.Duration) (int64, error) {
 	cutoff := time.Now().Add(-retention)
 	// Match GetHistory's cutoff formatting: bind a plain UTC "YYYY-MM-DD
@@ -121,5 +283,11 @@ func (s *Store) Cleanup(retention time.Duration) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	if _, err := s.db.Exec(
+		"DELETE FROM events WHERE timestamp < datetime(?)",
+		cutoff.UTC().Format("2006-01-02 15:04:05"),
+	); err != nil {
+		return 0, err
+	}
 	return res.RowsAffected()
 }
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"database/sql"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -59,19 +61,19 @@ func TestCleanupRemovesOnlyOldReadings(t *testing.T) {
 	}
 	defer s.Close()
 
-	if err := s.RecordReading(50, 0, 20); err != nil {
+	if err := s.RecordReading(50, 0, 20, GPUTelemetry{}, nil, nil); err != nil {
 		t.Fatalf("RecordReading failed: %v", err)
 	}
 	tooOld := lastReadingID(t, s)
 	ageReading(t, s, tooOld, 25) // 25h old: must be deleted under 24h retention
 
-	if err := s.RecordReading(51, 0, 20); err != nil {
+	if err := s.RecordReading(51, 0, 20, GPUTelemetry{}, nil, nil); err != nil {
 		t.Fatalf("RecordReading failed: %v", err)
 	}
 	stillFresh := lastReadingID(t, s)
 	ageReading(t, s, stillFresh, 23) // 23h old: must survive 24h retention
 
-	if err := s.RecordReading(52, 0, 20); err != nil {
+	if err := s.RecordReading(52, 0, 20, GPUTelemetry{}, nil, nil); err != nil {
 		t.Fatalf("RecordReading failed: %v", err)
 	}
 	// Left at "now" (unaged): must survive.
@@ -105,7 +107,7 @@ func TestCleanupNoOldReadingsDeletesNothing(t *testing.T) {
 	}
 	defer s.Close()
 
-	if err := s.RecordReading(40, 30, 20); err != nil {
+	if err := s.RecordReading(40, 30, 20, GPUTelemetry{}, nil, nil); err != nil {
 		t.Fatalf("RecordReading failed: %v", err)
 	}
 
@@ -117,3 +119,158 @@ func TestCleanupNoOldReadingsDeletesNothing(t *testing.T) {
 		t.Fatalf("expected 0 rows deleted, got %d", deleted)
 	}
 }
+
+func TestHistoryCarriesNamedSensors(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory store: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.RecordReading(45, 40, 30, GPUTelemetry{}, map[string]int{"inlet": 22, "nvme0": 41}, nil); err != nil {
+		t.Fatalf("RecordReading failed: %v", err)
+	}
+	if err := s.RecordReading(46, 40, 30, GPUTelemetry{}, nil, nil); err != nil {
+		t.Fatalf("RecordReading failed: %v", err)
+	}
+
+	history, err := s.GetHistory(time.Hour)
+	if err != nil {
+		t.Fatalf("GetHistory returned error: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("got %d points, want 2", len(history))
+	}
+	if got := history[0].Sensors; got["inlet"] != 22 || got["nvme0"] != 41 {
+		t.Fatalf("first point sensors = %v, want inlet=22 nvme0=41", got)
+	}
+	if history[1].Sensors != nil {
+		t.Fatalf("second point sensors = %v, want none", history[1].Sensors)
+	}
+}
+
+func TestHistoryCarriesFanRPMs(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory store: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.RecordReading(45, 40, 30, GPUTelemetry{}, nil, map[string]int{"Fan1": 4200, "Fan2": 4320}); err != nil {
+		t.Fatalf("RecordReading failed: %v", err)
+	}
+	history, err := s.GetHistory(time.Hour)
+	if err != nil {
+		t.Fatalf("GetHistory returned error: %v", err)
+	}
+	if len(history) != 1 || history[0].Fans["Fan1"] != 4200 || history[0].Fans["Fan2"] != 4320 || history[0].Sensors != nil {
+		t.Fatalf("history = %+v, want the two fan RPMs and no sensors", history)
+	}
+}
+
+func TestEventsAreRecordedAndCleanedUp(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory store: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.RecordEvent("fan_failure", "Fan3 failed"); err != nil {
+		t.Fatalf("RecordEvent failed: %v", err)
+	}
+	if _, err := s.db.Exec("UPDATE events SET timestamp = datetime('now', '-25 hours')"); err != nil {
+		t.Fatalf("failed to age event: %v", err)
+	}
+	if err := s.RecordEvent("fan_recovered", "Fan3 recovered"); err != nil {
+		t.Fatalf("RecordEvent failed: %v", err)
+	}
+
+	events, err := s.GetEvents(48 * time.Hour)
+	if err != nil {
+		t.Fatalf("GetEvents returned error: %v", err)
+	}
+	if len(events) != 2 || events[0].Kind != "fan_failure" || events[1].Message != "Fan3 recovered" || events[1].Timestamp.IsZero() {
+		t.Fatalf("events = %+v, want the failure then the recovery", events)
+	}
+
+	if _, err := s.Cleanup(24 * time.Hour); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	events, err = s.GetEvents(48 * time.Hour)
+	if err != nil {
+		t.Fatalf("GetEvents returned error: %v", err)
+	}
+	if len(events) != 1 || events[0].Kind != "fan_recovered" {
+		t.Fatalf("events after cleanup = %+v, want only the recent one", events)
+	}
+}
+
+func TestHistoryCarriesGPUTelemetry(t *testing.T) {
+	s, err := New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory store: %v", err)
+	}
+	defer s.Close()
+
+	memory, fan := 92, 100
+	if err := s.RecordReading(45, 83, 60, GPUTelemetry{MemoryTemp: &memory, FanSpeed: &fan, Throttled: true}, nil, nil); err != nil {
+		t.Fatalf("RecordReading failed: %v", err)
+	}
+	if err := s.RecordReading(45, 47, 30, GPUTelemetry{}, nil, nil); err != nil {
+		t.Fatalf("RecordReading failed: %v", err)
+	}
+
+	history, err := s.GetHistory(time.Hour)
+	if err != nil {
+		t.Fatalf("GetHistory returned error: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("got %d points, want 2", len(history))
+	}
+	hot := history[0]
+	if hot.GPUMemoryTemp == nil || *hot.GPUMemoryTemp != 92 || hot.GPUFanSpeed == nil || *hot.GPUFanSpeed != 100 || !hot.GPUThrottled {
+		t.Fatalf("first point GPU telemetry = %v/%v/%v, want 92/100/throttled", hot.GPUMemoryTemp, hot.GPUFanSpeed, hot.GPUThrottled)
+	}
+	if cool := history[1]; cool.GPUMemoryTemp != nil || cool.GPUFanSpeed != nil || cool.GPUThrottled {
+		t.Fatalf("second point GPU telemetry = %v/%v/%v, want none", cool.GPUMemoryTemp, cool.GPUFanSpeed, cool.GPUThrottled)
+	}
+}
+
+// TestNewMigratesOldReadingsTable opens a database written before named
+// sensors existed: its rows must survive and new readings must be storable.
+func TestNewMigratesOldReadingsTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		CREATE TABLE readings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+			cpu_temp INTEGER,
+			gpu_temp INTEGER,
+			fan_speed INTEGER
+		);
+		INSERT INTO readings (cpu_temp, gpu_temp, fan_speed) VALUES (44, 39, 25);
+	`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("New on an old database: %v", err)
+	}
+	defer s.Close()
+	if err := s.RecordReading(47, 39, 25, GPUTelemetry{}, map[string]int{"inlet": 23}, nil); err != nil {
+		t.Fatalf("RecordReading after migration: %v", err)
+	}
+	history, err := s.GetHistory(time.Hour)
+	if err != nil {
+		t.Fatalf("GetHistory returned error: %v", err)
+	}
+	if len(history) != 2 || history[0].CPUTemp != 44 || history[0].GPUThrottled || history[1].Sensors["inlet"] != 23 {
+		t.Fatalf("history = %+v, want the old row and the new one with its sensor", history)
+	}
+}